@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// atomNamespace is the namespace of Atom 1.0 (RFC 4287)
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// Link relations registered by RFC 4287; other values must be full IRIs
+var atomLinkRelations = map[string]bool{
+	"alternate": true,
+	"related":   true,
+	"self":      true,
+	"enclosure": true,
+	"via":       true,
+}
+
+// reMIMEType matches the type/subtype syntax required for link types
+var reMIMEType = regexp.MustCompile(`^[A-Za-z0-9][\w!#$&^.+-]*/[A-Za-z0-9][\w!#$&^.+-]*(\s*;.*)?$`)
+
+// parseRFC3339 parses an Atom date construct, fractional seconds included
+func parseRFC3339(value string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	return t, err == nil
+}
+
+// validateAtom checks Atom 1.0 feed structure
+func validateAtom(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	root, err := parseTree(content)
+	if err != nil || root == nil {
+		return errors // Reported by validateBasicXML
+	}
+
+	if root.Name.Local != "feed" || root.Name.Space != atomNamespace {
+		return append(errors, nodeError(ix, root, "Atom structure",
+			fmt.Sprintf("Root element must be <feed xmlns=%q>", atomNamespace)))
+	}
+
+	errors = append(errors, checkAtomCommon(ix, root)...)
+
+	entries := root.childrenNamed(atomNamespace, "entry")
+	feedHasAuthor := root.child(atomNamespace, "author") != nil
+	for _, entry := range entries {
+		errors = append(errors, checkAtomCommon(ix, entry)...)
+
+		if !feedHasAuthor && entry.child(atomNamespace, "author") == nil {
+			errors = append(errors, nodeError(ix, entry, "Atom missing element",
+				"<entry> has no <author> and the <feed> does not provide one"))
+		}
+
+		contentNode := entry.child(atomNamespace, "content")
+		if contentNode == nil && !hasAlternateLink(entry) {
+			errors = append(errors, nodeError(ix, entry, "Atom missing element",
+				"<entry> without <content> must have a <link rel=\"alternate\">"))
+		}
+		if contentNode != nil && entry.child(atomNamespace, "summary") == nil {
+			if _, hasSrc := contentNode.attr("src"); hasSrc {
+				errors = append(errors, nodeError(ix, entry, "Atom missing element",
+					"<entry> with out-of-line <content src=...> must have a <summary>"))
+			}
+		}
+	}
+
+	if !hasLinkRel(root, "self") {
+		errors = append(errors, nodeError(ix, root, "Atom missing element",
+			"<feed> should have a <link rel=\"self\"> pointing at the feed's own URL"))
+	}
+
+	return errors
+}
+
+// checkAtomCommon checks the rules shared by <feed> and <entry>: exactly
+// one id, title and updated, valid dates and well-formed links
+func checkAtomCommon(ix *lineIndex, n *xmlNode) []ValidationError {
+	var errors []ValidationError
+	name := n.Name.Local
+
+	for _, req := range []string{"id", "title", "updated"} {
+		switch found := n.childrenNamed(atomNamespace, req); len(found) {
+		case 1:
+		case 0:
+			errors = append(errors, nodeError(ix, n, "Atom missing element",
+				fmt.Sprintf("<%s> is missing required <%s>", name, req)))
+		default:
+			errors = append(errors, nodeError(ix, found[1], "Atom duplicate element",
+				fmt.Sprintf("<%s> must contain exactly one <%s>, found %d", name, req, len(found))))
+		}
+	}
+
+	for _, dateName := range []string{"updated", "published"} {
+		for _, d := range n.childrenNamed(atomNamespace, dateName) {
+			if dateErr := checkDateNode(ix, d, dateName, "rfc3339"); dateErr != nil {
+				errors = append(errors, *dateErr)
+			}
+		}
+	}
+
+	alternates := make(map[string]bool)
+	for _, link := range n.childrenNamed(atomNamespace, "link") {
+		if href, ok := link.attr("href"); !ok || strings.TrimSpace(href) == "" {
+			errors = append(errors, nodeError(ix, link, "Atom invalid link", "<link> is missing its href attribute"))
+		}
+
+		rel, hasRel := link.attr("rel")
+		if !hasRel {
+			rel = "alternate"
+		}
+		if !atomLinkRelations[rel] && !strings.Contains(rel, ":") {
+			errors = append(errors, nodeError(ix, link, "Atom invalid link",
+				fmt.Sprintf("<link rel=%q> is not a registered relation or an IRI", rel)))
+		}
+
+		linkType, hasType := link.attr("type")
+		if hasType && !reMIMEType.MatchString(linkType) {
+			errors = append(errors, nodeError(ix, link, "Atom invalid link",
+				fmt.Sprintf("<link type=%q> is not a valid MIME type", linkType)))
+		}
+
+		if rel == "alternate" {
+			hreflang, _ := link.attr("hreflang")
+			key := linkType + "|" + hreflang
+			if alternates[key] {
+				errors = append(errors, nodeError(ix, link, "Atom invalid link",
+					fmt.Sprintf("<%s> has more than one rel=\"alternate\" link with the same type and hreflang", name)))
+			}
+			alternates[key] = true
+		}
+	}
+
+	return errors
+}
+
+// hasAlternateLink reports whether n has a link whose rel is alternate,
+// which is the default when rel is omitted
+func hasAlternateLink(n *xmlNode) bool {
+	for _, link := range n.childrenNamed(atomNamespace, "link") {
+		if rel, ok := link.attr("rel"); !ok || rel == "alternate" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLinkRel reports whether n has a link with the given rel
+func hasLinkRel(n *xmlNode, rel string) bool {
+	for _, link := range n.childrenNamed(atomNamespace, "link") {
+		if r, _ := link.attr("rel"); r == rel {
+			return true
+		}
+	}
+	return false
+}
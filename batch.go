@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchOptions controls how several inputs are fetched and validated
+type BatchOptions struct {
+	Manifest            string   // File listing one input per line
+	DownloadConcurrency int      // Inputs fetched at the same time
+	PerHost             int      // Concurrent requests to any one host
+	Jobs                int      // Inputs validated at the same time
+	RateLimit           float64  // Downloads started per second, 0 for no limit
+	HostRateLimit       float64  // Downloads started per second from any one host, 0 for no limit
+	MaxTotalErrors      int      // Errors reported across all inputs, 0 for no limit
+	Summary             bool     // Print one line per input instead of its issues
+	DetailsFor          []string // With Summary, inputs whose issues are printed as well
+	Follow              bool     // Also validate the XML resources the inputs link to
+	MaxDepth            int      // With Follow, how many links away to go
+
+	Fetched map[string][]byte // Inputs already read, e.g. by followLinks
+}
+
+// wantsDetails reports whether input was named with --details-for
+func (b BatchOptions) wantsDetails(input string) bool {
+	for _, name := range b.DetailsFor {
+		if name == input || filepath.Clean(name) == filepath.Clean(input) {
+			return true
+		}
+	}
+	return false
+}
+
+// batchInput is one fetched input, or the error that prevented it
+type batchInput struct {
+	content []byte
+	err     error
+}
+
+// batchResult is one validated input with the progress lines printed
+// while validating it
+type batchResult struct {
+	batchInput
+	ix       *lineIndex // Of content, shared by the checks and the report
+	errors   []ValidationError
+	cached   bool
+	progress []byte
+}
+
+// readManifest returns the inputs listed in a manifest, one path or URL
+// per line; blank lines and lines starting with # are ignored
+func readManifest(path string) ([]string, error) {
+	content, err := readFileContent(path)
+	if err != nil {
+		return nil, err
+	}
+	var inputs []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			inputs = append(inputs, line)
+		}
+	}
+	return inputs, nil
+}
+
+// runBatch validates several inputs and exits with the highest status any
+// of them calls for (see exit.go). Downloads run concurrently, at most
+// batch.PerHost at a time per host, and up to batch.Jobs inputs that have
+// arrived are validated at once. Results are reported in input order.
+func runBatch(inputs []string, opts ValidationOptions, batch BatchOptions, cache *resultCache) {
+	jobs := max(batch.Jobs, 1)
+	if jobs > 1 {
+		showProgress = false
+	}
+	validateOpts := opts
+	if batch.Summary || opts.MaxAllowed > 0 || opts.DiffBase != "" {
+		// Summary lines and the budget count every issue, not just the
+		// ones that would fit, and issues on changed lines may follow
+		// those on unchanged ones
+		validateOpts.MaxErrors = 0
+	}
+	if opts.DiffBase != "" {
+		fmt.Printf("%s Reporting only issues on lines changed since %s\n", highlightColor("Note:"), opts.DiffBase)
+	}
+	fetched, taken := fetchInputs(inputs, batch, jobs)
+	validated := validateInputs(inputs, fetched, validateOpts, jobs, cache)
+
+	failed, withIssues, cached := 0, 0, 0
+	issues, warnings, failingIssues := 0, 0, 0
+	reported, done := 0, 0 // Errors shown so far and inputs gone through
+	status := exitOK       // The highest status any input calls for
+	for i, input := range inputs {
+		if batch.MaxTotalErrors > 0 && reported >= batch.MaxTotalErrors {
+			fmt.Printf("\n%s Reported %d errors (--max-total-errors); skipping the remaining %d inputs\n",
+				infoColor("Note:"), reported, len(inputs)-i)
+			break
+		}
+		done++
+		if !batch.Summary {
+			fmt.Printf("\n%s\n", headerColor("========================================"))
+			fmt.Printf("Validating XML: %s\n", input)
+		}
+		res := <-validated[i]
+		taken()
+		opts.OnError.run(input, res.ix, res.errors, opts)
+		opts.Webhook.add(input, res.errors, res.err, opts.FailOn)
+
+		shown := opts
+		if batch.MaxTotalErrors > 0 {
+			shown.MaxErrors = min(opts.MaxErrors, batch.MaxTotalErrors-reported)
+		}
+		if !batch.Summary {
+			reported += printDetails(input, res, shown)
+		} else {
+			printSummaryLine(input, res)
+			if len(res.errors) > 0 && batch.wantsDetails(input) {
+				reported += displayErrors(input, res.ix, res.errors, shown)
+				fmt.Println()
+			}
+		}
+
+		if res.cached {
+			cached++
+		}
+		switch {
+		case res.err != nil:
+			failed++
+			status = max(status, inputExitCode(res.err))
+		case len(res.errors) > 0:
+			withIssues++
+			issues += len(res.errors)
+			warnings += countWarnings(res.errors)
+			failingIssues += countFailing(res.errors, opts.FailOn)
+		}
+	}
+
+	fmt.Printf("\n%s\n", headerColor("========================================"))
+	fmt.Printf("Validated %d inputs: %d well-formed, %d with issues, %d unreadable\n",
+		done, done-withIssues-failed, withIssues, failed)
+	if batch.Summary && issues > 0 {
+		fmt.Printf("Found %d issues in all (%d errors, %d warnings)\n", issues, issues-warnings, warnings)
+	}
+	if cached > 0 {
+		fmt.Printf("%d unchanged inputs were not validated again (use --no-cache to force)\n", cached)
+	}
+	opts.Timings.print(os.Stdout)
+	if overBudget(failingIssues, opts.MaxAllowed) {
+		status = max(status, exitValidation)
+	}
+	if err := cache.save(); err != nil && opts.Debug {
+		fmt.Printf("Cannot save the result cache: %v\n", err)
+	}
+	switch {
+	case withIssues == 0 || opts.ErrorFormat != nil:
+	case batch.Summary:
+		fmt.Printf("%s Run again with --details-for=PATH, or on one input, to see its issues\n", infoColor("Tip:"))
+	default:
+		printCorrectionTips()
+	}
+	opts.Webhook.exit(status)
+}
+
+// printDetails prints the full report for one input of a batch and
+// returns how many issues it showed
+func printDetails(input string, res batchResult, opts ValidationOptions) int {
+	if res.err != nil {
+		fmt.Printf("❌ Error reading file: %v\n", res.err)
+		return 0
+	}
+	if res.cached {
+		fmt.Printf("%s Unchanged since the last run; showing cached results\n", highlightColor("Note:"))
+	}
+	os.Stdout.Write(res.progress)
+	if len(res.errors) == 0 {
+		fmt.Println(successColor("✅ XML is well-formed!"))
+		return 0
+	}
+	return displayErrors(input, res.ix, res.errors, opts)
+}
+
+// printSummaryLine prints the --summary line for one input: how many
+// issues it has and the worst severity among them
+func printSummaryLine(input string, res batchResult) {
+	switch {
+	case res.err != nil:
+		fmt.Printf("%s %s: cannot read: %v\n", errorColor("❌"), input, res.err)
+	case len(res.errors) == 0:
+		fmt.Printf("%s %s: well-formed\n", successColor("✅"), input)
+	case countWarnings(res.errors) == len(res.errors):
+		fmt.Printf("%s %s: %d issues, worst: %s\n", highlightColor("⚠️"), input, len(res.errors), severityWarning)
+	default:
+		fmt.Printf("%s %s: %d issues, worst: %s\n", errorColor("❌"), input, len(res.errors), severityError)
+	}
+}
+
+// validateInputs validates the fetched inputs on the given number of
+// workers, taking them in input order, and returns a channel per input
+// that delivers its result. Progress lines are kept with each result so
+// inputs validated at the same time do not interleave their output.
+func validateInputs(inputs []string, fetched []chan batchInput, opts ValidationOptions, jobs int, cache *resultCache) []chan batchResult {
+	results := make([]chan batchResult, len(inputs))
+	for i := range results {
+		results[i] = make(chan batchResult, 1)
+	}
+
+	next := make(chan int)
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for i := range next {
+				res := batchResult{batchInput: <-fetched[i]}
+				if res.err == nil {
+					var progress bytes.Buffer
+					jobOpts := opts
+					jobOpts.Progress = &progress
+					if len(opts.Severities) > 0 || opts.DiffBase != "" {
+						// An error may follow the warnings that fill the
+						// report, or the issues on unchanged lines
+						jobOpts.MaxErrors = 0
+					}
+					res.ix = indexLines(res.content)
+					res.errors, res.cached = cache.lookup(inputs[i], res.content, jobOpts)
+					if !res.cached {
+						res.errors = validateXML(res.content, res.ix, jobOpts)
+						res.progress = progress.Bytes()
+						cache.store(inputs[i], res.content, jobOpts, res.errors)
+					}
+					applySeverities(res.errors, opts.Severities)
+					res.errors = onChangedLines(inputs[i], opts.DiffBase, res.errors)
+				}
+				results[i] <- res
+			}
+		}()
+	}
+	go func() {
+		for i := range inputs {
+			next <- i
+		}
+		close(next)
+	}()
+	return results
+}
+
+// fetchInputs starts fetching every input and returns a channel per input
+// that delivers it, and a function to call once an input has been
+// reported. Only a few inputs are fetched ahead of the ones reported, so
+// memory use does not grow with the length of the list.
+func fetchInputs(inputs []string, batch BatchOptions, jobs int) ([]chan batchInput, func()) {
+	workers := max(batch.DownloadConcurrency, 1)
+	results := make([]chan batchInput, len(inputs))
+	for i := range results {
+		results[i] = make(chan batchInput, 1)
+	}
+
+	hosts := make(map[string]chan struct{})
+	hostLimiters := make(map[string]*rateLimiter)
+	for _, input := range inputs {
+		if host := inputHost(input); host != "" && hosts[host] == nil {
+			hosts[host] = make(chan struct{}, max(batch.PerHost, 1))
+			hostLimiters[host] = newRateLimiter(batch.HostRateLimit)
+		}
+	}
+	limiter := newRateLimiter(batch.RateLimit)
+
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range next {
+				if content, ok := batch.Fetched[inputs[i]]; ok {
+					results[i] <- batchInput{content, nil}
+					continue
+				}
+				host := inputHost(inputs[i])
+				limit := hosts[host]
+				if limit != nil {
+					limit <- struct{}{}
+					hostLimiters[host].wait()
+					limiter.wait()
+				}
+				content, err := fetchInput(inputs[i])
+				if limit != nil {
+					<-limit
+				}
+				results[i] <- batchInput{content, err}
+			}
+		}()
+	}
+
+	// Stay at most two rounds of downloads or validations ahead of the
+	// report
+	ahead := make(chan struct{}, 2*max(workers, jobs))
+	go func() {
+		for i := range inputs {
+			ahead <- struct{}{}
+			next <- i
+		}
+		close(next)
+	}()
+
+	taken := func() { <-ahead }
+	return results, taken
+}
+
+// rateLimiter spaces events out to at most a given number per second. A
+// nil *rateLimiter does not limit.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newRateLimiter returns a limiter for perSecond events a second, or nil
+// when perSecond is not positive
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the next event may start
+func (l *rateLimiter) wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	at := l.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(time.Until(at))
+}
+
+// fetchInput reads a whole input without printing progress
+func fetchInput(input string) ([]byte, error) {
+	r, _, charset, err := openInput(input)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil && isRemote(input) {
+		return nil, &networkError{err}
+	}
+	if err == nil {
+		content, err = applyCharset(content, charset, input)
+	}
+	return content, err
+}
+
+// inputHost returns the host of a URL input, or "" for a local file
+func inputHost(input string) string {
+	if !isRemote(input) {
+		return ""
+	}
+	if u, err := url.Parse(input); err == nil {
+		return strings.ToLower(u.Host)
+	}
+	return ""
+}
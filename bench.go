@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// benchStats accumulates the cost of one rule over a benchmark run
+type benchStats struct {
+	name    string
+	elapsed time.Duration
+	allocs  uint64
+	bytes   uint64
+}
+
+// runBench implements the "bench" subcommand
+func runBench(args []string) {
+	opts := ValidationOptions{}
+	iterations := 3
+	bfs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	bfs.IntVar(&iterations, "iterations", iterations, "Number of times to validate the corpus")
+	bfs.StringVar(&opts.Profile, "profile", "", "Also time this document profile's checks")
+	bfs.StringVar(&opts.Target, "target", "", "Also time the checks for this import target (mysql-utf8mb3)")
+	bfs.BoolVar(&opts.CheckHTML, "check-html", false, "Also time the embedded HTML check")
+	bfs.BoolVar(&opts.CheckDesign, "check-design", false, "Also time the hex color, SVG and CSS checks")
+	bfs.BoolVar(&opts.CheckDates, "check-dates", false, "Also time the date format check")
+	parseFlags(bfs, args)
+
+	if bfs.NArg() < 1 || iterations < 1 {
+		fmt.Println("Usage: xml_validator bench [--iterations=N] [--profile=NAME] [--target=TARGET] [--check-html] [--check-design] [--check-dates] <dir>")
+		os.Exit(exitUsage)
+	}
+	if _, ok := profiles[opts.Profile]; opts.Profile != "" && !ok {
+		fmt.Fprintf(os.Stderr, "❌ Unknown profile %q\n", opts.Profile)
+		os.Exit(exitUsage)
+	}
+	opts.EmbeddedSVG = "xml"
+
+	corpus, size, err := loadCorpus(bfs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading corpus: %v\n", err)
+		os.Exit(exitIO)
+	}
+	if len(corpus) == 0 {
+		fmt.Fprintf(os.Stderr, "❌ No .xml files found in %s\n", bfs.Arg(0))
+		os.Exit(exitUsage)
+	}
+	fmt.Printf("Benchmarking %d files (%s), %d iterations\n", len(corpus), formatMB(size), iterations)
+
+	// The checks print progress lines; keep them out of the report
+	stdout := os.Stdout
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitIO)
+	}
+	os.Stdout = devNull
+	stats, total := benchCorpus(corpus, iterations, opts)
+	os.Stdout = stdout
+	devNull.Close()
+
+	processed := float64(size) * float64(iterations) / (1 << 20)
+	fmt.Printf("Total: %v, %.1f MB/s\n\n", total.Round(time.Millisecond), processed/total.Seconds())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Rule\tTime\tShare\tMB/s\tAllocs/MB\tBytes/MB\t")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%v\t%.1f%%\t%.1f\t%d\t%d\t\n",
+			s.name, s.elapsed.Round(time.Microsecond),
+			100*s.elapsed.Seconds()/total.Seconds(),
+			processed/s.elapsed.Seconds(),
+			uint64(float64(s.allocs)/processed), uint64(float64(s.bytes)/processed))
+	}
+	w.Flush()
+}
+
+// loadCorpus reads every .xml file under dir into memory so the benchmark
+// does not measure disk reads
+func loadCorpus(dir string) ([][]byte, int64, error) {
+	var corpus [][]byte
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".xml") {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		corpus = append(corpus, content)
+		size += int64(len(content))
+		return nil
+	})
+	return corpus, size, err
+}
+
+// benchCorpus validates the corpus the given number of times, timing the
+// well-formedness parse and each rule separately. Rules run one at a time
+// so their allocations can be told apart.
+func benchCorpus(corpus [][]byte, iterations int, opts ValidationOptions) ([]*benchStats, time.Duration) {
+	rules := validationRules(opts)
+	stats := []*benchStats{{name: "parse"}}
+	for _, r := range rules {
+		stats = append(stats, &benchStats{name: r.name})
+	}
+
+	measure := func(s *benchStats, f func()) {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		f()
+		s.elapsed += time.Since(start)
+		runtime.ReadMemStats(&after)
+		s.allocs += after.Mallocs - before.Mallocs
+		s.bytes += after.TotalAlloc - before.TotalAlloc
+	}
+
+	// Each document is indexed once, as validateXML does
+	indexes := make([]*lineIndex, len(corpus))
+	for i, content := range corpus {
+		indexes[i] = indexLines(content)
+	}
+
+	var total time.Duration
+	for i := 0; i < iterations; i++ {
+		for k, content := range corpus {
+			measure(stats[0], func() { validateBasicXML(content, indexes[k]) })
+			for j, r := range rules {
+				measure(stats[j+1], func() {
+					if r.line != nil {
+						scanLines(content, []rule{r}, nil, opts)
+					} else {
+						r.document(content, indexes[k], opts)
+					}
+				})
+			}
+		}
+	}
+	for _, s := range stats {
+		total += s.elapsed
+	}
+	return stats, total
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bump when checks change so results from older versions are not reused.
+// cacheKey also includes the build, so a new release starts afresh even
+// when this is forgotten.
+const cacheVersion = 3
+
+// cacheEntry is the stored result of validating one local file
+type cacheEntry struct {
+	ModTime time.Time         `json:"mod_time"`
+	Hash    string            `json:"hash"`
+	Options string            `json:"options"`
+	Errors  []ValidationError `json:"errors"`
+}
+
+// resultCache maps absolute paths to their last validation result
+type resultCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+// openResultCache loads the cache from the user cache directory. A
+// missing or unreadable cache starts empty; it is only an optimization.
+func openResultCache() *resultCache {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+	c := &resultCache{
+		path:    filepath.Join(dir, "xml-validator", "results.json"),
+		entries: make(map[string]cacheEntry),
+	}
+	var stored struct {
+		Version int                   `json:"version"`
+		Entries map[string]cacheEntry `json:"entries"`
+	}
+	if data, err := os.ReadFile(c.path); err == nil && json.Unmarshal(data, &stored) == nil && stored.Version == cacheVersion {
+		c.entries = stored.Entries
+	}
+	return c
+}
+
+// cacheKey identifies the build and the options that affect validation
+// results
+func cacheKey(opts ValidationOptions) string {
+	keyed := opts
+	keyed.Debug, keyed.Color, keyed.EmitFixes = false, false, ""
+	keyed.Quarantine, keyed.Cleaned = "", ""
+	keyed.Progress, keyed.Severities, keyed.FailOn = nil, nil, ""
+	keyed.ShowOffsets, keyed.ByteColumns, keyed.MaxAllowed = false, false, 0
+	data, _ := json.Marshal(keyed)
+	v, c, _ := buildInfo()
+	return v + " " + c + " " + string(data)
+}
+
+// lookup returns the stored errors for a file whose modification time,
+// content and options all match the last run
+func (c *resultCache) lookup(path string, content []byte, opts ValidationOptions) ([]ValidationError, bool) {
+	if c == nil || isRemote(path) {
+		return nil, false
+	}
+	abs, info, ok := statInput(path)
+	if !ok {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, found := c.entries[abs]
+	if !found || !entry.ModTime.Equal(info.ModTime()) || entry.Options != cacheKey(opts) || entry.Hash != contentHash(content) {
+		return nil, false
+	}
+	return entry.Errors, true
+}
+
+// store records the errors found in a local file
+func (c *resultCache) store(path string, content []byte, opts ValidationOptions, errs []ValidationError) {
+	if c == nil || isRemote(path) {
+		return
+	}
+	abs, info, ok := statInput(path)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[abs] = cacheEntry{ModTime: info.ModTime(), Hash: contentHash(content), Options: cacheKey(opts), Errors: errs}
+	c.dirty = true
+}
+
+// save writes the cache back, dropping entries for files that are gone
+func (c *resultCache) save() error {
+	if c == nil || !c.dirty {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path := range c.entries {
+		if _, err := os.Stat(path); err != nil {
+			delete(c.entries, path)
+		}
+	}
+	data, err := json.Marshal(map[string]any{"version": cacheVersion, "entries": c.entries})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
+
+// statInput returns the absolute path and file info of a local input
+func statInput(path string) (string, fs.FileInfo, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, false
+	}
+	info, err := os.Stat(abs)
+	return abs, info, err == nil
+}
+
+// contentHash returns the hex SHA-256 of content
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// expandInputs replaces directory inputs with the .xml files under them
+func expandInputs(inputs []string) ([]string, error) {
+	var expanded []string
+	for _, input := range inputs {
+		if info, err := os.Stat(input); isRemote(input) || err != nil || !info.IsDir() {
+			expanded = append(expanded, input)
+			continue
+		}
+		err := filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".xml") {
+				expanded = append(expanded, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return expanded, nil
+}
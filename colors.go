@@ -0,0 +1,107 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// reColorAttr matches markup attributes whose value is a color
+	reColorAttr = regexp.MustCompile(`(?i)\s(fill|stroke|stop-color|flood-color|lighting-color|color|bgcolor|text|link|vlink|alink)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+	// reCSSDeclaration matches a property and its value in CSS text
+	reCSSDeclaration = regexp.MustCompile(`(?i)(?:^|[;{\s])(--[\w-]+|[a-z-]+)\s*:\s*([^;{}]*)`)
+
+	// reCSSURLValue matches a url(...) inside a CSS value
+	reCSSURLValue = regexp.MustCompile(`(?i)url\([^)]*\)`)
+)
+
+// CSS properties whose values may contain colors
+var cssColorProperties = map[string]bool{
+	"color": true, "background": true, "background-color": true, "background-image": true,
+	"border": true, "border-color": true, "border-top": true, "border-right": true,
+	"border-bottom": true, "border-left": true, "border-top-color": true, "border-right-color": true,
+	"border-bottom-color": true, "border-left-color": true, "outline": true, "outline-color": true,
+	"fill": true, "stroke": true, "stop-color": true, "flood-color": true, "lighting-color": true,
+	"text-decoration": true, "text-decoration-color": true, "text-emphasis-color": true,
+	"box-shadow": true, "text-shadow": true, "column-rule": true, "column-rule-color": true,
+	"caret-color": true, "accent-color": true, "scrollbar-color": true,
+}
+
+// colorContexts returns the byte ranges of the document that hold color
+// values: color attributes such as fill="..." and the values of color
+// properties in style attributes and <style> elements. Hex checks only
+// look inside these, so fragment links (#section-7f) and hashes in URLs
+// are not mistaken for colors.
+func colorContexts(content []byte) [][2]int {
+	var ranges [][2]int
+
+	for _, m := range reColorAttr.FindAllSubmatchIndex(content, -1) {
+		if m[4] >= 0 {
+			ranges = append(ranges, [2]int{m[4], m[5]})
+		} else {
+			ranges = append(ranges, [2]int{m[6], m[7]})
+		}
+	}
+
+	addCSS := func(start, end int) {
+		css := content[start:end]
+		for _, m := range reCSSDeclaration.FindAllSubmatchIndex(css, -1) {
+			property := strings.ToLower(string(css[m[2]:m[3]]))
+			if cssColorProperties[property] || strings.HasPrefix(property, "--") {
+				ranges = append(ranges, [2]int{start + m[4], start + m[5]})
+			}
+		}
+	}
+	for _, m := range reStyleAttr.FindAllSubmatchIndex(content, -1) {
+		if m[2] >= 0 {
+			addCSS(m[2], m[3])
+		} else {
+			addCSS(m[4], m[5])
+		}
+	}
+	for _, m := range reStyleElement.FindAllIndex(content, -1) {
+		end := strings.Index(strings.ToLower(string(content[m[1]:])), "</style")
+		if end >= 0 {
+			addCSS(m[1], m[1]+end)
+		}
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	return ranges
+}
+
+// hexColorRun is a '#' followed by hex digits found in a color context
+type hexColorRun struct {
+	start, end int    // Byte range of the code including '#'
+	digits     string // The hex digits
+	trailing   bool   // Followed by a letter, digit or '-', so not a color
+}
+
+// findHexColors returns every hex code inside the document's color contexts
+func findHexColors(content []byte) []hexColorRun {
+	var runs []hexColorRun
+	for _, r := range colorContexts(content) {
+		value := content[r[0]:r[1]]
+		urls := reCSSURLValue.FindAllIndex(value, -1)
+	runs:
+		for _, m := range reHexRun.FindAllSubmatchIndex(value, -1) {
+			if r[0]+m[0] > 0 && content[r[0]+m[0]-1] == '&' {
+				continue // A character reference such as &#8217;
+			}
+			for _, u := range urls {
+				if m[0] >= u[0] && m[0] < u[1] {
+					continue runs // A fragment in url(image.svg#id)
+				}
+			}
+			runs = append(runs, hexColorRun{
+				start:    r[0] + m[0],
+				end:      r[0] + m[3],
+				digits:   string(value[m[2]:m[3]]),
+				trailing: m[5] > m[4],
+			})
+		}
+	}
+	return runs
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config file read from the current directory unless --config names another
+const defaultConfigFile = ".xml-validator.yml"
+
+// Severities an issue can have, from least to most serious
+const (
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+// Config holds the settings read from a config file, e.g.
+//
+//	severity:
+//	  hex-colors: warning
+//	  CDATA006: warning
+//	rules:
+//	  - name: no-lorem
+//	    literal: Lorem ipsum
+//	    message: Placeholder text left in the content
+type Config struct {
+	// Severity of the issues of a check (by name) or of one kind of
+	// issue (by rule ID, which takes precedence)
+	Severity map[string]string `yaml:"severity"`
+
+	// Checks to run besides the built-in ones, see CustomRule
+	Rules []CustomRule `yaml:"rules"`
+
+	// Rule profiles for --profile besides the built-in ones, see RuleProfile
+	Profiles map[string]RuleProfile `yaml:"profiles"`
+
+	// Elements the issues of a check (by name) are limited to, e.g.
+	// control-chars: [content:encoded] leaves control characters in other
+	// elements unreported
+	Scope map[string][]string `yaml:"scope"`
+}
+
+// loadConfig reads the config file at path. A missing default config file
+// is not an error; one named with --config (explicit) must exist.
+func loadConfig(path string, explicit bool) (Config, error) {
+	var config Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := compileCustomRules(config.Rules); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	custom := customRuleKeys(config.Rules)
+	if err := checkSeverities(config.Severity, custom); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	for check, elements := range config.Scope {
+		if !isCheckName(check) && !custom[check] {
+			return config, fmt.Errorf("%s: scope of unknown check %q", path, check)
+		}
+		if len(elements) == 0 || slices.Contains(elements, "") {
+			return config, fmt.Errorf("%s: scope of %s needs element names", path, check)
+		}
+	}
+	for name, p := range config.Profiles {
+		if isProfileName(name) {
+			return config, fmt.Errorf("%s: profile %q is built in; extend it under another name", path, name)
+		}
+		if _, ok := profiles[p.Document]; p.Document != "" && !ok {
+			return config, fmt.Errorf("%s: profile %s: unknown document profile %q", path, name, p.Document)
+		}
+		if p.FailOn != "" && p.FailOn != severityError && p.FailOn != severityWarning {
+			return config, fmt.Errorf("%s: profile %s: fail-on is %q, want error or warning", path, name, p.FailOn)
+		}
+		if err := checkSeverities(p.Severity, custom); err != nil {
+			return config, fmt.Errorf("%s: profile %s: %v", path, name, err)
+		}
+		if _, err := resolveRuleProfile(name, config.Profiles); err != nil {
+			return config, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	// A rule's own severity is overridden like a built-in check's default
+	for _, r := range config.Rules {
+		_, byID := config.Severity[r.ID]
+		_, byName := config.Severity[r.Name]
+		if r.Severity != "" && !byID && !byName {
+			if config.Severity == nil {
+				config.Severity = make(map[string]string)
+			}
+			config.Severity[r.Name] = r.Severity
+		}
+	}
+	return config, nil
+}
+
+// customRuleKeys returns the names and IDs of custom rules, which
+// severity overrides may refer to
+func customRuleKeys(rules []CustomRule) map[string]bool {
+	keys := make(map[string]bool)
+	for _, r := range rules {
+		keys[r.Name], keys[r.ID] = true, true
+	}
+	return keys
+}
+
+// parseSeverityMap parses a --severity-map file read from path, a policy
+// shared between projects that maps rule IDs or check names to
+// severities:
+//
+//	CDATA006: warning
+//	hex-colors: warning
+//
+// rules are the config file's own, which the map may also refer to.
+func parseSeverityMap(path string, data []byte, rules []CustomRule) (map[string]string, error) {
+	var severities map[string]string
+	if err := yaml.Unmarshal(data, &severities); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := checkSeverities(severities, customRuleKeys(rules)); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return severities, nil
+}
+
+// checkSeverities reports the first unknown check or rule ID, or invalid
+// severity, in severity overrides. custom holds the names and IDs of the
+// config file's own rules.
+func checkSeverities(overrides map[string]string, custom map[string]bool) error {
+	for key, severity := range overrides {
+		if !isRuleKey(key) && !custom[key] {
+			return fmt.Errorf("unknown check or rule ID %q", key)
+		}
+		if severity != severityError && severity != severityWarning {
+			return fmt.Errorf("severity of %s is %q, want error or warning", key, severity)
+		}
+	}
+	return nil
+}
+
+// isCheckName reports whether name is the name of a check, built in or
+// added with RegisterRule or --plugin
+func isCheckName(name string) bool {
+	for _, r := range ruleByID {
+		if r.check == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isRuleKey reports whether key names a check or a rule ID
+func isRuleKey(key string) bool {
+	if ruleByID[key] != nil {
+		return true
+	}
+	for _, r := range ruleCatalog {
+		if r.check == key {
+			return true
+		}
+	}
+	return false
+}
+
+// applySeverities sets the severity of each issue: error unless
+// overridden by its rule ID or the name of its check
+func applySeverities(errs []ValidationError, overrides map[string]string) {
+	for i := range errs {
+		severity := severityError
+		if r := ruleByType[errs[i].ErrorType]; r != nil {
+			if s, ok := overrides[r.id]; ok {
+				severity = s
+			} else if s, ok := overrides[r.check]; ok {
+				severity = s
+			}
+		}
+		errs[i].Severity = severity
+	}
+}
+
+// countFailing returns how many issues are at least as serious as failOn
+func countFailing(errs []ValidationError, failOn string) int {
+	n := 0
+	for _, e := range errs {
+		if e.Severity == severityError || failOn == severityWarning {
+			n++
+		}
+	}
+	return n
+}
+
+// overBudget reports whether n failing issues fail the run: any of them
+// without --max-allowed (maxAllowed 0), otherwise more than maxAllowed.
+// With a budget it also prints how n compares to it.
+func overBudget(n, maxAllowed int) bool {
+	switch {
+	case maxAllowed == 0:
+		return n > 0
+	case n > maxAllowed:
+		fmt.Printf("%s %d issues exceed the --max-allowed budget of %d\n", errorColor("❌"), n, maxAllowed)
+		return true
+	case n > 0:
+		fmt.Printf("%s %d issues are within the --max-allowed budget of %d\n", highlightColor("Note:"), n, maxAllowed)
+	}
+	return false
+}
+
+// countWarnings returns how many of errs are warnings
+func countWarnings(errs []ValidationError) int {
+	warnings := 0
+	for _, e := range errs {
+		if e.Severity == severityWarning {
+			warnings++
+		}
+	}
+	return warnings
+}
+
+// configExitCode returns the exit status for an error from loadConfig:
+// exitIO when the file could not be read, exitUsage when it is invalid
+func configExitCode(err error) int {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return exitIO
+	}
+	return exitUsage
+}
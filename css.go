@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// reStyleAttr matches style="..." and style='...' attributes
+	reStyleAttr = regexp.MustCompile(`(?i)\sstyle\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+	// reStyleElement matches the start tag of a <style> element
+	reStyleElement = regexp.MustCompile(`(?i)<style(\s[^>]*)?>`)
+
+	// reCSSProperty matches a property name, including custom properties
+	reCSSProperty = regexp.MustCompile(`^(--[\w-]+|-?[a-zA-Z_][\w-]*)$`)
+
+	// reCSSMissingSemicolon finds "name:" inside a value, which means the
+	// previous declaration was not terminated
+	reCSSMissingSemicolon = regexp.MustCompile(`\s(-?[a-zA-Z][\w-]*)\s*:`)
+)
+
+// At-rules whose block holds rules rather than declarations
+var cssNestedAtRules = map[string]bool{
+	"media": true, "supports": true, "document": true, "layer": true,
+	"container": true, "keyframes": true, "-webkit-keyframes": true, "scope": true,
+}
+
+// validateCSS checks the CSS in style attributes and <style> elements
+// anywhere in the document, in the XML tree or in HTML inside CDATA
+func validateCSS(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	report := func(offset int, problem string, highlight string) {
+		line, col, lineContent := findErrorPosition(ix, offset)
+		errors = append(errors, ValidationError{
+			LineNumber: line,
+			Column:     col,
+			Line:       lineContent,
+			ErrorType:  "CSS syntax error",
+			Message:    problem,
+			Content:    highlight,
+		})
+	}
+
+	for _, match := range reStyleAttr.FindAllSubmatchIndex(content, -1) {
+		start, end := match[2], match[3]
+		if start < 0 {
+			start, end = match[4], match[5]
+		}
+		css := cssText(content[start:end])
+		if at, problem := checkCSSDeclarations(css); at >= 0 {
+			report(start+at, "style attribute: "+problem, string(content[start:end]))
+		}
+		if opts.MaxErrors > 0 && len(errors) >= opts.MaxErrors {
+			return errors
+		}
+	}
+
+	for _, match := range reStyleElement.FindAllIndex(content, -1) {
+		start := match[1]
+		end := bytes.Index(bytes.ToLower(content[start:]), []byte("</style"))
+		if end < 0 {
+			continue // Reported as an unclosed element
+		}
+		css := cssText(content[start : start+end])
+		if at, problem := checkCSSStylesheet(css); at >= 0 {
+			report(start+at, "<style>: "+problem, "<style")
+		}
+		if opts.MaxErrors > 0 && len(errors) >= opts.MaxErrors {
+			return errors
+		}
+	}
+
+	return errors
+}
+
+// cssTextReplacer blanks out CDATA markers and replaces the common
+// character references by a character padded with spaces to the same
+// length, so offsets in the result match the document
+var cssTextReplacer = strings.NewReplacer(
+	"<![CDATA[", "         ",
+	"]]>", "   ",
+	"&quot;", `"     `,
+	"&apos;", "'     ",
+	"&amp;", "&    ",
+	"&gt;", ">   ",
+	"&lt;", "<   ",
+	"&#39;", "'    ",
+)
+
+// Closing bracket for each opening bracket in a CSS value
+var cssClosingBracket = map[byte]byte{'(': ')', '[': ']'}
+
+// cssText prepares raw CSS for checking without moving any offsets
+func cssText(raw []byte) string {
+	return cssTextReplacer.Replace(string(raw))
+}
+
+// checkCSSDeclarations checks a declaration list such as the value of a
+// style attribute. It returns the byte offset and description of the
+// first problem, or -1.
+func checkCSSDeclarations(css string) (int, string) {
+	at, problem, _ := scanCSSDeclarations(css, 0, false)
+	return at, problem
+}
+
+// checkCSSStylesheet checks the rules of a <style> element
+func checkCSSStylesheet(css string) (int, string) {
+	at, problem, _ := scanCSSRules(css, 0, false)
+	return at, problem
+}
+
+// scanCSSRules scans rules and at-rules from i until the end of css, or
+// until the closing brace when nested. It returns the first problem and
+// the offset just past the block.
+func scanCSSRules(css string, i int, nested bool) (int, string, int) {
+	for {
+		var problem string
+		if i, problem = skipCSSSpace(css, i); problem != "" {
+			return i, problem, i
+		}
+		if i == len(css) {
+			if nested {
+				return i, "missing } at the end of the block", i
+			}
+			return -1, "", i
+		}
+		if css[i] == '}' {
+			if nested {
+				return -1, "", i + 1
+			}
+			return i, "unexpected }", i
+		}
+
+		start := i
+		atRule := ""
+		if css[i] == '@' {
+			j := i + 1
+			for j < len(css) && (css[j] == '-' || css[j] >= 'a' && css[j] <= 'z' || css[j] >= 'A' && css[j] <= 'Z') {
+				j++
+			}
+			atRule = strings.ToLower(css[i+1 : j])
+		}
+
+		end, problem := scanCSSValue(css, i, "{;}")
+		if problem != "" {
+			return end, problem, end
+		}
+		prelude := strings.TrimSpace(css[start:end])
+		if end == len(css) || css[end] != '{' {
+			if atRule != "" && end < len(css) && css[end] == ';' {
+				i = end + 1 // @import, @charset, @namespace
+				continue
+			}
+			return start, fmt.Sprintf("%q is not followed by a { block", prelude), end
+		}
+		if prelude == "" {
+			return start, "rule has no selector", end
+		}
+
+		var at int
+		if cssNestedAtRules[atRule] {
+			at, problem, i = scanCSSRules(css, end+1, true)
+		} else {
+			at, problem, i = scanCSSDeclarations(css, end+1, true)
+		}
+		if at >= 0 {
+			return at, problem, i
+		}
+	}
+}
+
+// scanCSSDeclarations scans property: value pairs from i until the end
+// of css, or until the closing brace when nested
+func scanCSSDeclarations(css string, i int, nested bool) (int, string, int) {
+	for {
+		var problem string
+		if i, problem = skipCSSSpace(css, i); problem != "" {
+			return i, problem, i
+		}
+		switch {
+		case i == len(css) && nested:
+			return i, "missing } at the end of the block", i
+		case i == len(css):
+			return -1, "", i
+		case css[i] == '}' && nested:
+			return -1, "", i + 1
+		case css[i] == ';':
+			i++
+			continue
+		}
+
+		nameStart := i
+		for i < len(css) && css[i] != ':' && css[i] != ';' && css[i] != '}' && css[i] != ' ' && css[i] != '\t' && css[i] != '\n' && css[i] != '\r' {
+			i++
+		}
+		name := css[nameStart:i]
+		if !reCSSProperty.MatchString(name) {
+			if name == "" {
+				return nameStart, fmt.Sprintf("expected a property name, found %q", css[nameStart]), i
+			}
+			return nameStart, fmt.Sprintf("%q is not a valid property name", name), i
+		}
+		if i, problem = skipCSSSpace(css, i); problem != "" {
+			return i, problem, i
+		}
+		if i == len(css) || css[i] != ':' {
+			return nameStart, fmt.Sprintf("declaration %q is missing a colon", name), i
+		}
+
+		valueStart := i + 1
+		end, problem := scanCSSValue(css, valueStart, ";}")
+		if problem != "" {
+			return end, problem, end
+		}
+		value := css[valueStart:end]
+		if strings.TrimSpace(value) == "" {
+			return nameStart, fmt.Sprintf("%s has no value", name), end
+		}
+		if m := reCSSMissingSemicolon.FindStringSubmatchIndex(topLevelCSS(value)); m != nil && !strings.HasPrefix(name, "--") {
+			return valueStart + m[2], fmt.Sprintf("missing ; before %q; the %s declaration is not terminated", value[m[2]:m[3]], name), end
+		}
+		i = end
+	}
+}
+
+// scanCSSValue returns the offset of the first byte in stops found outside
+// strings, comments and brackets, or len(css). Unterminated strings and
+// comments and unbalanced brackets are reported.
+func scanCSSValue(css string, i int, stops string) (int, string) {
+	var brackets []byte
+	for i < len(css) {
+		c := css[i]
+		switch {
+		case c == '"' || c == '\'':
+			start := i
+			for i++; i < len(css) && css[i] != c; i++ {
+				if css[i] == '\\' {
+					i++
+				} else if css[i] == '\n' {
+					break
+				}
+			}
+			if i >= len(css) || css[i] != c {
+				return start, "unterminated string"
+			}
+		case c == '/' && strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				return i, "unterminated comment"
+			}
+			i += end + 3
+		case c == '(' || c == '[':
+			brackets = append(brackets, cssClosingBracket[c])
+		case c == ')' || c == ']':
+			if len(brackets) == 0 || brackets[len(brackets)-1] != c {
+				return i, fmt.Sprintf("unbalanced %q", c)
+			}
+			brackets = brackets[:len(brackets)-1]
+		case len(brackets) == 0 && strings.IndexByte(stops, c) >= 0:
+			return i, ""
+		}
+		i++
+	}
+	if len(brackets) > 0 {
+		return i, fmt.Sprintf("missing %q", brackets[len(brackets)-1])
+	}
+	return i, ""
+}
+
+// skipCSSSpace skips whitespace and comments
+func skipCSSSpace(css string, i int) (int, string) {
+	for i < len(css) {
+		switch {
+		case css[i] == ' ' || css[i] == '\t' || css[i] == '\n' || css[i] == '\r' || css[i] == '\f':
+			i++
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				return i, "unterminated comment"
+			}
+			i += end + 4
+		default:
+			return i, ""
+		}
+	}
+	return i, ""
+}
+
+// topLevelCSS blanks out strings, comments and bracketed parts of a value
+// so patterns only match its top level
+func topLevelCSS(value string) string {
+	b := []byte(value)
+	depth := 0
+	var quote byte
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			b[i] = ' '
+		case c == '"' || c == '\'':
+			quote = c
+			b[i] = ' '
+		case c == '(' || c == '[':
+			depth++
+			b[i] = ' '
+		case c == ')' || c == ']':
+			depth--
+			b[i] = ' '
+		case depth > 0:
+			b[i] = ' '
+		}
+	}
+	return string(b)
+}
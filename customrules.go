@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+
+	"github.com/antchfx/xpath"
+)
+
+// CustomRule is a pattern check or XPath assertion declared under "rules"
+// in the config file. Its matches are reported like those of the built-in
+// checks, and its name works with --checks, --disable and severity
+// overrides.
+//
+//	rules:
+//	  - name: no-lorem
+//	    id: ACME001
+//	    pattern: '(?i)lorem ipsum'
+//	    message: Placeholder text left in the content
+//	    severity: warning
+//	    element: content:encoded
+//	  - name: legacy-gallery
+//	    pattern: '\[gallery ids="([0-9,]+)"\]'
+//	    message: Legacy gallery shortcode
+//	    replacement: '<!-- wp:gallery {"ids":[$1]} /-->'
+//	  - name: item-guid
+//	    xpath: //item[not(guid)]
+//	    message: Item has no guid
+type CustomRule struct {
+	Name      string `yaml:"name"`      // Check name
+	ID        string `yaml:"id"`        // Rule ID of its errors; the name when empty
+	Pattern   string `yaml:"pattern"`   // Regular expression (RE2 syntax) to report
+	Literal   string `yaml:"literal"`   // Or plain text to report
+	XPath     string `yaml:"xpath"`     // Or an XPath 1.0 expression selecting the nodes to report
+	Message   string `yaml:"message"`   // Reported with each match
+	Severity  string `yaml:"severity"`  // error (the default) or warning
+	Element   string `yaml:"element"`   // Only report matches inside this element, e.g. content:encoded
+	Attribute string `yaml:"attribute"` // Only report matches in this attribute's values (on Element, if set)
+
+	// What the fix subcommand replaces each match with, also offered as
+	// its suggested fix; $1 or ${name} insert the pattern's groups
+	Replacement *string `yaml:"replacement"`
+
+	re *regexp.Regexp
+}
+
+// compileCustomRules checks the rules read from a config file and
+// compiles their patterns
+func compileCustomRules(rules []CustomRule) error {
+	used := make(map[string]bool)
+	for i := range rules {
+		r := &rules[i]
+		if r.ID == "" {
+			r.ID = r.Name
+		}
+		switch {
+		case r.Name == "":
+			return fmt.Errorf("rule %d has no name", i+1)
+		case used[r.Name] || r.Name == "basic" || isRuleKey(r.Name):
+			return fmt.Errorf("rule name %q is already used by another check", r.Name)
+		case used[r.ID] || ruleByID[r.ID] != nil:
+			return fmt.Errorf("rule ID %q is already used by another rule", r.ID)
+		case countNonEmpty(r.Pattern, r.Literal, r.XPath) != 1:
+			return fmt.Errorf("rule %s needs one of pattern, literal or xpath", r.Name)
+		case r.XPath != "" && (r.Element != "" || r.Attribute != ""):
+			return fmt.Errorf("rule %s: xpath selects its own nodes, so element and attribute do not apply", r.Name)
+		case r.XPath != "" && r.Replacement != nil:
+			return fmt.Errorf("rule %s: only pattern and literal rules can have a replacement", r.Name)
+		case r.Message == "":
+			return fmt.Errorf("rule %s has no message", r.Name)
+		case r.Severity != "" && r.Severity != severityError && r.Severity != severityWarning:
+			return fmt.Errorf("severity of rule %s is %q, want error or warning", r.Name, r.Severity)
+		}
+		used[r.Name], used[r.ID] = true, true
+
+		if r.XPath != "" {
+			if _, err := xpath.Compile(r.XPath); err != nil {
+				return fmt.Errorf("rule %s: invalid xpath %q: %v", r.Name, r.XPath, err)
+			}
+			continue
+		}
+		expr := r.Pattern
+		if r.Literal != "" {
+			expr = regexp.QuoteMeta(r.Literal)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("rule %s: %v", r.Name, err)
+		}
+		if re.MatchString("") {
+			return fmt.Errorf("rule %s: pattern %q matches empty text", r.Name, expr)
+		}
+		r.re = re
+	}
+	return nil
+}
+
+// countNonEmpty returns how many of values are not empty
+func countNonEmpty(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// registerCustomRules adds the rules to the rule catalog, so their errors
+// get rule IDs and severity overrides like those of the built-in checks
+func registerCustomRules(rules []CustomRule) {
+	for _, r := range rules {
+		info := &ruleInfo{
+			id:        r.ID,
+			errorType: r.errorType(),
+			check:     r.Name,
+			summary:   r.Message,
+		}
+		ruleByType[info.errorType] = info
+		ruleByID[info.id] = info
+	}
+}
+
+// errorType is the ErrorType the rule's errors are reported with
+func (r CustomRule) errorType() string {
+	return "Custom rule " + r.Name
+}
+
+// rule returns the rule run by the engine. Matches anywhere are found in
+// the shared line pass, so a pattern cannot span lines; matches in an
+// element or attribute need the token stream, and XPath assertions the
+// document tree, so they are found in a document pass.
+func (r CustomRule) rule() rule {
+	progress := fmt.Sprintf("Checking custom rule %s...", r.Name)
+	if r.XPath != "" {
+		return rule{name: r.Name, progress: progress, document: r.checkXPath}
+	}
+	if r.Element == "" && r.Attribute == "" {
+		return rule{name: r.Name, progress: progress, line: r.checkLine}
+	}
+	return rule{name: r.Name, progress: progress, document: r.checkDocument}
+}
+
+// checkLine reports every match in one line
+func (r CustomRule) checkLine(lineNum, lineStart int, line []byte) []ValidationError {
+	var errs []ValidationError
+	for _, m := range r.re.FindAllSubmatchIndex(line, -1) {
+		errs = append(errs, ValidationError{
+			LineNumber: lineNum,
+			Column:     m[0] + 1,
+			Line:       string(line),
+			ErrorType:  r.errorType(),
+			Message:    r.Message,
+			Content:    string(line[m[0]:m[1]]),
+			Fix:        r.fix(line, lineStart, m),
+		})
+	}
+	return errs
+}
+
+// fix returns the rule's correction of the match m in src, which starts
+// at offset in the document, or nil if it has no replacement
+func (r CustomRule) fix(src []byte, offset int, m []int) *SuggestedFix {
+	if r.Replacement == nil {
+		return nil
+	}
+	return &SuggestedFix{
+		Start:       offset + m[0],
+		End:         offset + m[1],
+		Replacement: string(r.re.Expand(nil, []byte(*r.Replacement), src, m)),
+	}
+}
+
+// checkDocument reports the matches inside r.Element, or in the values of
+// r.Attribute. The source text is matched, so entities are not decoded.
+func (r CustomRule) checkDocument(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	var errs []ValidationError
+	match := func(start, end int) {
+		for _, m := range r.re.FindAllSubmatchIndex(content[start:end], -1) {
+			if opts.MaxErrors > 0 && len(errs) >= opts.MaxErrors {
+				return
+			}
+			line := ix.lineAt(start + m[0])
+			errs = append(errs, ValidationError{
+				LineNumber: line,
+				Column:     start + m[0] - ix.lineStart(line) + 1,
+				Line:       string(ix.line(line)),
+				ErrorType:  r.errorType(),
+				Message:    r.Message,
+				Content:    string(content[start+m[0] : start+m[1]]),
+				Fix:        r.fix(content[start:end], start, m),
+			})
+		}
+	}
+
+	if r.Attribute == "" {
+		for _, span := range elementContents(content, r.Element) {
+			match(span[0], span[1])
+		}
+		return errs
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		before := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if err != nil {
+			return errs
+		}
+		t, ok := token.(xml.StartElement)
+		if !ok || r.Element != "" && rawName(t.Name) != r.Element {
+			continue
+		}
+		_, attrs, _, _ := parseSVGTag(content, before, int(decoder.InputOffset()))
+		for _, a := range attrs {
+			if a.name == r.Attribute && a.hasValue {
+				match(a.valueAt, a.valueAt+len(a.value))
+			}
+		}
+	}
+}
+
+// elementContents returns the byte ranges of the content of the elements
+// with the given prefixed name, e.g. content:encoded. An element nested in
+// one of the same name is part of the outer one's range.
+func elementContents(content []byte, name string) [][2]int {
+	var spans [][2]int
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	depth, inside := 0, 0 // Nesting of the element, and where the outermost one's content starts
+	for {
+		before := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if err != nil {
+			return spans
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if rawName(t.Name) == name {
+				if depth == 0 {
+					inside = int(decoder.InputOffset())
+				}
+				depth++
+			}
+		case xml.EndElement:
+			if depth > 0 && rawName(t.Name) == name {
+				if depth--; depth == 0 {
+					spans = append(spans, [2]int{inside, before})
+				}
+			}
+		}
+	}
+}
+
+// fixCustomRules replaces the matches of the rules that have a
+// replacement, one rule after the other, and returns the result together
+// with the number of replacements made
+func fixCustomRules(content []byte, rules []CustomRule) ([]byte, int) {
+	total := 0
+	for _, r := range rules {
+		if r.Replacement == nil {
+			continue
+		}
+		var errs []ValidationError
+		ix := indexLines(content) // Of the content the last rule left
+		if r.Element != "" || r.Attribute != "" {
+			errs = r.checkDocument(content, ix, ValidationOptions{})
+		} else {
+			for n := 1; n <= ix.lines(); n++ {
+				errs = append(errs, r.checkLine(n, ix.lineStart(n), ix.line(n))...)
+			}
+		}
+		fixes := make([]SuggestedFix, len(errs))
+		for i, e := range errs {
+			fixes[i] = *e.Fix
+		}
+		content = applySuggestedFixes(content, fixes)
+		total += len(fixes)
+	}
+	return content, total
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dateFormat describes one of the date syntaxes used by feed vocabularies
+type dateFormat struct {
+	description string
+	example     string
+	valid       func(value string) bool
+}
+
+// Date formats selectable by name in --date-format
+var dateFormats = map[string]dateFormat{
+	"rfc822": {"an RFC 822 date", "Mon, 02 Jan 2006 15:04:05 +0000",
+		func(v string) bool { _, ok := parseRFC822(v); return ok }},
+	"rfc3339": {"an RFC 3339 date", "2006-01-02T15:04:05Z",
+		func(v string) bool { _, ok := parseRFC3339(v); return ok }},
+	"w3c": {"a W3C Datetime (ISO 8601)", "2006-01-02T15:04:05+00:00",
+		func(v string) bool { _, ok := parseW3CDatetime(v); return ok }},
+	"wordpress": {"a WordPress date", "2006-01-02 15:04:05",
+		validWordPressDate},
+}
+
+// dateElement maps an element to the date format its vocabulary requires.
+// space is matched as a prefix of the element's namespace URI so every WXR
+// version is covered by one entry; an empty space only matches elements
+// without a namespace unless anySpace is set.
+type dateElement struct {
+	space    string
+	local    string
+	label    string
+	format   string
+	anySpace bool
+}
+
+// Date-bearing elements checked by --check-dates
+var dateElements = []dateElement{
+	{"", "pubDate", "pubDate", "rfc822", false},
+	{"", "lastBuildDate", "lastBuildDate", "rfc822", false},
+	{atomNamespace, "updated", "updated", "rfc3339", false},
+	{atomNamespace, "published", "published", "rfc3339", false},
+	{dcNamespace, "date", "dc:date", "w3c", false},
+	{sitemapNamespace, "lastmod", "lastmod", "w3c", false},
+	{wpNamespacePrefix, "post_date", "wp:post_date", "wordpress", false},
+	{wpNamespacePrefix, "post_date_gmt", "wp:post_date_gmt", "wordpress", false},
+	{wpNamespacePrefix, "comment_date", "wp:comment_date", "wordpress", false},
+	{wpNamespacePrefix, "comment_date_gmt", "wp:comment_date_gmt", "wordpress", false},
+}
+
+// dateElementFlags collects repeated --date-format=ELEMENT=FORMAT values
+type dateElementFlags []dateElement
+
+func (f *dateElementFlags) String() string {
+	var parts []string
+	for _, e := range *f {
+		parts = append(parts, e.local+"="+e.format)
+	}
+	return strings.Join(parts, ",")
+}
+
+// MarshalJSON writes the rules as the flag values, so that they are part
+// of the result cache's key (see cacheKey)
+func (f dateElementFlags) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.String())
+}
+
+func (f *dateElementFlags) Set(value string) error {
+	local, format, ok := strings.Cut(value, "=")
+	if !ok || local == "" {
+		return fmt.Errorf("want ELEMENT=FORMAT, got %q", value)
+	}
+	if _, known := dateFormats[format]; !known {
+		return fmt.Errorf("unknown date format %q (want rfc822, rfc3339, w3c or wordpress)", format)
+	}
+	*f = append(*f, dateElement{local: local, label: local, format: format, anySpace: true})
+	return nil
+}
+
+// validWordPressDate accepts the MySQL DATETIME values WXR uses, including
+// the all-zero value WordPress writes for unpublished drafts
+func validWordPressDate(value string) bool {
+	if value == "0000-00-00 00:00:00" {
+		return true
+	}
+	_, err := time.Parse("2006-01-02 15:04:05", value)
+	return err == nil
+}
+
+// checkDateNode returns an error for n if its text is not in the named
+// format, or nil when the date is valid
+func checkDateNode(ix *lineIndex, n *xmlNode, label, format string) *ValidationError {
+	f := dateFormats[format]
+	if f.valid(n.trimmedText()) {
+		return nil
+	}
+	err := nodeError(ix, n, "Invalid date",
+		fmt.Sprintf("<%s> %q is not %s (expected e.g. %q)", label, n.trimmedText(), f.description, f.example))
+	return &err
+}
+
+// validateDates checks every known date-bearing element in the document,
+// plus any configured with --date-format
+func validateDates(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	root, err := parseTree(content)
+	if err != nil || root == nil {
+		return errors // Reported by validateBasicXML
+	}
+
+	// Configured elements come first so they override the built-in table
+	elements := append(append([]dateElement{}, opts.DateElements...), dateElements...)
+
+	var walk func(n *xmlNode)
+	walk = func(n *xmlNode) {
+		for _, e := range elements {
+			if e.local != n.Name.Local {
+				continue
+			}
+			if !e.anySpace && (e.space == "" && n.Name.Space != "" || !strings.HasPrefix(n.Name.Space, e.space)) {
+				continue
+			}
+			if dateErr := checkDateNode(ix, n, e.label, e.format); dateErr != nil {
+				errors = append(errors, *dateErr)
+			}
+			break
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	return errors
+}
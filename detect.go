@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// Document types validate recognizes from the root element when no
+// --profile is given, with how they are described when detected
+var documentTypes = map[string]string{
+	"wxr":     "a WordPress export (WXR)",
+	"rss":     "an RSS feed",
+	"atom":    "an Atom feed",
+	"sitemap": "a sitemap",
+	"svg":     "an SVG image",
+}
+
+// detectDocumentType returns the type of document the root element of
+// content names: an <rss> declaring the wp: namespace is a WordPress
+// export, <feed> Atom, <urlset> or <sitemapindex> a sitemap. It returns ""
+// for anything else, including content it cannot parse up to the root.
+func detectDocumentType(content []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return ""
+		}
+		root, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch root.Name.Local {
+		case "rss":
+			for _, a := range root.Attr {
+				if a.Name.Space == "xmlns" && (a.Name.Local == "wp" || strings.HasPrefix(a.Value, "http://wordpress.org/export/")) {
+					return "wxr"
+				}
+			}
+			return "rss"
+		case "feed":
+			return "atom"
+		case "urlset", "sitemapindex":
+			return "sitemap"
+		case "svg":
+			return "svg"
+		}
+		return ""
+	}
+}
+
+// withDocumentType returns opts with the checks for a detected document
+// type enabled: its profile, or the styling checks for SVG
+func (opts ValidationOptions) withDocumentType(kind string) ValidationOptions {
+	if kind == "svg" {
+		opts.CheckDesign = true
+	} else {
+		opts.Profile = kind
+	}
+	return opts
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// checkDiffBase reports whether base names a commit of the git
+// repository in the working directory
+func checkDiffBase(base string) error {
+	out, err := exec.Command("git", "rev-parse", "--verify", "--quiet", base+"^{commit}").Output()
+	if err != nil || len(bytes.TrimSpace(out)) == 0 {
+		return fmt.Errorf("%q is not a revision of a git repository here", base)
+	}
+	return nil
+}
+
+// changedLines returns the lines of the file at path that were added or
+// modified since base, a git revision, as git diff finds them: the
+// working tree file, committed or not, against its version at base. all
+// is true for a file that did not exist at base, e.g. an untracked one.
+func changedLines(path, base string) (lines map[int]bool, all bool, err error) {
+	dir, name := filepath.Dir(path), "./"+filepath.Base(path)
+	// The file may be outside any repository, or in another one than the
+	// working directory's
+	verify := exec.Command("git", "rev-parse", "--verify", "--quiet", base+"^{commit}")
+	verify.Dir = dir
+	if out, err := verify.Output(); err != nil || len(bytes.TrimSpace(out)) == 0 {
+		return nil, false, fmt.Errorf("%s: %q is not a revision of the git repository the file is in", path, base)
+	}
+	tree := exec.Command("git", "ls-tree", "--name-only", base, "--", name)
+	tree.Dir = dir
+	listed, err := tree.Output()
+	if err != nil {
+		return nil, false, fmt.Errorf("git ls-tree %s: %v", path, err)
+	}
+	if len(bytes.TrimSpace(listed)) == 0 {
+		return nil, true, nil
+	}
+
+	diff := exec.Command("git", "diff", "--no-color", "--no-ext-diff", "--unified=0", base, "--", name)
+	diff.Dir = dir
+	out, err := diff.Output()
+	if err != nil {
+		return nil, false, fmt.Errorf("git diff %s: %v", path, err)
+	}
+	lines = make(map[int]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// e.g. "@@ -12,3 +12,4 @@": four lines from line 12 in the new file
+		hunk, ok := strings.CutPrefix(scanner.Text(), "@@ -")
+		if !ok {
+			continue
+		}
+		_, added, ok := strings.Cut(hunk, " +")
+		if !ok {
+			continue
+		}
+		added, _, _ = strings.Cut(added, " ")
+		startText, countText, hasCount := strings.Cut(added, ",")
+		start, err := strconv.Atoi(startText)
+		if err != nil {
+			continue
+		}
+		count := 1
+		if hasCount {
+			if count, err = strconv.Atoi(countText); err != nil {
+				continue
+			}
+		}
+		for line := start; line < start+count; line++ {
+			lines[line] = true
+		}
+	}
+	return lines, false, nil
+}
+
+// onChangedLines returns the errors of the local file at path that are on
+// lines changed since base, and those without a line. URL inputs and
+// files git cannot compare keep all their errors, with a warning.
+func onChangedLines(path, base string, errs []ValidationError) []ValidationError {
+	if base == "" || isRemote(path) {
+		return errs
+	}
+	lines, all, err := changedLines(path, base)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v; reporting every issue\n", highlightColor("Warning:"), err)
+		return errs
+	}
+	if all {
+		return errs
+	}
+	var kept []ValidationError
+	for _, e := range errs {
+		if e.LineNumber == 0 || lines[e.LineNumber] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
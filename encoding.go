@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var (
+	reXMLDeclaration = regexp.MustCompile(`^<\?xml\s[^?]*\?>`)
+	reDeclEncoding   = regexp.MustCompile(`(\sencoding\s*=\s*)(["'])([^"']*)["']`)
+	reDeclVersion    = regexp.MustCompile(`(version\s*=\s*["'][^"']*["'])`)
+)
+
+// Byte order marks recognised at the start of a document
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// detectEncoding returns the name of the encoding content is stored in,
+// looking at the byte order mark first and then the XML declaration.
+// Documents without either are UTF-8 as the XML spec requires.
+func detectEncoding(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, bomUTF8):
+		return "UTF-8"
+	case bytes.HasPrefix(content, bomUTF16LE):
+		return "UTF-16LE"
+	case bytes.HasPrefix(content, bomUTF16BE):
+		return "UTF-16BE"
+	}
+
+	if decl := reXMLDeclaration.Find(content); decl != nil {
+		if m := reDeclEncoding.FindSubmatch(decl); m != nil {
+			return string(m[3])
+		}
+	}
+	return "UTF-8"
+}
+
+// lookupEncoding resolves an encoding name, treating the UTF-16 family
+// specially so output always carries a byte order mark
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(name) {
+	case "utf-8", "utf8":
+		return unicode.UTF8, nil
+	case "utf-16", "utf-16le":
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), nil
+	case "utf-16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), nil
+	}
+
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("unsupported encoding %q", name)
+	}
+	return enc, nil
+}
+
+// decodeToUTF8 converts content from its detected encoding to UTF-8 and
+// strips any byte order mark
+func decodeToUTF8(content []byte) ([]byte, error) {
+	name := detectEncoding(content)
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s input: %v", name, err)
+	}
+	return bytes.TrimPrefix(decoded, bomUTF8), nil
+}
+
+// encodeFromUTF8 converts UTF-8 content to the named encoding, rewriting
+// the XML declaration so it matches the bytes that follow it
+func encodeFromUTF8(content []byte, name string) ([]byte, error) {
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+
+	content = setDeclaredEncoding(content, canonicalEncodingName(name))
+	encoded, err := enc.NewEncoder().Bytes(content)
+	if err != nil {
+		return nil, fmt.Errorf("encoding output as %s: %v", name, err)
+	}
+	return encoded, nil
+}
+
+// canonicalEncodingName returns the spelling used in XML declarations.
+// Endianness is carried by the byte order mark, so UTF-16LE/BE are both
+// declared as UTF-16.
+func canonicalEncodingName(name string) string {
+	upper := strings.ToUpper(name)
+	switch upper {
+	case "UTF8":
+		return "UTF-8"
+	case "UTF-16LE", "UTF-16BE":
+		return "UTF-16"
+	}
+	return upper
+}
+
+// setDeclaredEncoding updates (or adds) the encoding pseudo-attribute of
+// the XML declaration. A declaration is inserted when the document has
+// none, since non-UTF-8 documents are required to declare their encoding.
+func setDeclaredEncoding(content []byte, name string) []byte {
+	decl := reXMLDeclaration.Find(content)
+	if decl == nil {
+		header := fmt.Sprintf(`<?xml version="1.0" encoding="%s"?>`+"\n", name)
+		return append([]byte(header), content...)
+	}
+
+	var newDecl []byte
+	if reDeclEncoding.Match(decl) {
+		newDecl = reDeclEncoding.ReplaceAll(decl, []byte(`${1}${2}`+name+`${2}`))
+	} else {
+		// The encoding must follow version, so place it right after it
+		newDecl = reDeclVersion.ReplaceAll(decl, []byte(`${1} encoding="`+name+`"`))
+	}
+
+	return append(newDecl, content[len(decl):]...)
+}
+
+// sameEncoding reports whether two encoding names, e.g. from a
+// Content-Type header and an XML declaration, mean the same encoding
+func sameEncoding(a, b string) bool {
+	if canonicalEncodingName(a) == canonicalEncodingName(b) {
+		return true
+	}
+	ea, errA := ianaindex.IANA.Encoding(a)
+	eb, errB := ianaindex.IANA.Encoding(b)
+	return errA == nil && errB == nil && ea != nil && ea == eb
+}
+
+// applyCharset converts a download to UTF-8 from the charset of its
+// Content-Type header, which takes precedence over the XML declaration
+// (RFC 7303) but not over a byte order mark. A declared encoding is
+// rewritten to UTF-8 so the parser does not decode the content again; a
+// conflict between the two is reported as a warning.
+func applyCharset(content []byte, charset, source string) ([]byte, error) {
+	if charset == "" || bytes.HasPrefix(content, bomUTF8) ||
+		bytes.HasPrefix(content, bomUTF16LE) || bytes.HasPrefix(content, bomUTF16BE) {
+		return content, nil
+	}
+	enc, err := lookupEncoding(charset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: ignoring Content-Type charset: %v\n", highlightColor("Warning:"), source, err)
+		return content, nil
+	}
+
+	declared := ""
+	if decl := reXMLDeclaration.Find(content); decl != nil {
+		if m := reDeclEncoding.FindSubmatch(decl); m != nil {
+			declared = string(m[3])
+		}
+	}
+	if declared != "" && !sameEncoding(declared, charset) {
+		fmt.Fprintf(os.Stderr, "%s %s: Content-Type says charset=%s but the XML declaration says %s; decoding as %s\n",
+			highlightColor("Warning:"), source, charset, declared, charset)
+	}
+
+	if enc != unicode.UTF8 {
+		content, err = enc.NewDecoder().Bytes(content)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s input: %v", charset, err)
+		}
+	}
+	if declared != "" && canonicalEncodingName(declared) != "UTF-8" {
+		content = setDeclaredEncoding(content, "UTF-8")
+	}
+	return content, nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rule is one check run by validateXML. Line rules look at one line at a
+// time and all of them share a single pass over the document; document
+// rules need the whole content, e.g. to parse a tree. A line rule can keep
+// state from line to line, e.g. to follow CDATA sections across lines,
+// and report what is left at the end of the pass.
+type rule struct {
+	name     string // Short identifier, e.g. "cdata"
+	progress string // Printed before the rule runs
+	line     func(lineNum, lineStart int, line []byte) []ValidationError
+	end      func() []ValidationError // Called after the last line, for a line rule with state
+	document func(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError
+	dedupe   bool // Drop errors an earlier rule already reported at the same position
+}
+
+// validationRules returns the rules enabled by opts, in report order
+func validationRules(opts ValidationOptions) []rule {
+	cdata := newCDATAScanner()
+	rules := []rule{
+		{name: "cdata", progress: "Checking CDATA sections...", line: cdata.line, end: cdata.end},
+		{name: "control-chars", progress: "Checking for control characters...", line: checkControlCharacterLine},
+	}
+	// Characters a utf8mb3 database cannot store
+	if opts.Target == "mysql-utf8mb3" {
+		rules = append(rules, rule{name: "utf8mb3", progress: "Checking for 4-byte UTF-8 characters...", line: checkUTF8MB3Line})
+	}
+	// Styling checks, which matter for SVG and HTML content but are noise
+	// on most other XML
+	if opts.CheckDesign {
+		rules = append(rules,
+			rule{name: "hex-colors", progress: "Checking hex color codes...", document: validateHexColors},
+			rule{name: "svg", progress: "Checking SVG syntax...", document: validateSVG},
+			rule{name: "css", progress: "Checking CSS syntax...", document: validateCSS},
+		)
+	}
+	if check, ok := profiles[opts.Profile]; ok {
+		rules = append(rules, rule{name: opts.Profile, progress: fmt.Sprintf("Checking %s structure...", opts.Profile), document: check})
+	}
+	if opts.CheckHTML {
+		rules = append(rules, rule{name: "html", progress: "Checking HTML inside CDATA sections...", document: validateEmbeddedHTML})
+	}
+	// A profile and --check-dates can both check the same pubDate
+	if opts.CheckDates {
+		rules = append(rules, rule{name: "dates", progress: "Checking date formats...", document: validateDates, dedupe: true})
+	}
+	for _, r := range registeredRules {
+		rules = append(rules, registeredRule(r)...)
+	}
+	for _, cr := range opts.CustomRules {
+		rules = append(rules, cr.rule())
+	}
+	for _, p := range opts.Plugins {
+		rules = append(rules, p.rule())
+	}
+	return slices.DeleteFunc(rules, func(r rule) bool { return opts.Skip[r.name] })
+}
+
+// skippedChecks returns the checks to leave out so that only those listed
+// in only run (every enabled check when it is empty), less those listed in
+// disable. "basic" names the well-formedness check. Naming a check that
+// does not exist, or an optional one that opts do not enable, is an error.
+func skippedChecks(opts ValidationOptions, only, disable []string) (map[string]bool, error) {
+	enabled := map[string]bool{"basic": true}
+	for _, r := range validationRules(opts) {
+		enabled[r.name] = true
+	}
+	if opts.Detect {
+		// Checks a detected document type may turn on
+		for kind := range documentTypes {
+			for _, r := range validationRules(opts.withDocumentType(kind)) {
+				enabled[r.name] = true
+			}
+		}
+	}
+	for _, name := range append(slices.Clone(only), disable...) {
+		if enabled[name] {
+			continue
+		}
+		for _, s := range optionalRules() {
+			for _, r := range validationRules(s.opts) {
+				if r.name == name {
+					return nil, fmt.Errorf("check %q is not enabled; it needs %s", name, s.flag)
+				}
+			}
+		}
+		return nil, fmt.Errorf("unknown check %q (run \"xml_validator rules\" for the list)", name)
+	}
+
+	skip := make(map[string]bool)
+	for name := range enabled {
+		if len(only) > 0 && !slices.Contains(only, name) {
+			skip[name] = true
+		}
+	}
+	for _, name := range disable {
+		skip[name] = true
+	}
+	return skip, nil
+}
+
+// runRules runs rules over content, whose line index is ix, and returns
+// their errors ordered by position. The rules only read content and ix, so
+// the shared line pass and each document rule run in their own goroutine.
+func runRules(content []byte, ix *lineIndex, rules []rule, opts ValidationOptions) []ValidationError {
+	rules = scopeRules(content, ix, rules, opts.Scopes)
+	for _, r := range rules {
+		fmt.Fprintln(opts.progressOut(), infoColor(r.progress))
+	}
+
+	// Each rule reads the whole document once, the line rules together
+	bar := newProgressBar("Validating", int64(len(content)), int64(len(rules)))
+	results := make([][]ValidationError, len(rules))
+	var lineNames []string
+	for _, r := range rules {
+		if r.line != nil {
+			lineNames = append(lineNames, r.name)
+		}
+	}
+	var lineCrash []ValidationError
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer recoverRule(ix, strings.Join(lineNames, "/"), &lineCrash)
+		for i, errs := range scanLines(content, rules, bar, opts) {
+			if rules[i].line != nil {
+				results[i] = errs
+			}
+		}
+	}()
+	for i, r := range rules {
+		if r.document == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer recoverRule(ix, r.name, &results[i])
+			start := time.Now()
+			results[i] = r.document(content, ix, opts)
+			opts.Timings.since(r.name, start)
+			bar.add(int64(len(content)))
+		}()
+	}
+	wg.Wait()
+	bar.finish()
+
+	allErrors := lineCrash
+	for i, r := range rules {
+		if r.dedupe {
+			allErrors = appendNewErrors(allErrors, results[i])
+		} else {
+			allErrors = append(allErrors, results[i]...)
+		}
+	}
+	sortByPosition(allErrors)
+
+	if opts.MaxErrors > 0 && len(allErrors) > opts.MaxErrors {
+		return allErrors[:opts.MaxErrors]
+	}
+	return allErrors
+}
+
+// recoverRule, deferred in the goroutine running the named rules, turns
+// a panic into an issue in *errs, so that a document that trips a bug is
+// reported instead of ending the process, which would take every other
+// request to serve with it
+func recoverRule(ix *lineIndex, name string, errs *[]ValidationError) {
+	if p := recover(); p != nil {
+		*errs = []ValidationError{{
+			LineNumber: 1,
+			Column:     1,
+			Line:       string(ix.line(1)),
+			ErrorType:  "Internal error",
+			Message:    fmt.Sprintf("The %s check crashed on this document: %v", name, p),
+		}}
+	}
+}
+
+// scopeRules returns rules with those that scopes limits to some elements
+// wrapped, so that they only report issues inside those elements' content
+func scopeRules(content []byte, ix *lineIndex, rules []rule, scopes map[string][]string) []rule {
+	if len(scopes) == 0 {
+		return rules
+	}
+	rules = slices.Clone(rules)
+	spans := make(map[string][][2]int) // Content of each element, found once
+	for i, r := range rules {
+		elements, ok := scopes[r.name]
+		if !ok {
+			continue
+		}
+		var ranges [][2]int
+		for _, element := range elements {
+			if _, found := spans[element]; !found {
+				spans[element] = elementContents(content, element)
+			}
+			ranges = append(ranges, spans[element]...)
+		}
+		ranges = mergeRanges(ranges)
+		outside := func(offset int) bool {
+			// The last range that starts at or before offset
+			i, found := slices.BinarySearchFunc(ranges, offset, func(r [2]int, offset int) int { return cmp.Compare(r[0], offset) })
+			if !found {
+				i--
+			}
+			return i < 0 || offset >= ranges[i][1]
+		}
+
+		if line := r.line; line != nil {
+			rules[i].line = func(lineNum, lineStart int, text []byte) []ValidationError {
+				return slices.DeleteFunc(line(lineNum, lineStart, text), func(e ValidationError) bool {
+					return outside(lineStart + max(e.Column, 1) - 1)
+				})
+			}
+		}
+		if end := r.end; end != nil {
+			rules[i].end = func() []ValidationError {
+				return slices.DeleteFunc(end(), func(e ValidationError) bool {
+					return outside(ix.offset(e.LineNumber, e.Column))
+				})
+			}
+		}
+		if document := r.document; document != nil {
+			rules[i].document = func(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+				return slices.DeleteFunc(document(content, ix, opts), func(e ValidationError) bool {
+					return outside(ix.offset(e.LineNumber, e.Column))
+				})
+			}
+		}
+	}
+	return rules
+}
+
+// mergeRanges sorts byte ranges and joins those that overlap, e.g. the
+// content of nested elements
+func mergeRanges(ranges [][2]int) [][2]int {
+	slices.SortFunc(ranges, func(a, b [2]int) int { return cmp.Compare(a[0], b[0]) })
+	var merged [][2]int
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// sortByPosition orders errors by line and column. Errors at the same
+// position keep the order of their rules.
+func sortByPosition(errs []ValidationError) {
+	slices.SortStableFunc(errs, func(a, b ValidationError) int {
+		return cmp.Or(cmp.Compare(a.LineNumber, b.LineNumber), cmp.Compare(a.Column, b.Column))
+	})
+}
+
+// scanLines feeds every line of content to all line rules in one pass and
+// returns each rule's errors by index. Lines are slices of content, so
+// nothing is copied unless a rule reports an error. A rule stops receiving
+// lines once it has reported opts.MaxErrors errors; the pass ends when
+// every rule has. Rules still running get their end call after it. Progress is added to bar every megabyte.
+func scanLines(content []byte, rules []rule, bar *progressBar, opts ValidationOptions) [][]ValidationError {
+	results := make([][]ValidationError, len(rules))
+	done := make([]bool, len(rules))
+	active := 0
+	for i, r := range rules {
+		if r.line == nil {
+			done[i] = true
+			continue
+		}
+		active++
+	}
+	lineRules, reported := active, 0
+	var elapsed []time.Duration // Time in each rule, with --timings
+	if opts.Timings != nil {
+		elapsed = make([]time.Duration, len(rules))
+		defer func() {
+			for i, d := range elapsed {
+				if rules[i].line != nil {
+					opts.Timings.add(rules[i].name, d)
+				}
+			}
+		}()
+	}
+
+	for lineNum, start := 1, 0; active > 0 && start <= len(content); lineNum++ {
+		end := bytes.IndexByte(content[start:], '\n')
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += start
+		}
+		line := content[start:end]
+
+		for i, r := range rules {
+			if done[i] {
+				continue
+			}
+			var t time.Time
+			if elapsed != nil {
+				t = time.Now()
+			}
+			results[i] = append(results[i], r.line(lineNum, start, line)...)
+			if elapsed != nil {
+				elapsed[i] += time.Since(t)
+			}
+			if opts.MaxErrors > 0 && len(results[i]) >= opts.MaxErrors {
+				done[i] = true
+				active--
+			}
+		}
+		if start>>20 != (end+1)>>20 {
+			bar.add(int64(end+1-reported) * int64(lineRules))
+			reported = end + 1
+		}
+		start = end + 1
+	}
+	bar.add(int64(len(content)-reported) * int64(lineRules))
+
+	for i, r := range rules {
+		if !done[i] && r.end != nil {
+			results[i] = append(results[i], r.end()...)
+		}
+	}
+	return results
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// formattedError is what an --error-format template is executed with
+type formattedError struct {
+	File     string // Input path or URL
+	Line     int
+	Col      int
+	Offset   int    // Byte offset in the document
+	Rule     string // Rule ID, e.g. CDATA003
+	Type     string // Error type, e.g. "CDATA Error"
+	Severity string // error or warning
+	Message  string
+	Content  string // The offending text
+	Fix      string // Replacement text of the suggested fix, if any
+}
+
+// parseErrorFormat compiles an --error-format template. Each issue is
+// printed on its own line, so a trailing newline is added when missing.
+// The template is tried on an empty issue so that unknown fields are
+// reported before any input is validated.
+func parseErrorFormat(format string) (*template.Template, error) {
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	tmpl, err := template.New("error-format").Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, formattedError{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// printFormattedErrors prints up to opts.MaxErrors issues (all when 0)
+// with the --error-format template and returns how many it printed
+func printFormattedErrors(input string, ix *lineIndex, errs []ValidationError, opts ValidationOptions) int {
+	max := opts.MaxErrors
+	if max == 0 || max > len(errs) {
+		max = len(errs)
+	}
+	for _, e := range errs[:max] {
+		severity := e.Severity
+		if severity == "" {
+			severity = severityError
+		}
+		record := formattedError{
+			File:     input,
+			Line:     e.LineNumber,
+			Col:      errorColumn(ix, e, opts.ByteColumns),
+			Offset:   e.Offset,
+			Rule:     e.ruleID(),
+			Type:     e.ErrorType,
+			Severity: severity,
+			Message:  e.Message,
+			Content:  e.Content,
+		}
+		if e.Fix != nil {
+			record.Fix = e.Fix.Replacement
+		}
+		if err := opts.ErrorFormat.Execute(os.Stdout, record); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error in --error-format: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+	return max
+}
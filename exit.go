@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+)
+
+// Exit statuses. Scripts rely on them to tell bad XML from an input that
+// could not be read, so their meanings do not change.
+const (
+	exitOK         = 0 // Every input is valid (warnings allowed unless --fail-on=warning)
+	exitValidation = 1 // An input has issues at the --fail-on severity
+	exitIO         = 2 // A file could not be read or written
+	exitUsage      = 3 // Unknown command, flag or flag value, or missing arguments
+	exitNetwork    = 4 // A URL could not be downloaded
+)
+
+// networkError marks a failure to download a URL input
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string { return e.err.Error() }
+
+func (e *networkError) Unwrap() error { return e.err }
+
+// inputExitCode returns the exit status for an error reading an input
+func inputExitCode(err error) int {
+	var netErr *networkError
+	if errors.As(err, &netErr) {
+		return exitNetwork
+	}
+	return exitIO
+}
+
+// parseFlags parses a command's flags, exiting with exitUsage on an
+// invalid one. The flag set must use flag.ContinueOnError.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitUsage)
+	}
+}
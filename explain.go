@@ -0,0 +1,354 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// ruleInfo documents one kind of error under a stable ID, for "explain"
+type ruleInfo struct {
+	id        string // Stable identifier, e.g. "CDATA003"; never reused
+	errorType string // The ErrorType the error is reported with
+	check     string // Name of the check that reports it, see validationRules
+	summary   string
+	example   string
+	fix       string
+}
+
+// ruleCatalog lists every kind of error. IDs are part of the tool's
+// interface (scripts and configs refer to them), so new kinds get new
+// numbers and the IDs of removed ones are not reused.
+var ruleCatalog = []ruleInfo{
+	{"XML001", "Basic XML Syntax Error", "basic",
+		"The document is not well-formed XML: a tag is not closed or closed in the wrong order, an attribute is not quoted, a character such as & or < is not escaped, and so on. Nothing else is checked until it is fixed.",
+		"<item><title>Fish & Chips</title></item>",
+		"Fix the construct at the reported position, e.g. write & as &amp; and < as &lt; in text, or wrap the text in <![CDATA[ ]]>. Run with --recover to list several such errors at once."},
+	{"XML002", "XML Error", "basic",
+		"The XML parser failed without reporting a position, typically because the input could not be read or uses an unsupported encoding.",
+		"<?xml version=\"1.0\" encoding=\"x-unknown\"?>",
+		"Check that the input is complete and declares an encoding it is really written in; 'fix --output-encoding=utf-8' can re-encode it."},
+
+	{"CDATA001", "Special character after CDATA opening", "cdata",
+		"A character other than a letter, digit or space directly follows <![CDATA[. Some importers, notably older WordPress ones, mis-parse such sections.",
+		"<content:encoded><![CDATA[#intro]]></content:encoded>",
+		"Add a space or the intended text first, or check that the export did not corrupt the start of the content."},
+	{"CDATA002", "Exclamation mark after CDATA opening", "cdata",
+		"An exclamation mark directly follows <![CDATA[, which usually means the opening marker was duplicated or mangled, as happens in some WordPress exports.",
+		"<![CDATA[!<p>Hello</p>]]>",
+		"Remove the stray '!' or repair the marker to read <![CDATA[."},
+	{"CDATA003", "Unclosed CDATA section", "cdata",
+		"A <![CDATA[ section has no ]]> anywhere after it, so the rest of the document is swallowed as text.",
+		"<title><![CDATA[Hello</title></channel></rss>",
+		"Add the missing ]]> where the section should end."},
+	{"CDATA004", "Nested CDATA sections", "cdata",
+		"A second <![CDATA[ appears inside an open CDATA section. CDATA cannot nest: the first ]]> ends the outer section and the rest becomes markup.",
+		"<![CDATA[ outer <![CDATA[ inner ]]> ]]>",
+		"Remove the inner markers, or split the text so the inner ]]> is written as ]]]]><![CDATA[>."},
+	{"CDATA005", "Multiple CDATA closing sequences", "cdata",
+		"A CDATA section is followed by more than one ]]>. Only the first ends the section; the second is literal text that breaks well-formedness.",
+		"<![CDATA[ a ]]> b ]]>",
+		"Remove the extra ]]>, or escape a literal ]]> in content as ]]]]><![CDATA[>."},
+	{"CDATA006", "Empty CDATA section", "cdata",
+		"An empty <![CDATA[]]> section. It is legal but usually means content was lost during an export.",
+		"<excerpt:encoded><![CDATA[]]></excerpt:encoded>",
+		"Check the source of the data; remove the section if empty is intended."},
+
+	{"CHAR001", "Control character", "control-chars",
+		"A control character (bytes 0x00-0x1F other than tab, CR and LF) appears in the document. XML 1.0 forbids them everywhere, even in CDATA.",
+		"<title>Page^L break</title> (^L being a form feed, 0x0C)",
+		"Delete the character. --emit-fixes writes a fix that removes it."},
+	{"CHAR002", "4-byte UTF-8 character", "utf8mb3",
+		"A character outside the Basic Multilingual Plane, such as an emoji, which takes four bytes in UTF-8. MySQL's legacy utf8/utf8mb3 character set cannot store it and truncates or rejects the row.",
+		"<title>Launch day 🚀</title>",
+		"Convert the table to utf8mb4, or replace the character with an HTML entity (&#x1F680;) as WordPress does."},
+
+	{"COLOR001", "Invalid hex color", "hex-colors",
+		"A hex color in a color attribute or CSS color property does not have 3, 4, 6 or 8 hex digits, or contains non-hex characters.",
+		"<rect fill=\"#12345\"/>",
+		"Use #RGB, #RGBA, #RRGGBB or #RRGGBBAA. 'fix --hex-colors=expand' repairs codes that have one obvious meaning."},
+
+	{"SVG001", "SVG unclosed element", "svg",
+		"An SVG element is opened but never closed.",
+		"<svg><g><rect width=\"1\" height=\"1\"/></svg>",
+		"Add the end tag (</g>), or self-close an element without content (<g/>)."},
+	{"SVG002", "SVG self-closing tag issue", "svg",
+		"A graphics element such as <path> or <circle> is opened but never closed. Such elements are normally empty and written self-closing.",
+		"<circle r=\"5\">",
+		"Write <circle r=\"5\"/>, or add the end tag if the element has content. The fix command self-closes empty ones."},
+	{"SVG003", "SVG unknown element", "svg",
+		"The element is not in the SVG 1.1 or 2.0 element tables, often because of a case mistake (SVG names are case-sensitive).",
+		"<lineargradient id=\"g\">",
+		"Use the exact SVG name (linearGradient), or put custom elements in their own namespace."},
+	{"SVG004", "SVG misplaced element", "svg",
+		"The element is not allowed inside its parent, e.g. a gradient <stop> outside a gradient.",
+		"<g><stop offset=\"0\"/></g>",
+		"Move the element into a parent that accepts it."},
+	{"SVG005", "SVG unquoted attribute", "svg",
+		"An attribute value is not quoted, which XML does not allow (HTML does; see --embedded-svg=html).",
+		"<rect width=100 height=\"10\"/>",
+		"Quote the value: width=\"100\"."},
+	{"SVG006", "SVG disallowed attribute", "svg",
+		"The element does not accept this attribute, often because of a case mistake such as viewbox for viewBox.",
+		"<svg viewbox=\"0 0 10 10\">",
+		"Use the exact attribute name, or remove the attribute."},
+	{"SVG007", "SVG invalid path data", "svg",
+		"The d attribute of a <path> breaks the path data grammar: an unknown command letter, the wrong number of arguments, a malformed number or an arc flag other than 0 or 1.",
+		"<path d=\"M 10 10 L 20\"/>",
+		"Correct the token at the reported offset; every command needs complete coordinate pairs."},
+	{"SVG008", "SVG invalid transform", "svg",
+		"A transform, gradientTransform or patternTransform value uses an unknown function, the wrong number of arguments or a malformed number.",
+		"<g transform=\"rotate(45,)\">",
+		"Use translate, scale, rotate, skewX, skewY or matrix with the documented argument counts."},
+	{"SVG009", "SVG invalid length", "svg",
+		"A length attribute such as width, x or r has a malformed number, an unknown unit, or a space between the number and its unit.",
+		"<rect width=\"100pxx\" height=\"50 px\"/>",
+		"Write a number directly followed by a unit such as px, em, pt, mm or %."},
+	{"SVG010", "SVG invalid viewBox", "svg",
+		"The viewBox does not hold exactly four numbers, or its width or height is negative or zero.",
+		"<svg viewBox=\"0 0 100\">",
+		"Give min-x, min-y, width and height, e.g. viewBox=\"0 0 100 50\"."},
+	{"SVG011", "SVG viewBox mismatch", "svg",
+		"The aspect ratio of the viewBox differs from that of the width and height, so the image is letterboxed or distorted.",
+		"<svg width=\"100\" height=\"100\" viewBox=\"0 0 200 100\">",
+		"Make the ratios match, or set preserveAspectRatio if the difference is intended."},
+	{"SVG012", "SVG script", "svg",
+		"The SVG contains a <script> element, which runs when the image is opened directly or inlined.",
+		"<svg><script>alert(1)</script></svg>",
+		"Remove the script; images should not need one."},
+	{"SVG013", "SVG event handler", "svg",
+		"An on* attribute such as onload holds script that runs when the SVG is served.",
+		"<svg onload=\"alert(1)\">",
+		"Remove the event handler attribute."},
+	{"SVG014", "SVG javascript URL", "svg",
+		"A link or reference uses a javascript: URL.",
+		"<a href=\"javascript:alert(1)\">",
+		"Link to a real URL or remove the link."},
+	{"SVG015", "SVG external reference", "svg",
+		"An <image>, <use> or CSS url() loads a resource from another host, which leaks visits and breaks when the host goes away.",
+		"<image href=\"https://tracker.example/pixel.png\"/>",
+		"Embed or self-host the resource, or list trusted hosts with --svg-allow-hosts."},
+
+	{"CSS001", "CSS syntax error", "css",
+		"CSS in a style attribute or <style> element has an unterminated declaration, string or comment, a missing colon or value, or unbalanced brackets or braces.",
+		"<p style=\"color: red; font-weight\">",
+		"Complete the declaration at the reported position (property: value;)."},
+
+	{"HTML001", "HTML unclosed tag", "html",
+		"HTML inside a CDATA section opens an element that is never closed.",
+		"<![CDATA[<p>Intro <strong>bold</p>]]>",
+		"Close the element (</strong>) before its parent ends."},
+	{"HTML002", "HTML stray end tag", "html",
+		"HTML inside a CDATA section has an end tag with no matching open element.",
+		"<![CDATA[<p>Text</p></div>]]>",
+		"Remove the end tag or add the missing start tag."},
+	{"HTML003", "HTML invalid nesting", "html",
+		"HTML inside a CDATA section closes elements in the wrong order, or nests an element such as <p> or <a> inside another of the same kind.",
+		"<![CDATA[<b><i>text</b></i>]]>",
+		"Close elements in the reverse order they were opened."},
+
+	{"DATE001", "Invalid date", "dates",
+		"A date element (pubDate, updated, wp:post_date...) is not in the format its vocabulary requires, such as RFC 822 for RSS or RFC 3339 for Atom.",
+		"<pubDate>2024-01-05</pubDate>",
+		"Write the date in the expected format, e.g. <pubDate>Fri, 05 Jan 2024 09:00:00 +0000</pubDate>."},
+
+	{"WXR001", "WXR structure", "wxr",
+		"The document is not shaped like a WordPress export: the root is not <rss> or it has no <channel>.",
+		"<export><item/></export>",
+		"Export again from WordPress (Tools > Export); hand-built files need <rss><channel>...</channel></rss>."},
+	{"WXR002", "WXR version", "wxr",
+		"<wp:wxr_version> is missing or unsupported, disagrees with the wp: namespace, or the file uses elements newer than its declared version.",
+		"<wp:wxr_version>1.0</wp:wxr_version> with <wp:term>",
+		"Declare the version that matches the namespace and the elements used (1.2 for current WordPress)."},
+	{"WXR003", "WXR missing channel element", "wxr",
+		"The <channel> lacks an element the WordPress importer requires, such as <wp:wxr_version> or <wp:base_site_url>.",
+		"<channel><title>Blog</title><item/></channel>",
+		"Add the missing element, copying it from a fresh export if necessary."},
+	{"WXR004", "WXR incomplete item", "wxr",
+		"An <item> lacks <wp:post_id>, <wp:post_type> or <wp:status>, so the importer cannot place it.",
+		"<item><title>Hello</title></item>",
+		"Add the missing wp: elements."},
+	{"WXR005", "WXR duplicate GUID", "wxr",
+		"Two items share a <guid>. The importer treats the second as already imported and skips it.",
+		"<guid>https://example.com/?p=1</guid> on two items",
+		"Give each item a unique GUID."},
+	{"WXR006", "WXR duplicate post ID", "wxr",
+		"Two items share a <wp:post_id>, so parent, featured-image and comment references become ambiguous.",
+		"<wp:post_id>7</wp:post_id> on two items",
+		"Renumber one item and the references to it."},
+	{"WXR007", "WXR broken reference", "wxr",
+		"A <wp:post_parent> or featured image (_thumbnail_id) refers to a post that is not in the export, or to one that is not an attachment.",
+		"<wp:post_parent>99</wp:post_parent> with no item 99",
+		"Include the referenced post in the export, or clear the reference."},
+	{"WXR008", "WXR undeclared author", "wxr",
+		"An item's <dc:creator> has no matching <wp:author> entry, so the importer cannot map it to a user.",
+		"<dc:creator>guest</dc:creator> with no <wp:author_login>guest</wp:author_login>",
+		"Add the <wp:author> entry, or change the creator to a declared author."},
+	{"WXR009", "WXR corrupted serialized data", "wxr",
+		"A PHP serialized <wp:meta_value> declares string lengths that no longer match its content, typically after a search-and-replace. WordPress silently drops such values.",
+		"s:5:\"https://new.example\";",
+		"Re-run the replacement with a serialization-aware tool (e.g. wp search-replace), or correct the s:N: lengths."},
+	{"WXR010", "WXR invalid slug", "wxr",
+		"A category, tag or term slug, or a <wp:post_name>, breaks WordPress slug rules (upper case, spaces, characters WordPress would strip).",
+		"<wp:category_nicename>My News</wp:category_nicename>",
+		"Use lower-case letters, digits and hyphens: my-news."},
+	{"WXR011", "WXR duplicate slug", "wxr",
+		"Two terms of one taxonomy share a slug. The importer merges them into one term.",
+		"two <wp:category> with <wp:category_nicename>news</wp:category_nicename>",
+		"Give each term a unique slug."},
+	{"WXR012", "WXR slug collision", "wxr",
+		"Several items of one post type share a slug. The importer renames all but the first, breaking links.",
+		"two posts with <wp:post_name>hello</wp:post_name>",
+		"Make the slugs unique before importing so URLs stay predictable."},
+	{"WXR013", "WXR title collision", "wxr",
+		"Several items of one post type share a title, often a sign of duplicated content.",
+		"two pages titled About",
+		"Check whether the items are duplicates; rename or remove them."},
+	{"WXR014", "Broken attachment URL", "wxr",
+		"With --check-urls, an attachment's <wp:attachment_url> or <guid> did not resolve. The importer will fail to download the media.",
+		"<wp:attachment_url>https://old.example/uploads/a.jpg</wp:attachment_url> returning 404",
+		"Restore the file at that URL or update the URL before importing."},
+
+	{"RSS001", "RSS structure", "rss",
+		"The feed is not RSS 2.0: the root is not <rss version=\"2.0\"> or it does not hold exactly one <channel>.",
+		"<rss version=\"0.91\">",
+		"Use <rss version=\"2.0\"> with a single <channel>."},
+	{"RSS002", "RSS missing element", "rss",
+		"A <channel> lacks <title>, <link> or <description>, or an <item> has neither <title> nor <description>.",
+		"<item><link>https://example.com/1</link></item>",
+		"Add the required element."},
+	{"RSS003", "RSS invalid enclosure", "rss",
+		"An <enclosure> lacks its required url, length or type attribute.",
+		"<enclosure url=\"https://example.com/a.mp3\"/>",
+		"Add length (in bytes) and type (MIME type) attributes."},
+	{"RSS004", "RSS unknown element", "rss",
+		"An element is not part of RSS 2.0 and is not in a declared namespace.",
+		"<channel><subtitle>x</subtitle></channel>",
+		"Put extensions in a namespace (e.g. itunes:subtitle) and declare it on <rss>."},
+	{"RSS005", "Broken enclosure URL", "rss",
+		"With --check-urls, an enclosure URL did not resolve.",
+		"<enclosure url=\"https://example.com/gone.mp3\" .../> returning 404",
+		"Fix or remove the enclosure."},
+	{"RSS006", "Enclosure length mismatch", "rss",
+		"With --check-urls, the enclosure's length attribute differs from the Content-Length the server reports.",
+		"length=\"1000\" for a 52,000,000 byte file",
+		"Set length to the file's size in bytes."},
+	{"RSS007", "Enclosure type mismatch", "rss",
+		"With --check-urls, the enclosure's type attribute differs from the Content-Type the server sends.",
+		"type=\"audio/mpeg\" for a file served as video/mp4",
+		"Set type to the file's real MIME type, or fix the server's Content-Type."},
+
+	{"ATOM001", "Atom structure", "atom",
+		"The root element is not <feed> in the Atom namespace.",
+		"<feed>...</feed> without xmlns=\"http://www.w3.org/2005/Atom\"",
+		"Declare the namespace on <feed>."},
+	{"ATOM002", "Atom missing element", "atom",
+		"A feed or entry lacks an element RFC 4287 requires: id, title, updated, an author, a self or alternate link, or a summary for out-of-line content.",
+		"<entry><title>Hi</title></entry>",
+		"Add the missing element."},
+	{"ATOM003", "Atom duplicate element", "atom",
+		"An element that must appear exactly once appears several times.",
+		"<entry><id>a</id><id>b</id></entry>",
+		"Keep one of them."},
+	{"ATOM004", "Atom invalid link", "atom",
+		"A <link> lacks href, uses an unregistered rel that is not an IRI or an invalid MIME type, or duplicates another alternate link.",
+		"<link rel=\"home\" href=\"/\"/>",
+		"Use a registered relation (alternate, self, related, enclosure, via) or a full IRI."},
+
+	{"PODCAST001", "Podcast episode numbering", "podcast",
+		"An <itunes:season> or <itunes:episode> is not a positive integer, or an episode number is used twice.",
+		"<itunes:episode>0</itunes:episode>",
+		"Number episodes 1, 2, 3... uniquely within a season."},
+	{"PODCAST002", "Podcast missing element", "podcast",
+		"The channel lacks an element podcast directories require: <language>, <itunes:image href>, <itunes:category> or <itunes:explicit>.",
+		"<channel> without <itunes:image>",
+		"Add the missing element."},
+	{"PODCAST003", "Podcast invalid category", "podcast",
+		"<itunes:category text> is not an Apple Podcasts category.",
+		"<itunes:category text=\"Tech\"/>",
+		"Use a category from Apple's list, e.g. Technology."},
+	{"PODCAST004", "Podcast invalid value", "podcast",
+		"<itunes:explicit>, <podcast:locked> or <itunes:episodeType> has a value outside its allowed set.",
+		"<itunes:explicit>clean</itunes:explicit>",
+		"Use true/false for explicit, yes/no for locked and full, trailer or bonus for episodeType."},
+	{"PODCAST005", "Podcast missing enclosure", "podcast",
+		"An episode has no <enclosure> with its media file.",
+		"<item><title>Episode 1</title></item>",
+		"Add <enclosure url=... length=... type=...>."},
+	{"PODCAST006", "Podcast invalid enclosure", "podcast",
+		"The enclosure's MIME type is not one podcast directories accept.",
+		"type=\"audio/wav\"",
+		"Publish audio/mpeg, audio/x-m4a, video/mp4 or another accepted type."},
+
+	{"SITEMAP001", "Sitemap structure", "sitemap",
+		"The root is not <urlset> or <sitemapindex>, or it does not declare the sitemaps.org namespace.",
+		"<urlset> without xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\"",
+		"Use the sitemaps.org root element and namespace."},
+	{"SITEMAP002", "Sitemap limit", "sitemap",
+		"The file is over 50 MB uncompressed or lists more than 50,000 URLs.",
+		"<urlset> with 60,000 <url> entries",
+		"Split it into several sitemaps referenced from a <sitemapindex>."},
+	{"SITEMAP003", "Sitemap missing element", "sitemap",
+		"A <url> or <sitemap> entry has no <loc>.",
+		"<url><lastmod>2024-01-05</lastmod></url>",
+		"Add the page's absolute URL in <loc>."},
+	{"SITEMAP004", "Sitemap invalid URL", "sitemap",
+		"A <loc> is not an absolute http(s) URL.",
+		"<loc>/about</loc>",
+		"Use the full URL, e.g. https://example.com/about."},
+	{"SITEMAP005", "Sitemap invalid value", "sitemap",
+		"<changefreq> or <priority> has a value outside its allowed set or range.",
+		"<priority>5</priority>",
+		"Use always...never for changefreq and 0.0-1.0 for priority."},
+}
+
+// ruleByType and ruleByID index ruleCatalog
+var (
+	ruleByType = make(map[string]*ruleInfo)
+	ruleByID   = make(map[string]*ruleInfo)
+)
+
+func init() {
+	for i := range ruleCatalog {
+		r := &ruleCatalog[i]
+		ruleByType[r.errorType] = r
+		ruleByID[r.id] = r
+	}
+}
+
+// ruleID returns the stable ID of the error's kind, or "" if it has none
+func (e ValidationError) ruleID() string {
+	if r := ruleByType[e.ErrorType]; r != nil {
+		return r.id
+	}
+	return ""
+}
+
+// runExplain implements the "explain" subcommand
+func runExplain(args []string) {
+	efs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	parseFlags(efs, args)
+
+	if efs.NArg() < 1 {
+		// List the IDs to choose from
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, r := range ruleCatalog {
+			fmt.Fprintf(w, "%s\t%s\n", r.id, r.errorType)
+		}
+		w.Flush()
+		return
+	}
+
+	r := ruleByID[strings.ToUpper(efs.Arg(0))]
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "❌ Unknown rule ID %q (run \"xml_validator explain\" for the list)\n", efs.Arg(0))
+		os.Exit(exitUsage)
+	}
+	fmt.Printf("%s %s\n", headerColor(r.id), errorColor(r.errorType))
+	fmt.Printf("%s %s\n\n", infoColor("Check:"), r.check)
+	fmt.Println(r.summary)
+	fmt.Printf("\n%s\n  %s\n", infoColor("Example:"), highlightColor(r.example))
+	fmt.Printf("\n%s\n  %s\n", infoColor("Fix:"), r.fix)
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// FetchOptions controls how URL inputs are downloaded. Every subcommand
+// that reads URLs takes the same flags for them, see addFetchFlags.
+type FetchOptions struct {
+	Timeout        time.Duration // Whole download, body included; 0 for no limit
+	ConnectTimeout time.Duration // Connecting, TLS handshake included; 0 for no limit
+	Retries        int           // Further attempts after a transient failure
+	Debug          bool          // Report each failed attempt
+	Headers        headerFlags   // Sent with every request, after User-Agent so they can replace it
+	UserAgent      string
+	BasicAuth      string // USER:PASS, or "" for $XML_VALIDATOR_BASIC_AUTH
+	BearerToken    string // Or "" for $XML_VALIDATOR_BEARER_TOKEN
+	Proxy          string // Proxy URL for every scheme, instead of $HTTP_PROXY and $HTTPS_PROXY
+	CACert         string // PEM file of CA certificates trusted besides the system ones
+	ClientCert     string // PEM certificate for servers that require one, with ClientKey
+	ClientKey      string
+	Insecure       bool // Skip verifying server certificates
+	MaxRedirects   int  // Redirects followed before giving up
+	NoRedirects    bool // Report a redirect as an HTTP error instead of following it
+	HTTPCache      bool // Keep downloads and revalidate them instead of downloading again
+
+	// Hosts, or buckets, that get the headers and credentials of the flags
+	// and the environment; nil for any. --follow limits them to the hosts
+	// of the inputs, so links cannot lead them to third parties.
+	AuthHosts map[string]bool
+}
+
+// Environment variables holding credentials, so they stay out of shell
+// history and process listings
+const (
+	basicAuthEnv   = "XML_VALIDATOR_BASIC_AUTH"
+	bearerTokenEnv = "XML_VALIDATOR_BEARER_TOKEN"
+)
+
+// fetchOptions are the download options of this run
+var fetchOptions FetchOptions
+
+// addFetchFlags adds the flags for downloading URL inputs to fs
+func addFetchFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&fetchOptions.Timeout, "timeout", 5*time.Minute, "Give up on a URL input not downloaded within this time, e.g. 30s (0 for no limit)")
+	fs.DurationVar(&fetchOptions.ConnectTimeout, "connect-timeout", 10*time.Second, "Give up on connecting to the server of a URL input after this time (0 for no limit)")
+	fs.IntVar(&fetchOptions.Retries, "retries", 0, "Retry a URL input this many times after a server error (5xx, 429) or a dropped connection, waiting longer each time")
+	fetchOptions.Headers = headerFlags{}
+	fs.Var(fetchOptions.Headers, "header", "Send this header with requests for URL inputs, e.g. 'X-Api-Key: secret'; repeatable")
+	v, _, _ := buildInfo()
+	fs.StringVar(&fetchOptions.UserAgent, "user-agent", "xml_validator/"+v, "User-Agent header for requests for URL inputs")
+	fs.Func("basic-auth", "Log in to the servers of URL inputs with USER:PASS (or set "+basicAuthEnv+")", func(value string) error {
+		if !strings.Contains(value, ":") {
+			return fmt.Errorf("want USER:PASS")
+		}
+		fetchOptions.BasicAuth = value
+		return nil
+	})
+	fs.Func("proxy", "Fetch URLs through this proxy, e.g. http://proxy.example.com:3128 or socks5://localhost:1080, instead of the one in $HTTPS_PROXY/$HTTP_PROXY; $NO_PROXY still applies", func(value string) error {
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			return fmt.Errorf("want an http, https or socks5 URL")
+		}
+		fetchOptions.Proxy = value
+		return nil
+	})
+	fs.IntVar(&fetchOptions.MaxRedirects, "max-redirects", 10, "Give up on a URL input after following this many redirects")
+	fs.BoolVar(&fetchOptions.NoRedirects, "no-follow-redirects", false, "Fail on a URL input that redirects instead of following it, e.g. to catch feeds that bounce through trackers")
+	fs.BoolVar(&fetchOptions.HTTPCache, "http-cache", false, "Keep downloaded documents in the user cache directory and only download them again when the server says they changed (ETag, Last-Modified)")
+	fs.StringVar(&fetchOptions.CACert, "ca-cert", "", "Also trust the CA certificates in this PEM file for HTTPS URLs, e.g. an internal CA")
+	fs.StringVar(&fetchOptions.ClientCert, "client-cert", "", "Present this PEM certificate to servers requiring mutual TLS (with --client-key)")
+	fs.StringVar(&fetchOptions.ClientKey, "client-key", "", "Private key of --client-cert, a PEM file")
+	fs.BoolVar(&fetchOptions.Insecure, "insecure", false, "Do not verify the certificates of HTTPS servers, e.g. self-signed staging hosts; anyone on the network path can then read and change the documents")
+	fs.StringVar(&fetchOptions.BearerToken, "bearer-token", "", "Send this token as \"Authorization: Bearer\" to the servers of URL inputs, instead of --basic-auth (or set "+bearerTokenEnv+")")
+}
+
+// headerFlags collects repeated --header="Name: value" values
+type headerFlags http.Header
+
+func (f headerFlags) String() string {
+	var parts []string
+	for name, values := range f {
+		for _, v := range values {
+			parts = append(parts, name+": "+v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (f headerFlags) Set(value string) error {
+	name, v, ok := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("want 'Name: value', got %q", value)
+	}
+	http.Header(f).Add(name, strings.TrimSpace(v))
+	return nil
+}
+
+var (
+	fetchClientOnce sync.Once
+	fetchClient     *http.Client
+)
+
+// httpClient returns the client URL inputs are downloaded with, built
+// from fetchOptions on first use, after the flags are parsed. Other
+// requests, such as those of --check-urls, share its transport so they go
+// through the same proxy.
+func httpClient() *http.Client {
+	fetchClientOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		proxy := httpproxy.FromEnvironment()
+		if fetchOptions.Proxy != "" {
+			proxy.HTTPProxy, proxy.HTTPSProxy = fetchOptions.Proxy, fetchOptions.Proxy
+		}
+		proxyFunc := proxy.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) { return proxyFunc(req.URL) }
+		transport.DialContext = (&net.Dialer{Timeout: fetchOptions.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = fetchOptions.ConnectTimeout
+		config, err := fetchTLSConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitUsage)
+		}
+		transport.TLSClientConfig = config
+		fetchClient = &http.Client{Transport: transport, Timeout: fetchOptions.Timeout, CheckRedirect: checkRedirect}
+	})
+	return fetchClient
+}
+
+// checkRedirect applies --max-redirects and --no-follow-redirects to the
+// redirect to req, and keeps the headers of the flags from other hosts
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if fetchOptions.NoRedirects {
+		// The redirect response is returned, and reported with its target
+		return http.ErrUseLastResponse
+	}
+	if len(via) > fetchOptions.MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", fetchOptions.MaxRedirects)
+	}
+	// Go copies every header to the new request but Authorization and
+	// Cookie, and keeps those for subdomains
+	if req.URL.Host != via[0].URL.Host || !fetchOptions.sendsCredentials(req.URL) {
+		for name := range fetchOptions.Headers {
+			req.Header.Del(name)
+		}
+		req.Host = ""
+		req.Header.Set("User-Agent", fetchOptions.UserAgent)
+	}
+	if !fetchOptions.sendsCredentials(req.URL) {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+// redirects returns how many redirects led to resp
+func redirects(resp *http.Response) int {
+	n := 0
+	for r := resp.Request.Response; r != nil; r = r.Request.Response {
+		n++
+	}
+	return n
+}
+
+// fetchTLSConfig returns the TLS settings of the --ca-cert, --client-cert,
+// --client-key and --insecure flags
+func fetchTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: fetchOptions.Insecure}
+	if fetchOptions.Insecure {
+		fmt.Fprintf(os.Stderr, "%s --insecure: the certificates of HTTPS servers are not verified\n", highlightColor("Warning:"))
+	}
+	if fetchOptions.CACert != "" {
+		pem, err := os.ReadFile(fetchOptions.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read --ca-cert: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("--ca-cert %s holds no PEM certificates", fetchOptions.CACert)
+		}
+		config.RootCAs = pool
+	}
+	if (fetchOptions.ClientCert == "") != (fetchOptions.ClientKey == "") {
+		return nil, fmt.Errorf("--client-cert and --client-key go together")
+	}
+	if fetchOptions.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(fetchOptions.ClientCert, fetchOptions.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// Waits between attempts: the first retry waits about retryBaseDelay,
+// each further one twice as long, up to retryMaxDelay
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+)
+
+// fetcher downloads URL inputs of one scheme. Fetchers for schemes other
+// than HTTP return a response made up the way fetchHTTP's would be, so
+// inputs are read the same whatever their scheme.
+type fetcher func(u *url.URL) (*http.Response, error)
+
+// fetchers by URL scheme; isRemote treats any URL with one of these
+// schemes as an input to download
+var fetchers = map[string]fetcher{
+	"http":  fetchHTTP,
+	"https": fetchHTTP,
+	"s3":    fetchS3,
+	"gs":    fetchGCS,
+	"ftp":   fetchFTP,
+}
+
+// fetchURL starts downloading rawURL with the fetcher for its scheme
+func fetchURL(rawURL string) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	fetch := fetchers[strings.ToLower(u.Scheme)]
+	if fetch == nil {
+		return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	return fetch(u)
+}
+
+// sendsCredentials reports whether requests for u may carry the headers
+// and credentials of the flags and the environment
+func (o FetchOptions) sendsCredentials(u *url.URL) bool {
+	return o.AuthHosts == nil || o.AuthHosts[strings.ToLower(u.Host)]
+}
+
+// fetchHTTP requests u with the headers and credentials of the flags
+func fetchHTTP(u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", fetchOptions.UserAgent)
+	if !fetchOptions.sendsCredentials(u) {
+		// Only the netrc file, which names its hosts, applies
+		if login, ok := netrcLogin(u.Hostname()); ok {
+			req.SetBasicAuth(login.user, login.password)
+		}
+		return sendFetch(req)
+	}
+	for name, values := range fetchOptions.Headers {
+		if name == "Host" {
+			// Go sends the Host header from req.Host
+			req.Host = values[len(values)-1]
+			continue
+		}
+		req.Header[name] = values
+	}
+	if req.Header.Get("Authorization") == "" {
+		setAuth(req)
+	}
+	return sendFetch(req)
+}
+
+// sendFetch sends req for a fetcher that downloads over HTTP, with
+// retries and resuming. With --http-cache, a document unchanged since it
+// was last downloaded is read from the cache instead.
+func sendFetch(req *http.Request) (*http.Response, error) {
+	url := req.URL.String()
+	var cache *httpCache
+	if fetchOptions.HTTPCache {
+		cache = openHTTPCache()
+	}
+	entry, cached := cache.lookup(url)
+	if cached {
+		cache.prepare(req, entry)
+	}
+	resp, err := fetchWithRetries(req)
+	if err != nil {
+		return nil, err
+	}
+	if cached && resp.StatusCode == http.StatusNotModified {
+		fmt.Fprintln(os.Stderr, infoColor("Not modified since the last download; using the cached copy"))
+		return cache.cached(resp, entry)
+	}
+	resp = resumable(resp)
+	cache.record(url, resp)
+	return resp, nil
+}
+
+// fetchWithRetries sends req, again after each transient failure as often
+// as fetchOptions.Retries allows. The last response or error is returned.
+func fetchWithRetries(req *http.Request) (*http.Response, error) {
+	url := req.URL.String()
+	for attempt := 0; ; attempt++ {
+		resp, err := httpClient().Do(req)
+		if attempt >= fetchOptions.Retries || !isTransient(resp, err) {
+			return resp, err
+		}
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			resp.Body.Close()
+		}
+		delay := retryDelay(attempt)
+		if fetchOptions.Debug {
+			fmt.Fprintf(os.Stderr, "Attempt %d of %d for %s failed (%s); retrying in %v\n", attempt+1, fetchOptions.Retries+1, url, reason, delay.Round(time.Millisecond))
+		}
+		time.Sleep(delay)
+	}
+}
+
+// isTransient reports whether a request that got resp or err may succeed
+// when sent again: the server was overloaded or failing, or the
+// connection dropped or timed out
+func isTransient(resp *http.Response, err error) bool {
+	if err == nil {
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// retryDelay returns how long to wait before retry attempt+1: exponential
+// backoff with jitter, so clients that failed together do not retry
+// together
+func retryDelay(attempt int) time.Duration {
+	delay := min(retryBaseDelay<<min(attempt, 16), retryMaxDelay)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// setAuth adds the first credentials found to req: a bearer token, then
+// a user and password, each from its flag or environment variable, then
+// the login for the host in the netrc file
+func setAuth(req *http.Request) {
+	if token := cmp.Or(fetchOptions.BearerToken, os.Getenv(bearerTokenEnv)); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return
+	}
+	if user, pass, ok := strings.Cut(cmp.Or(fetchOptions.BasicAuth, os.Getenv(basicAuthEnv)), ":"); ok {
+		req.SetBasicAuth(user, pass)
+		return
+	}
+	if login, ok := netrcLogin(req.URL.Hostname()); ok {
+		req.SetBasicAuth(login.user, login.password)
+	}
+}
+
+// netrcEntry is the login for one machine in a netrc file
+type netrcEntry struct {
+	user, password string
+}
+
+// netrcLogin returns the login $NETRC, or ~/.netrc, has for host, or its
+// default login
+func netrcLogin(host string) (netrcEntry, bool) {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return netrcEntry{}, false
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return netrcEntry{}, false
+	}
+	machines, fallback := parseNetrc(string(data))
+	if login, ok := machines[strings.ToLower(host)]; ok {
+		return login, true
+	}
+	return fallback, fallback != netrcEntry{}
+}
+
+// parseNetrc reads the logins of a netrc file by machine name, and the
+// default login. Macro definitions are skipped.
+func parseNetrc(data string) (map[string]netrcEntry, netrcEntry) {
+	machines := make(map[string]netrcEntry)
+	var fallback netrcEntry
+	var current *netrcEntry
+	var machine string
+	save := func() {
+		if current == nil {
+			return
+		}
+		if machine == "" {
+			fallback = *current
+		} else {
+			machines[machine] = *current
+		}
+	}
+
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			next := func() string {
+				if j+1 < len(fields) {
+					j++
+					return fields[j]
+				}
+				return ""
+			}
+			switch fields[j] {
+			case "machine":
+				save()
+				current, machine = &netrcEntry{}, strings.ToLower(next())
+			case "default":
+				save()
+				current, machine = &netrcEntry{}, ""
+			case "login":
+				if user := next(); current != nil {
+					current.user = user
+				}
+			case "password":
+				if password := next(); current != nil {
+					current.password = password
+				}
+			case "account":
+				next()
+			case "macdef":
+				// The macro runs to the next blank line
+				for i++; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				}
+				j = len(fields)
+			}
+		}
+	}
+	save()
+	return machines, fallback
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Hex color fixing modes for --hex-colors
+const (
+	hexFixOff       = "off"       // Leave hex colors untouched
+	hexFixExpand    = "expand"    // Repair malformed codes that have one obvious meaning
+	hexFixNormalize = "normalize" // Expand, then lowercase and expand #RGB shorthand everywhere
+)
+
+// reHexRun matches a run of hex digits after '#'; the character following
+// it is captured so identifiers like #abcdefg can be told apart from colors
+var reHexRun = regexp.MustCompile(`#([0-9a-fA-F]+)([\w-]?)`)
+
+// FixOptions selects which automatic corrections the fix subcommand applies
+type FixOptions struct {
+	SVGSelfClosing bool
+	HexColors      string       // One of hexFixOff, hexFixExpand, hexFixNormalize
+	OutputEncoding string       // Transcode the result to this encoding when set
+	Output         string       // Output path, stdout when empty
+	InPlace        bool         // Overwrite the input file
+	CustomRules    []CustomRule // Config file rules; those with a replacement are applied
+}
+
+// runFix implements the "fix" subcommand
+func runFix(args []string) {
+	opts := FixOptions{}
+	fs := flag.NewFlagSet("fix", flag.ContinueOnError)
+	fs.BoolVar(&opts.SVGSelfClosing, "svg-self-closing", true, "Self-close empty SVG shape elements such as <path ...>")
+	fs.StringVar(&opts.HexColors, "hex-colors", hexFixOff, "Hex color correction: off, expand (#RGBA -> #RRGGBBAA), or normalize (also lowercase and expand #RGB)")
+	fs.StringVar(&opts.OutputEncoding, "output-encoding", "", "Transcode the output (e.g. utf-8, utf-16) and update the XML declaration")
+	fs.StringVar(&opts.Output, "output", "", "Write the result to this file instead of stdout")
+	fs.BoolVar(&opts.InPlace, "in-place", false, "Overwrite the input file (local files only)")
+	configPath := fs.String("config", defaultConfigFile, "Config file whose custom rules with a replacement are applied too")
+	addFetchFlags(fs)
+	parseFlags(fs, args)
+
+	explicitConfig := false
+	fs.Visit(func(f *flag.Flag) { explicitConfig = explicitConfig || f.Name == "config" })
+	config, err := loadConfig(*configPath, explicitConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading config: %v\n", err)
+		os.Exit(configExitCode(err))
+	}
+	opts.CustomRules = config.Rules
+
+	switch opts.HexColors {
+	case hexFixOff, hexFixExpand, hexFixNormalize:
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Unknown --hex-colors mode %q (want off, expand or normalize)\n", opts.HexColors)
+		os.Exit(exitUsage)
+	}
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: xml_validator fix [--svg-self-closing] [--hex-colors=MODE] [--config=FILE] [--output-encoding=ENC] [--output=FILE | --in-place] <xml-file-or-URL>")
+		os.Exit(exitUsage)
+	}
+
+	filepath := fs.Arg(0)
+	if opts.InPlace {
+		if isRemote(filepath) {
+			fmt.Fprintln(os.Stderr, "❌ --in-place cannot be used with a URL")
+			os.Exit(exitUsage)
+		}
+		opts.Output = filepath
+	}
+
+	content, err := readFileContent(filepath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading file: %v\n", err)
+		os.Exit(inputExitCode(err))
+	}
+
+	if opts.OutputEncoding != "" {
+		// Fixers work on UTF-8, so normalize the input before running them
+		if content, err = decodeToUTF8(content); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitValidation)
+		}
+	}
+
+	fixed, count := fixXML(content, opts)
+	fmt.Fprintf(os.Stderr, "%s Applied %d fixes\n", successColor("✅"), count)
+
+	if opts.OutputEncoding != "" {
+		if fixed, err = encodeFromUTF8(fixed, opts.OutputEncoding); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitUsage)
+		}
+		fmt.Fprintf(os.Stderr, "%s Output encoded as %s\n", successColor("✅"), canonicalEncodingName(opts.OutputEncoding))
+	}
+
+	if err := writeOutput(opts.Output, fixed); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error writing output: %v\n", err)
+		os.Exit(exitIO)
+	}
+}
+
+// fixXML runs every enabled fixer over content and returns the result
+// together with the total number of corrections made
+func fixXML(content []byte, opts FixOptions) ([]byte, int) {
+	total := 0
+
+	if opts.SVGSelfClosing {
+		var n int
+		content, n = fixSVGSelfClosing(content)
+		total += n
+	}
+
+	if opts.HexColors != hexFixOff {
+		var n int
+		content, n = fixHexColors(content, opts.HexColors == hexFixNormalize)
+		total += n
+	}
+
+	content, n := fixCustomRules(content, opts.CustomRules)
+	total += n
+
+	return content, total
+}
+
+// fixSVGSelfClosing applies the fixes validateSVG suggests for unclosed
+// graphics elements: empty ones are self-closed and those with content get
+// their end tag. Driving the fix from the same token-based scan keeps it
+// from touching an element the validator accepts, such as a <path> closed
+// on a later line.
+func fixSVGSelfClosing(content []byte) ([]byte, int) {
+	var fixes []SuggestedFix
+	for _, e := range validateSVG(content, indexLines(content), ValidationOptions{EmbeddedSVG: "xml"}) {
+		if e.ErrorType == "SVG self-closing tag issue" && e.Fix != nil {
+			fixes = append(fixes, *e.Fix)
+		}
+	}
+	if len(fixes) == 0 {
+		return content, 0
+	}
+	return applySuggestedFixes(content, fixes), len(fixes)
+}
+
+// fixHexColors repairs hex color codes in color attributes and CSS color
+// properties, the contexts validateHexColors checks. Four-digit #RGBA codes are expanded
+// to #RRGGBBAA; with normalize set, #RGB is expanded to #RRGGBB and all
+// codes are lowercased. Codes whose intended value cannot be inferred
+// (#R, #RG, #RRGGB, seven or more than eight digits) are left as they are
+// and listed on stderr for manual review.
+func fixHexColors(content []byte, normalize bool) ([]byte, int) {
+	var out bytes.Buffer
+	count := 0
+	last := 0
+	ix := indexLines(content)
+
+	for _, run := range findHexColors(content) {
+		if run.trailing {
+			// Followed by a word character: an identifier, not a color
+			continue
+		}
+		digits := run.digits
+
+		replacement := ""
+		switch len(digits) {
+		case 3:
+			if normalize {
+				replacement = expandHexShorthand(digits)
+			}
+		case 4:
+			replacement = expandHexShorthand(digits)
+		case 6, 8:
+			if normalize {
+				replacement = digits
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "%s line %d: cannot fix #%s automatically (TODO: pick #RGB, #RRGGBB or #RRGGBBAA)\n",
+				highlightColor("Skipped"), lineOf(ix, run.start), digits)
+			continue
+		}
+		if replacement == "" {
+			continue
+		}
+		if normalize {
+			replacement = strings.ToLower(replacement)
+		}
+		if replacement == digits {
+			continue
+		}
+
+		out.Write(content[last : run.start+1])
+		out.WriteString(replacement)
+		last = run.end
+		count++
+	}
+	out.Write(content[last:])
+
+	return out.Bytes(), count
+}
+
+// expandHexShorthand doubles every digit, turning RGB into RRGGBB and
+// RGBA into RRGGBBAA
+func expandHexShorthand(digits string) string {
+	var b strings.Builder
+	for _, c := range digits {
+		b.WriteRune(c)
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// emittedFix is the JSON form of a suggested fix written by --emit-fixes
+type emittedFix struct {
+	File      string       `json:"file"`
+	Line      int          `json:"line"`
+	Column    int          `json:"column"`
+	RuleID    string       `json:"ruleId,omitempty"`
+	ErrorType string       `json:"errorType"`
+	Message   string       `json:"message"`
+	Fix       SuggestedFix `json:"fix"`
+}
+
+// writeSuggestedFixes writes every error that carries a suggested fix to
+// path as a JSON array. Byte ranges refer to the unmodified input;
+// columns are characters unless byteColumns is set.
+func writeSuggestedFixes(path, file string, ix *lineIndex, errs []ValidationError, byteColumns bool) error {
+	fixes := []emittedFix{}
+	for _, e := range errs {
+		if e.Fix == nil {
+			continue
+		}
+		fixes = append(fixes, emittedFix{
+			File:      file,
+			Line:      e.LineNumber,
+			Column:    errorColumn(ix, e, byteColumns),
+			RuleID:    e.ruleID(),
+			ErrorType: e.ErrorType,
+			Message:   e.Message,
+			Fix:       *e.Fix,
+		})
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // Replacements are XML, keep them readable
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(fixes); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
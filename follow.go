@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// followLinks adds to inputs the XML resources they link to: the sitemaps
+// of a sitemap index, the feeds of an OPML outline, and the next pages and
+// XML enclosures of an RSS or Atom feed, and then the resources those link
+// to, up to batch.MaxDepth links away. Each resource is listed once, after
+// the document that first links to it. The documents read to find links
+// are kept in batch.Fetched, so the batch does not download them again.
+// Headers and credentials from the flags and the environment are only
+// sent to the hosts of inputs, not to other hosts links lead to.
+func followLinks(inputs []string, batch *BatchOptions) []string {
+	batch.Fetched = make(map[string][]byte)
+	seen := make(map[string]bool)
+	fetchOptions.AuthHosts = make(map[string]bool)
+	for _, input := range inputs {
+		seen[input] = true
+		if host := inputHost(input); host != "" {
+			fetchOptions.AuthHosts[host] = true
+		}
+	}
+	limiter := newRateLimiter(batch.RateLimit)
+	hostLimiters := make(map[string]*rateLimiter)
+
+	level := inputs
+	var all []string
+	for depth := 0; len(level) > 0; depth++ {
+		var next []string
+		for _, input := range level {
+			all = append(all, input)
+			if depth == batch.MaxDepth {
+				continue
+			}
+			if host := inputHost(input); host != "" {
+				if hostLimiters[host] == nil {
+					hostLimiters[host] = newRateLimiter(batch.HostRateLimit)
+				}
+				hostLimiters[host].wait()
+				limiter.wait()
+			}
+			content, err := fetchInput(input)
+			if err != nil {
+				// The batch reports it as unreadable
+				continue
+			}
+			links := linkedResources(content, input)
+			if len(links) == 0 {
+				continue
+			}
+			batch.Fetched[input] = content
+			fmt.Fprintln(os.Stderr, infoColor(fmt.Sprintf("Following %d linked resources from %s", len(links), input)))
+			for _, link := range links {
+				if !seen[link] {
+					seen[link] = true
+					next = append(next, link)
+				}
+			}
+		}
+		level = next
+	}
+	return all
+}
+
+// linkedResources returns the XML resources content links to, resolved
+// against base, the URL or path it was read from. Which links count
+// depends on the root element; other documents, including sitemaps of
+// pages, link to none.
+func linkedResources(content []byte, base string) []string {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		enc, err := lookupEncoding(label)
+		if err != nil {
+			return nil, err
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	var links []string
+	var root string
+	var path []string // Local names of the open elements
+	var text strings.Builder
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			text.Reset()
+			if root == "" {
+				root = t.Name.Local
+				if root != "sitemapindex" && root != "opml" && root != "rss" && root != "feed" {
+					return nil
+				}
+			}
+			switch {
+			case root == "opml" && t.Name.Local == "outline":
+				links = appendLink(links, attrValue(t, "xmlUrl"), base)
+			case (root == "rss" || root == "feed") && t.Name.Local == "link":
+				if rel := attrValue(t, "rel"); rel == "next" || rel != "self" && isXMLType(attrValue(t, "type")) {
+					links = appendLink(links, attrValue(t, "href"), base)
+				}
+			case root == "rss" && t.Name.Local == "enclosure" && isXMLType(attrValue(t, "type")):
+				links = appendLink(links, attrValue(t, "url"), base)
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if root == "sitemapindex" && t.Name.Local == "loc" && len(path) >= 2 && path[len(path)-2] == "sitemap" {
+				links = appendLink(links, text.String(), base)
+			}
+			path = path[:len(path)-1]
+		}
+	}
+	return links
+}
+
+// attrValue returns the value of the attribute with the given local name
+func attrValue(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// isXMLType reports whether a MIME type is XML, e.g. application/rss+xml
+func isXMLType(mimeType string) bool {
+	mimeType, _, _ = strings.Cut(strings.ToLower(mimeType), ";")
+	mimeType = strings.TrimSpace(mimeType)
+	return strings.HasSuffix(mimeType, "/xml") || strings.HasSuffix(mimeType, "+xml")
+}
+
+// appendLink resolves link against base and appends it to links. Links
+// from a URL resolve to URLs; relative links from a local file resolve to
+// files next to it. Links to anything but HTTP(S) and files are dropped.
+func appendLink(links []string, link, base string) []string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return links
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return links
+	}
+	switch {
+	case ref.Scheme != "":
+		if !isRemote(link) {
+			return links
+		}
+	case isRemote(base):
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			return links
+		}
+		link = baseURL.ResolveReference(ref).String()
+	default:
+		link = filepath.Join(filepath.Dir(base), filepath.FromSlash(ref.Path))
+	}
+	return append(links, link)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fetchFTP downloads ftp://[USER[:PASS]@]HOST[:PORT]/PATH in passive
+// mode. The login is taken from the URL, else from the netrc file, else
+// it is anonymous.
+func fetchFTP(u *url.URL) (*http.Response, error) {
+	// They go into commands, where a decoded %0D%0A would start another
+	pass, _ := u.User.Password()
+	if strings.ContainsAny(u.Path+u.User.Username()+pass, "\r\n") {
+		return nil, fmt.Errorf("%s: the path and login of an FTP URL cannot contain line breaks", u.Redacted())
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "21")
+	}
+	dialer := &net.Dialer{Timeout: fetchOptions.ConnectTimeout}
+	conn, err := dialer.Dial("tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if fetchOptions.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(fetchOptions.Timeout))
+	}
+	ftp := &ftpConn{Conn: textproto.NewConn(conn), control: conn}
+	body, size, err := ftp.retrieve(u, dialer)
+	if err != nil {
+		ftp.Close()
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Header:        make(http.Header),
+		Body:          body,
+		ContentLength: size,
+		Request:       &http.Request{Method: http.MethodGet, URL: u},
+	}, nil
+}
+
+// ftpConn is the control connection of an FTP download
+type ftpConn struct {
+	*textproto.Conn
+	control net.Conn
+}
+
+// cmd sends a command and reads its reply, which must have the given
+// code class (e.g. 2 for 2xx)
+func (c *ftpConn) cmd(class int, format string, args ...any) (int, string, error) {
+	if _, err := c.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+	return c.ReadResponse(class)
+}
+
+// retrieve logs in and starts sending the file at u's path, returning
+// the data connection and the file's size, or -1 when the server does
+// not say
+func (c *ftpConn) retrieve(u *url.URL, dialer *net.Dialer) (io.ReadCloser, int64, error) {
+	if _, _, err := c.ReadResponse(2); err != nil {
+		return nil, 0, err
+	}
+	user, pass := "anonymous", "anonymous@"
+	if u.User != nil {
+		user = u.User.Username()
+		pass, _ = u.User.Password()
+	} else if login, ok := netrcLogin(u.Hostname()); ok {
+		user, pass = login.user, login.password
+	}
+	code, _, err := c.cmd(2, "USER %s", user)
+	if err != nil && code == 331 {
+		_, _, err = c.cmd(2, "PASS %s", pass)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, _, err := c.cmd(2, "TYPE I"); err != nil {
+		return nil, 0, err
+	}
+	path := strings.TrimPrefix(u.Path, "/")
+	size := int64(-1)
+	if _, msg, err := c.cmd(2, "SIZE %s", path); err == nil {
+		if n, err := strconv.ParseInt(strings.TrimSpace(msg), 10, 64); err == nil {
+			size = n
+		}
+	}
+
+	addr, err := c.passive()
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	if fetchOptions.Timeout > 0 {
+		data.SetDeadline(time.Now().Add(fetchOptions.Timeout))
+	}
+	if _, _, err := c.cmd(1, "RETR %s", path); err != nil {
+		data.Close()
+		return nil, 0, err
+	}
+	return &ftpBody{Conn: data, ftp: c}, size, nil
+}
+
+// passive asks the server for the address of a data connection, with
+// EPSV and then PASV
+func (c *ftpConn) passive() (string, error) {
+	host, _, _ := net.SplitHostPort(c.control.RemoteAddr().String())
+	if _, msg, err := c.cmd(2, "EPSV"); err == nil {
+		// e.g. "Entering Extended Passive Mode (|||6446|)"
+		start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if start >= 0 && end > start+4 {
+			return net.JoinHostPort(host, msg[start+4:end]), nil
+		}
+	}
+	_, msg, err := c.cmd(2, "PASV")
+	if err != nil {
+		return "", err
+	}
+	// e.g. "Entering Passive Mode (192,168,1,2,19,137)"
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	var parts []int
+	if start >= 0 && end > start {
+		for _, field := range strings.Split(msg[start+1:end], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				break
+			}
+			parts = append(parts, n)
+		}
+	}
+	if len(parts) != 6 {
+		return "", fmt.Errorf("cannot read the passive mode reply %q", msg)
+	}
+	// The server's own address is used, as NAT often makes the one in the
+	// reply unreachable
+	return net.JoinHostPort(host, strconv.Itoa(parts[4]<<8|parts[5])), nil
+}
+
+// ftpBody is the data connection of a download; closing it ends the
+// session
+type ftpBody struct {
+	net.Conn
+	ftp *ftpConn
+}
+
+func (b *ftpBody) Close() error {
+	err := b.Conn.Close()
+	b.ftp.ReadResponse(2)
+	b.ftp.Cmd("QUIT")
+	b.ftp.Close()
+	return err
+}
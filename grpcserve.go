@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+
+	"github.com/yourusername/go-xml-validator/validatorpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcValidator implements the Validator gRPC service (see
+// validatorpb/validator.proto) with the options of the serve subcommand
+type grpcValidator struct {
+	validatorpb.UnimplementedValidatorServer
+	s *serveConfig
+}
+
+// Validate collects the chunks of a document until the client closes its
+// side, then streams back its issues and a summary
+func (g grpcValidator) Validate(stream validatorpb.Validator_ValidateServer) (err error) {
+	// Unlike net/http, gRPC does not recover a handler's panic
+	defer func() {
+		if p := recover(); p != nil {
+			err = status.Errorf(codes.Internal, "validation crashed: %v", p)
+		}
+	}()
+	if !g.s.acquire(stream.Context()) {
+		return status.FromContextError(stream.Context().Err()).Err()
+	}
+	defer g.s.release()
+
+	var content []byte
+	var opts ValidationOptions
+	for first := true; ; first = false {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first {
+			// Reject a bad profile before the whole document is sent
+			if opts, err = g.s.options(req.GetProfile(), req.GetProfile() != ""); err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+		if int64(len(content)+len(req.GetChunk())) > g.s.maxBody {
+			return status.Errorf(codes.ResourceExhausted, "document larger than %s", formatMB(g.s.maxBody))
+		}
+		content = append(content, req.GetChunk()...)
+	}
+	if len(content) == 0 {
+		return status.Error(codes.InvalidArgument, "empty document")
+	}
+
+	ix := indexLines(content)
+	errs := validateXML(content, ix, opts)
+	applySeverities(errs, opts.Severities)
+	report := jsonReport("", ix, errs, opts.FailOn)
+	for _, issue := range report.Issues {
+		err := stream.Send(&validatorpb.ValidateResponse{Result: &validatorpb.ValidateResponse_Issue{Issue: &validatorpb.Issue{
+			Line:     int32(issue.Line),
+			Column:   int32(issue.Column),
+			Offset:   int64(issue.Offset),
+			RuleId:   issue.RuleID,
+			Type:     issue.Type,
+			Message:  issue.Message,
+			Content:  issue.Content,
+			Severity: issue.Severity,
+		}}})
+		if err != nil {
+			return err
+		}
+	}
+	return stream.Send(&validatorpb.ValidateResponse{Result: &validatorpb.ValidateResponse_Summary{Summary: &validatorpb.Summary{
+		Valid:    report.Valid,
+		Errors:   int32(report.Errors),
+		Warnings: int32(report.Warnings),
+	}}})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// rePlaceholder matches the {name} placeholders of --on-error-exec
+var rePlaceholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// Placeholders --on-error-exec fills in. Per file, the error ones
+// describe the first failing error.
+var hookPlaceholders = []string{"file", "line", "col", "rule", "type", "severity", "message", "count"}
+
+// errorHook is the command --on-error-exec runs for failing issues. The
+// command line is split at spaces before placeholders are filled in, so a
+// message with spaces is still one argument and needs no quoting; use a
+// script for pipes or redirection.
+type errorHook struct {
+	args    []string
+	perFile bool // Run once per file with failing issues instead of once per issue
+}
+
+// parseErrorHook checks the command line of --on-error-exec and how often
+// it runs: "error" or "file"
+func parseErrorHook(command, per string) (*errorHook, error) {
+	if per != "error" && per != "file" {
+		return nil, fmt.Errorf("unknown --on-error-exec-per %q (want error or file)", per)
+	}
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("--on-error-exec needs a command")
+	}
+	for _, m := range rePlaceholder.FindAllStringSubmatch(command, -1) {
+		if !slices.Contains(hookPlaceholders, m[1]) {
+			return nil, fmt.Errorf("unknown placeholder %s in --on-error-exec (want {%s})", m[0], strings.Join(hookPlaceholders, "}, {"))
+		}
+	}
+	return &errorHook{args: args, perFile: per == "file"}, nil
+}
+
+// run starts the command for the issues of file at the --fail-on severity
+// or worse, waiting for each. A command that fails is reported and the
+// run goes on. A nil hook does nothing.
+func (h *errorHook) run(file string, ix *lineIndex, errs []ValidationError, opts ValidationOptions) {
+	if h == nil {
+		return
+	}
+	var failing []ValidationError
+	for _, e := range errs {
+		if e.Severity == severityError || opts.FailOn == severityWarning {
+			failing = append(failing, e)
+		}
+	}
+	if len(failing) == 0 {
+		return
+	}
+	if h.perFile {
+		failing = failing[:1]
+	}
+	count := strconv.Itoa(countFailing(errs, opts.FailOn))
+	for _, e := range failing {
+		values := map[string]string{
+			"file":     file,
+			"line":     strconv.Itoa(e.LineNumber),
+			"col":      strconv.Itoa(errorColumn(ix, e, opts.ByteColumns)),
+			"rule":     e.ruleID(),
+			"type":     e.ErrorType,
+			"severity": e.Severity,
+			"message":  e.Message,
+			"count":    count,
+		}
+		args := make([]string, len(h.args))
+		for i, arg := range h.args {
+			args[i] = rePlaceholder.ReplaceAllStringFunc(arg, func(p string) string {
+				return values[p[1:len(p)-1]]
+			})
+		}
+		// Keep the command's output apart from the report
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s --on-error-exec failed for %s: %v\n", errorColor("❌"), file, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"golang.org/x/net/html"
+)
+
+// HTML elements that never have an end tag
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "param": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// HTML elements whose end tag may be omitted; they are closed implicitly
+// and never reported as unclosed
+var htmlOptionalEnd = map[string]bool{
+	"p": true, "li": true, "dt": true, "dd": true, "tr": true, "td": true, "th": true,
+	"thead": true, "tbody": true, "tfoot": true, "colgroup": true, "option": true,
+	"optgroup": true, "rt": true, "rp": true,
+}
+
+// Elements that may not contain another element of the same kind
+var htmlNoSelfNesting = map[string]bool{"a": true, "form": true, "button": true}
+
+// reLooksLikeHTML detects CDATA sections worth parsing as HTML
+var reLooksLikeHTML = regexp.MustCompile(`<[a-zA-Z/]`)
+
+// openHTMLElement is an element on the HTML parse stack and where it started
+type openHTMLElement struct {
+	name   string
+	offset int
+}
+
+// validateEmbeddedHTML parses the HTML inside every CDATA section and
+// reports unclosed tags, stray end tags and invalid nesting, positioned in
+// the surrounding XML document
+func validateEmbeddedHTML(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	for _, section := range findCDATASections(content) {
+		body := content[section[0]:section[1]]
+		if !reLooksLikeHTML.Match(body) {
+			continue
+		}
+		errors = append(errors, checkHTMLFragment(ix, body, section[0])...)
+		if opts.MaxErrors > 0 && len(errors) >= opts.MaxErrors {
+			break
+		}
+	}
+
+	return errors
+}
+
+// findCDATASections returns the [start, end) byte ranges of CDATA contents,
+// excluding the <![CDATA[ and ]]> markers
+func findCDATASections(content []byte) [][2]int {
+	var sections [][2]int
+	pos := 0
+	for {
+		open := bytes.Index(content[pos:], []byte("<![CDATA["))
+		if open < 0 {
+			return sections
+		}
+		start := pos + open + len("<![CDATA[")
+		end := bytes.Index(content[start:], []byte("]]>"))
+		if end < 0 {
+			return sections
+		}
+		sections = append(sections, [2]int{start, start + end})
+		pos = start + end + len("]]>")
+	}
+}
+
+// checkHTMLFragment tokenizes one HTML fragment starting at base in the XML
+// document and checks that its elements are properly closed and nested
+func checkHTMLFragment(ix *lineIndex, fragment []byte, base int) []ValidationError {
+	var errors []ValidationError
+	var stack []openHTMLElement
+
+	report := func(offset int, errorType, message, highlight string) {
+		line, col, lineContent := findErrorPosition(ix, base+offset)
+		errors = append(errors, ValidationError{
+			LineNumber: line,
+			Column:     col,
+			Line:       lineContent,
+			ErrorType:  errorType,
+			Message:    message,
+			Content:    highlight,
+		})
+	}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(fragment))
+	offset := 0
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break // io.EOF: a byte slice reader cannot fail otherwise
+		}
+		tokenStart := offset
+		offset += len(tokenizer.Raw())
+
+		nameBytes, _ := tokenizer.TagName()
+		name := string(nameBytes)
+
+		switch tokenType {
+		case html.StartTagToken:
+			if htmlVoidElements[name] {
+				continue
+			}
+			if htmlNoSelfNesting[name] {
+				for _, open := range stack {
+					if open.name == name {
+						report(tokenStart, "HTML invalid nesting",
+							fmt.Sprintf("<%s> cannot be nested inside another <%s>", name, name), "<"+name)
+						break
+					}
+				}
+			}
+			stack = append(stack, openHTMLElement{name: name, offset: tokenStart})
+
+		case html.EndTagToken:
+			if htmlVoidElements[name] {
+				continue
+			}
+			match := -1
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == name {
+					match = i
+					break
+				}
+			}
+			if match < 0 {
+				report(tokenStart, "HTML stray end tag",
+					fmt.Sprintf("</%s> has no matching <%s>", name, name), "</"+name+">")
+				continue
+			}
+			// Anything still open above the match is misnested, unless its
+			// end tag is optional and it was closed implicitly
+			for _, open := range stack[match+1:] {
+				if !htmlOptionalEnd[open.name] {
+					report(tokenStart, "HTML invalid nesting",
+						fmt.Sprintf("</%s> closes <%s> while <%s> (line %d) is still open",
+							name, name, open.name, lineOf(ix, base+open.offset)), "</"+name+">")
+				}
+			}
+			stack = stack[:match]
+		}
+	}
+
+	for _, open := range stack {
+		if !htmlOptionalEnd[open.name] {
+			report(open.offset, "HTML unclosed tag",
+				fmt.Sprintf("<%s> is never closed", open.name), "<"+open.name)
+		}
+	}
+
+	return errors
+}
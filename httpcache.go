@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// httpCacheEntry describes a cached download, stored next to its body so
+// the next request for the URL can be made conditional
+type httpCacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+}
+
+// httpCache keeps downloaded documents in the user cache directory, one
+// pair of files per URL, and revalidates them with If-None-Match and
+// If-Modified-Since so an unchanged document is not downloaded again
+type httpCache struct {
+	dir string
+}
+
+// openHTTPCache returns the cache, or nil when there is no user cache
+// directory; it is only an optimization
+func openHTTPCache() *httpCache {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+	return &httpCache{dir: filepath.Join(dir, "xml-validator", "http")}
+}
+
+// paths returns the files holding the entry and the body for url
+func (c *httpCache) paths(url string) (string, string) {
+	sum := sha256.Sum256([]byte(url))
+	base := filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+	return base + ".json", base + ".body"
+}
+
+// lookup returns the entry for url if its body is stored too
+func (c *httpCache) lookup(url string) (httpCacheEntry, bool) {
+	var entry httpCacheEntry
+	if c == nil {
+		return entry, false
+	}
+	meta, body := c.paths(url)
+	data, err := os.ReadFile(meta)
+	if err != nil || json.Unmarshal(data, &entry) != nil || entry.URL != url {
+		return entry, false
+	}
+	if info, err := os.Stat(body); err != nil || info.Size() != entry.Size {
+		return entry, false
+	}
+	return entry, true
+}
+
+// prepare makes req conditional on the cached copy of its URL changing
+func (c *httpCache) prepare(req *http.Request, entry httpCacheEntry) {
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// cached returns a response serving the stored body of entry in place of
+// resp, a 304 Not Modified
+func (c *httpCache) cached(resp *http.Response, entry httpCacheEntry) (*http.Response, error) {
+	resp.Body.Close()
+	_, body := c.paths(entry.URL)
+	f, err := os.Open(body)
+	if err != nil {
+		return nil, err
+	}
+	header := resp.Header.Clone()
+	header.Set("Content-Type", entry.ContentType)
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         resp.Proto,
+		ProtoMajor:    resp.ProtoMajor,
+		ProtoMinor:    resp.ProtoMinor,
+		Header:        header,
+		Body:          f,
+		ContentLength: entry.Size,
+		Request:       resp.Request,
+	}, nil
+}
+
+// record has resp's body stored as it is read, if the server sent a
+// validator to revalidate it with. The copy is only kept once the whole
+// body has been read.
+func (c *httpCache) record(url string, resp *http.Response) {
+	entry := httpCacheEntry{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+	}
+	if c == nil || resp.StatusCode != http.StatusOK || (entry.ETag == "" && entry.LastModified == "") {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(c.dir, "download-*")
+	if err != nil {
+		return
+	}
+	resp.Body = &cachingBody{ReadCloser: resp.Body, cache: c, entry: entry, file: f, want: resp.ContentLength}
+}
+
+// cachingBody copies a response body to a temporary file, which becomes
+// the cached copy when the body has been read to the end
+type cachingBody struct {
+	io.ReadCloser
+	cache *httpCache
+	entry httpCacheEntry
+	file  *os.File
+	want  int64 // Content-Length, or -1
+	err   error // Set when the copy failed and is dropped
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.err == nil {
+		_, b.err = b.file.Write(p[:n])
+		b.entry.Size += int64(n)
+	}
+	if err == io.EOF && b.err == nil && b.file != nil && (b.want < 0 || b.want == b.entry.Size) {
+		b.err = b.commit()
+	}
+	return n, err
+}
+
+// commit moves the copy into place and writes its entry
+func (b *cachingBody) commit() error {
+	if err := b.file.Close(); err != nil {
+		return err
+	}
+	meta, body := b.cache.paths(b.entry.URL)
+	if err := os.Rename(b.file.Name(), body); err != nil {
+		return err
+	}
+	data, err := json.Marshal(b.entry)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(meta, data, 0644); err != nil {
+		return err
+	}
+	// Stop Close from removing it
+	b.file = nil
+	return nil
+}
+
+func (b *cachingBody) Close() error {
+	if b.file != nil {
+		b.file.Close()
+		os.Remove(b.file.Name())
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+)
+
+// lineIndex records where each line of a document starts, so byte offsets
+// convert to line numbers by binary search instead of a rescan
+type lineIndex struct {
+	content []byte
+	starts  []int // Offset of the first byte of each line
+}
+
+// indexLines builds the line index of content. Callers build one per
+// document and pass it to validateXML, which hands it to every check, and
+// to the reports of its errors; concurrent checks share it without
+// locking.
+func indexLines(content []byte) *lineIndex {
+	ix := &lineIndex{content: content, starts: []int{0}}
+	for i := 0; ; {
+		next := bytes.IndexByte(content[i:], '\n')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+		ix.starts = append(ix.starts, i)
+	}
+	return ix
+}
+
+// lines returns the number of lines, not counting the empty one after a
+// final newline
+func (ix *lineIndex) lines() int {
+	n := len(ix.starts)
+	if n > 1 && ix.starts[n-1] == len(ix.content) {
+		n--
+	}
+	return n
+}
+
+// lineAt returns the 1-based line containing offset
+func (ix *lineIndex) lineAt(offset int) int {
+	return sort.SearchInts(ix.starts, offset+1)
+}
+
+// lineStart returns the offset where a 1-based line starts, or the length
+// of the content for lines past the end
+func (ix *lineIndex) lineStart(line int) int {
+	if line > len(ix.starts) {
+		return len(ix.content)
+	}
+	return ix.starts[max(line, 1)-1]
+}
+
+// offset returns the byte offset of a 1-based line and byte column; a
+// column of 0 (unknown) means the start of the line
+func (ix *lineIndex) offset(line, column int) int {
+	return min(ix.lineStart(line)+max(column, 1)-1, len(ix.content))
+}
+
+// line returns the text of a 1-based line without its line ending
+func (ix *lineIndex) line(line int) []byte {
+	if line < 1 || line > len(ix.starts) {
+		return nil
+	}
+	end := len(ix.content)
+	if line < len(ix.starts) {
+		end = ix.starts[line] - 1
+	}
+	return bytes.TrimSuffix(ix.content[ix.starts[line-1]:end], []byte("\r"))
+}
@@ -1,189 +1,750 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/xml"
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
-	"regexp"
+	"runtime"
 	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-runewidth"
 )
 
 // ValidationError represents a single XML validation issue
 type ValidationError struct {
 	LineNumber int
 	Column     int
+	Offset     int // Byte offset in the document
 	Line       string
 	ErrorType  string
 	Message    string
 	Content    string // For highlighting purposes
+	Fix        *SuggestedFix
+	Severity   string // "error" or "warning", see applySeverities
+}
+
+// SuggestedFix is a machine-applyable correction for a ValidationError:
+// replace the bytes in [Start, End) of the original content with Replacement
+type SuggestedFix struct {
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Replacement string `json:"replacement"`
 }
 
 // Global validation options
 type ValidationOptions struct {
 	MaxErrors int
 	Debug     bool
-	Color     bool // Whether to use colored output
+	Color     bool      // Whether to use colored output
+	EmitFixes string    // Path to write suggested fixes to as JSON
+	Profile   string    // Document-type specific checks to run, e.g. "wxr"
+	Detect    bool      // Run the checks for the type each document's root element names, without --profile
+	CheckURLs bool      // Request referenced media URLs to confirm they resolve
+	Recover   bool      // Keep parsing after a well-formedness error
+	Progress  io.Writer // Where progress lines are printed, stdout when nil
+
+	Quarantine string // Path to move <item> elements with errors to
+	Cleaned    string // Path to write the document without quarantined items to
+
+	MaxMemory     int64               // Stream inputs that would need more memory than this, 0 for no limit
+	StreamRemote  bool                `json:"-"` // Stream URL inputs whatever their size
+	Spill         bool                `json:"-"` // Keep a temporary copy of a streamed URL input for the report's context
+	SaveDownload  string              `json:"-"` // Path to write the downloaded bytes of a URL input to
+	Target        string              // Import target with extra constraints, e.g. "mysql-utf8mb3"
+	SVGAllowHosts []string            // Hosts SVG content may load resources from
+	EmbeddedSVG   string              // How SVG inside CDATA is checked: xml, html or off
+	CheckHTML     bool                // Parse HTML embedded in CDATA sections
+	CheckDesign   bool                // Check hex colors, SVG and CSS
+	CheckDates    bool                // Validate date formats of feed elements
+	DateElements  dateElementFlags    // Extra ELEMENT=FORMAT date rules
+	Skip          map[string]bool     // Checks left out with --checks or --disable, "basic" for well-formedness
+	Severities    map[string]string   // Severity overrides by check name or rule ID, from the config file
+	FailOn        string              // Least severity that makes the run fail
+	ShowOffsets   bool                // Print the byte offset of each error
+	ByteColumns   bool                // Report columns in bytes instead of characters
+	MaxAllowed    int                 // Failing issues tolerated before the run fails
+	DiffBase      string              `json:"-"` // Git revision; only issues on lines changed since it are reported
+	CustomRules   []CustomRule        // Checks declared in the config file
+	Scopes        map[string][]string // Elements the issues of a check are limited to, by check name, from the config file
+	Plugins       pluginFlags         // External checkers added with --plugin
+	PluginTimeout time.Duration       `json:"-"` // How long each plugin run may take; 0 for no limit
+
+	ErrorFormat *template.Template `json:"-"` // Prints each issue on one line instead of the full report
+	Timings     *timings           `json:"-"` // Collects how long each check takes, with --timings
+	OnError     *errorHook         `json:"-"` // Command run for failing issues, with --on-error-exec
+	Webhook     *webhook           `json:"-"` // Where the summary of the run is posted, with --webhook
 }
 
-// Define color functions 
-var (
-	successColor   = color.New(color.FgGreen).SprintFunc()
-	errorColor     = color.New(color.FgRed).SprintFunc()
-	highlightColor = color.New(color.FgYellow).SprintFunc() 
-	headerColor    = color.New(color.FgCyan).SprintFunc()
-	infoColor      = color.New(color.FgBlue).SprintFunc()
-)
+// progressOut returns the writer for progress lines
+func (opts ValidationOptions) progressOut() io.Writer {
+	if opts.Progress == nil {
+		return os.Stdout
+	}
+	return opts.Progress
+}
+
+// profileCheck runs the structural checks for one document profile
+type profileCheck func(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError
+
+// Document profiles selectable with --profile
+var profiles = map[string]profileCheck{
+	"wxr":     validateWXR,
+	"rss":     validateRSS,
+	"atom":    validateAtom,
+	"podcast": validatePodcast,
+	"sitemap": validateSitemap,
+}
+
+// Define color functions. They print in the styles of the current theme,
+// see theme.go.
+func successColor(a ...interface{}) string   { return successStyle.Sprint(a...) }
+func errorColor(a ...interface{}) string     { return errorStyle.Sprint(a...) }
+func highlightColor(a ...interface{}) string { return highlightStyle.Sprint(a...) }
+func headerColor(a ...interface{}) string    { return headerStyle.Sprint(a...) }
+func infoColor(a ...interface{}) string      { return infoStyle.Sprint(a...) }
+
+// colorMode is the --color flag. In auto mode output is colored only when
+// stdout is a terminal and NO_COLOR is not set; true (always) and false
+// (never) override that. A bare --color means true.
+type colorMode string
+
+func (c *colorMode) String() string { return string(*c) }
+
+func (c *colorMode) Set(s string) error {
+	switch strings.ToLower(s) {
+	case "auto":
+		*c = "auto"
+	case "true", "always", "1":
+		*c = "always"
+	case "false", "never", "0":
+		*c = "never"
+	default:
+		return fmt.Errorf("want auto, always or never")
+	}
+	return nil
+}
+
+func (c *colorMode) IsBoolFlag() bool { return true }
+
+// apply sets whether output is colored. Auto keeps the color package's
+// own detection of NO_COLOR, TERM=dumb and redirected output; the styles
+// record NO_COLOR when created, so forcing a mode sets them one by one.
+func (c colorMode) apply() bool {
+	if c == "auto" {
+		return !color.NoColor
+	}
+	color.NoColor = c == "never"
+	for _, style := range outputStyles() {
+		if color.NoColor {
+			style.DisableColor()
+		} else {
+			style.EnableColor()
+		}
+	}
+	return !color.NoColor
+}
 
 func main() {
+	// Dispatch subcommands; anything else is validated, so bare
+	// "xml_validator file.xml" still works
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "version", "--version", "-version":
+			printVersion()
+			return
+		case "validate":
+			runValidate(os.Args[2:])
+			return
+		case "format":
+			runFormat(os.Args[2:])
+			return
+		case "fix":
+			runFix(os.Args[2:])
+			return
+		case "stats":
+			runStats(os.Args[2:])
+			return
+		case "explain":
+			runExplain(os.Args[2:])
+			return
+		case "rules":
+			runRuleList(os.Args[2:])
+			return
+		case "split":
+			runSplit(os.Args[2:])
+			return
+		case "merge":
+			runMerge(os.Args[2:])
+			return
+		case "bench":
+			runBench(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		}
+	}
+	runValidate(os.Args[1:])
+}
+
+// runValidate implements the "validate" subcommand
+func runValidate(args []string) {
 	// Parse command-line flags
 	opts := ValidationOptions{}
-	flag.IntVar(&opts.MaxErrors, "max-errors", 5, "Maximum number of errors to report")
-	flag.BoolVar(&opts.Debug, "debug", false, "Enable debug output")
-	flag.BoolVar(&opts.Color, "color", true, "Enable colored output")
-	flag.Parse()
+	vfs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	vfs.IntVar(&opts.MaxErrors, "max-errors", 5, "Maximum number of errors to report for each input, 0 for no limit (same as --max-errors-per-file)")
+	vfs.IntVar(&opts.MaxErrors, "max-errors-per-file", 5, "Maximum number of errors to report for each input, 0 for no limit")
+	maxTotalErrors := vfs.Int("max-total-errors", 0, "Stop once this many errors have been reported across all inputs, 0 for no limit")
+	vfs.BoolVar(&opts.Debug, "debug", false, "Enable debug output")
+	colors := colorMode("auto")
+	themeName := vfs.String("theme", "default", "Color theme: default, colorblind (blue/orange instead of green/red), mono (bold and underline only) or solarized")
+	vfs.Var(&colors, "color", "Color output: auto (only on a terminal, and not when NO_COLOR is set), always or never")
+	vfs.BoolVar(&opts.Recover, "recover", false, "Keep parsing after a well-formedness error to report every structural error (up to --max-errors)")
+	vfs.StringVar(&opts.EmitFixes, "emit-fixes", "", "Write suggested fixes for the reported errors to this JSON file")
+	vfs.StringVar(&opts.Quarantine, "quarantine", "", "Move <item> elements that have errors into this file, wrapped in the original channel")
+	vfs.StringVar(&opts.Cleaned, "cleaned", "", "With --quarantine, write the document without the bad items to this file")
+	vfs.StringVar(&opts.Profile, "profile", "", "Run document-type checks instead of those for the detected type (none for no detection): wxr (WordPress export), rss (RSS 2.0), atom (Atom 1.0), podcast (RSS with itunes:/podcast: tags), sitemap (sitemaps.org); or a rule profile bundling checks and severities: strict, minimal, wordpress, svg, design, feed, or one from the config file")
+	vfs.StringVar(&opts.Target, "target", "", "Check constraints of an import target: mysql-utf8mb3 (no 4-byte UTF-8 such as emoji)")
+	vfs.StringVar(&opts.EmbeddedSVG, "embedded-svg", "xml", "How to check SVG inside CDATA (e.g. in content:encoded): xml (strict), html (as a browser parses inline SVG) or off")
+	maxMemory := vfs.String("max-memory", "", "Memory budget (e.g. 512MB); larger inputs are streamed and only get the well-formedness and line-based checks")
+	vfs.BoolVar(&opts.StreamRemote, "stream-remote", false, "Validate a URL input as it downloads instead of reading it into memory first, with the well-formedness and line-based checks only")
+	vfs.StringVar(&opts.SaveDownload, "save-download", "", "Write the downloaded bytes of a URL input to this file, e.g. to attach to a bug report or fix locally")
+	vfs.BoolVar(&opts.Spill, "spill", false, "While streaming a URL input, keep a copy in a temporary file so the report can show the lines of syntax errors; it is deleted afterwards")
+	errorFormat := vfs.String("error-format", "", "Print each issue on one line with this Go template, e.g. '{{.File}}:{{.Line}}:{{.Col}} [{{.Rule}}] {{.Message}}' (fields: File, Line, Col, Offset, Rule, Type, Severity, Message, Content, Fix)")
+	interactive := vfs.Bool("interactive", false, "Review the issues of a local file in a terminal UI, applying suggested fixes or opening them in $EDITOR")
+	vfs.BoolVar(&opts.ShowOffsets, "show-offsets", false, "Also print the byte offset of each error in the document, for dd, head -c and tail -c")
+	vfs.BoolVar(&opts.ByteColumns, "byte-columns", false, "Report columns as byte positions instead of characters")
+	noPager := vfs.Bool("no-pager", false, "Print the report directly instead of through $PAGER when it does not fit on the terminal")
+	timeChecks := vfs.Bool("timings", false, "Print how long each check took, to find the slow ones to --disable")
+	onErrorExec := vfs.String("on-error-exec", "", "Run this command for each issue at the --fail-on severity, e.g. 'notify-send {file}:{line} {message}' (placeholders: {file}, {line}, {col}, {rule}, {type}, {severity}, {message}, {count})")
+	onErrorExecPer := vfs.String("on-error-exec-per", "error", "Run --on-error-exec once per failing issue (error) or once per file with failing issues (file)")
+	webhookURL := vfs.String("webhook", "", "When the run finishes, POST a JSON summary (files, counts, top rules, pass/fail) to this URL, e.g. to notify monitoring of scheduled validations")
+	noCache := vfs.Bool("no-cache", false, "Validate every local file again instead of reusing results for files unchanged since the last run")
+	batch := BatchOptions{}
+	vfs.StringVar(&batch.Manifest, "manifest", "", "Also validate every path or URL listed in this file, one per line")
+	vfs.IntVar(&batch.DownloadConcurrency, "download-concurrency", 4, "With several inputs, how many to download at the same time")
+	vfs.IntVar(&batch.PerHost, "per-host", 2, "With several inputs, how many downloads may run against one host at a time")
+	vfs.IntVar(&batch.Jobs, "jobs", runtime.NumCPU(), "With several inputs, how many to validate at the same time")
+	vfs.Float64Var(&batch.RateLimit, "rate-limit", 0, "With several inputs, start at most this many downloads per second (0 for no limit)")
+	vfs.Float64Var(&batch.HostRateLimit, "rate-limit-per-host", 0, "With several inputs, start at most this many downloads per second from any one host (0 for no limit)")
+	vfs.BoolVar(&batch.Follow, "follow", false, "Also validate the XML resources the inputs link to: the sitemaps of a sitemap index, the feeds of an OPML file, the next pages of a feed")
+	vfs.IntVar(&batch.MaxDepth, "max-depth", 1, "With --follow, how many links away from the inputs to go")
+	vfs.BoolVar(&batch.Summary, "summary", false, "Print one line per input (issue count and worst severity) and the totals instead of every issue")
+	detailsFor := vfs.String("details-for", "", "With --summary, comma-separated inputs whose issues are printed in full (implies --summary)")
+	configPath := vfs.String("config", defaultConfigFile, "Config file with severity overrides and custom rules")
+	severityMap := vfs.String("severity-map", "", "YAML file or URL mapping rule IDs or check names to severities, e.g. a policy shared across repositories; the config file's severities take precedence")
+	vfs.IntVar(&opts.MaxAllowed, "max-allowed", 0, "Only fail when more than this many issues are at the --fail-on severity, e.g. a budget of known issues during a cleanup")
+	vfs.StringVar(&opts.FailOn, "fail-on", severityError, "Exit with status 1 for issues of this severity or worse: error or warning")
+	vfs.StringVar(&opts.DiffBase, "diff-base", "", "Only report issues on lines added or modified since this git revision, e.g. origin/main, to adopt the validator in a repository of legacy XML")
+	warningsAsErrors := vfs.Bool("warnings-as-errors", false, "Report every issue as an error, ignoring warning severities in the config file")
+	checks := vfs.String("checks", "", "Comma-separated checks to run, leaving out the rest (basic is well-formedness; see the rules command)")
+	disable := vfs.String("disable", "", "Comma-separated checks to leave out, e.g. hex-colors,svg")
+	svgAllowHosts := vfs.String("svg-allow-hosts", "", "Comma-separated hosts SVG images, <use> and CSS url() references may load from without being reported")
+	vfs.BoolVar(&opts.CheckHTML, "check-html", false, "Parse HTML inside CDATA sections and report unclosed, stray or misnested tags")
+	vfs.BoolVar(&opts.CheckDesign, "check-design", false, "Check hex color codes, SVG markup and CSS syntax (also enabled by --profile=svg and --profile=design)")
+	vfs.BoolVar(&opts.CheckDates, "check-dates", false, "Check that pubDate, updated, wp:post_date and similar elements hold correctly formatted dates")
+	vfs.Var(&opts.DateElements, "date-format", "Also check ELEMENT against FORMAT (rfc822, rfc3339, w3c, wordpress); repeatable, implies --check-dates")
+	vfs.BoolVar(&opts.CheckURLs, "check-urls", false, "Send HEAD requests for WXR attachment and RSS enclosure URLs, reporting broken links and enclosure length/type mismatches")
+	vfs.Var(&opts.Plugins, "plugin", "Also run the executable at PATH with the document, or with each ELEMENT's content, on stdin; it prints a JSON array of {line, column, offset, message, content} issues. Repeatable: [ELEMENT=]PATH")
+	vfs.DurationVar(&opts.PluginTimeout, "plugin-timeout", 30*time.Second, "Report a plugin that has not finished with a document or element within this time as failed (0 for no limit)")
+	addFetchFlags(vfs)
+	parseFlags(vfs, args)
+	fetchOptions.Debug = opts.Debug
+	registerPlugins(opts.Plugins)
+
+	if opts.MaxErrors < 0 || *maxTotalErrors < 0 || opts.MaxAllowed < 0 {
+		fmt.Println("❌ --max-errors, --max-errors-per-file, --max-total-errors and --max-allowed must be 0 or more")
+		os.Exit(exitUsage)
+	}
+	// No one input can report more than the total allows
+	if total := *maxTotalErrors; total > 0 && (opts.MaxErrors == 0 || opts.MaxErrors > total) {
+		opts.MaxErrors = total
+	}
+	batch.MaxTotalErrors = *maxTotalErrors
+	if opts.DiffBase != "" {
+		if err := checkDiffBase(opts.DiffBase); err != nil {
+			fmt.Printf("❌ Invalid --diff-base: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+	if batch.DetailsFor = splitList(*detailsFor); len(batch.DetailsFor) > 0 {
+		batch.Summary = true
+	}
+
+	if *errorFormat != "" {
+		tmpl, err := parseErrorFormat(*errorFormat)
+		if err != nil {
+			fmt.Printf("❌ Invalid --error-format: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		opts.ErrorFormat = tmpl
+	}
+
+	if *onErrorExec != "" {
+		hook, err := parseErrorHook(*onErrorExec, *onErrorExecPer)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(exitUsage)
+		}
+		opts.OnError = hook
+	}
+	if *webhookURL != "" {
+		hook, err := newWebhook(*webhookURL)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(exitUsage)
+		}
+		opts.Webhook = hook
+	}
+
+	for _, host := range strings.Split(*svgAllowHosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			opts.SVGAllowHosts = append(opts.SVGAllowHosts, strings.ToLower(host))
+		}
+	}
+
+	if len(opts.DateElements) > 0 {
+		opts.CheckDates = true
+	}
+
+	if opts.Cleaned != "" && opts.Quarantine == "" {
+		fmt.Println("❌ --cleaned requires --quarantine")
+		os.Exit(exitUsage)
+	}
+
+	if *maxMemory != "" {
+		budget, err := parseByteSize(*maxMemory)
+		if err != nil {
+			fmt.Printf("❌ Invalid --max-memory: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		opts.MaxMemory = budget
+	}
+
+	if opts.EmbeddedSVG != "xml" && opts.EmbeddedSVG != "html" && opts.EmbeddedSVG != "off" {
+		fmt.Printf("❌ Unknown --embedded-svg mode %q (want xml, html or off)\n", opts.EmbeddedSVG)
+		os.Exit(exitUsage)
+	}
+
+	if opts.Target != "" && opts.Target != "mysql-utf8mb3" {
+		fmt.Printf("❌ Unknown target %q\n", opts.Target)
+		os.Exit(exitUsage)
+	}
+
+	explicit := make(map[string]bool)
+	vfs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	config, err := loadConfig(*configPath, explicit["config"])
+	if err != nil {
+		fmt.Printf("❌ Error reading config: %v\n", err)
+		os.Exit(configExitCode(err))
+	}
+	registerCustomRules(config.Rules)
+	opts.CustomRules = config.Rules
+	opts.Scopes = config.Scope
+	opts.Severities = config.Severity
+	if *severityMap != "" {
+		data, err := readFileContent(*severityMap)
+		if err != nil {
+			fmt.Printf("❌ Error reading severity map: %v\n", err)
+			os.Exit(inputExitCode(err))
+		}
+		policy, err := parseSeverityMap(*severityMap, data, config.Rules)
+		if err != nil {
+			fmt.Printf("❌ Invalid severity map: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		opts.Severities = mergeSeverities(policy, opts.Severities)
+	}
+
+	// Without --profile each document gets the checks for its type;
+	// --profile=none turns that off
+	opts.Detect = !explicit["profile"]
+	if opts.Profile == "none" {
+		opts.Profile = ""
+	}
+
+	// A rule profile stands for a set of the flags below, which take
+	// precedence over it
+	if _, ok := profiles[opts.Profile]; opts.Profile != "" && !ok {
+		rp, err := resolveRuleProfile(opts.Profile, config.Profiles)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(exitUsage)
+		}
+		opts.Profile = rp.Document
+		opts.CheckHTML = opts.CheckHTML || rp.CheckHTML
+		opts.CheckDesign = opts.CheckDesign || rp.CheckDesign
+		opts.CheckDates = opts.CheckDates || rp.CheckDates
+		if *checks == "" {
+			*checks = strings.Join(rp.Checks, ",")
+		}
+		*disable = strings.Join(append(rp.Disable, splitList(*disable)...), ",")
+		opts.Severities = mergeSeverities(rp.Severity, opts.Severities)
+		if !explicit["fail-on"] && rp.FailOn != "" {
+			opts.FailOn = rp.FailOn
+		}
+	}
+	if *warningsAsErrors {
+		// Like -Werror: nothing is downgraded to a warning
+		opts.Severities = nil
+	}
+
+	if opts.FailOn != severityError && opts.FailOn != severityWarning {
+		fmt.Printf("❌ Unknown --fail-on severity %q (want error or warning)\n", opts.FailOn)
+		os.Exit(exitUsage)
+	}
+
+	if *checks != "" || *disable != "" {
+		skip, err := skippedChecks(opts, splitList(*checks), splitList(*disable))
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(exitUsage)
+		}
+		opts.Skip = skip
+	}
 
 	// Apply color setting
-	if !opts.Color {
-		// Disable all colors if the color flag is false
-		color.NoColor = true
+	if err := applyTheme(*themeName); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if paged := os.Getenv(pagedEnv); paged != "" && colors == "auto" {
+		// Run by runPaged: stdout is the pager, color as the terminal would
+		colors = colorMode(paged)
+	}
+	opts.Color = colors.apply()
+	if !*noPager && !*interactive {
+		runPaged(opts.Color)
 	}
 
 	// Check for required file argument
-	args := flag.Args()
+	args = vfs.Args()
+	if batch.Manifest != "" {
+		listed, err := readManifest(batch.Manifest)
+		if err != nil {
+			fmt.Printf("❌ Error reading manifest: %v\n", err)
+			opts.Webhook.exit(inputExitCode(err))
+		}
+		args = append(args, listed...)
+	}
+	args, err = expandInputs(args)
+	if err != nil {
+		fmt.Printf("❌ Error listing inputs: %v\n", err)
+		opts.Webhook.exit(exitIO)
+	}
+	if batch.Follow {
+		if batch.MaxDepth < 1 {
+			fmt.Println("❌ --max-depth must be at least 1")
+			os.Exit(exitUsage)
+		}
+		args = followLinks(args, &batch)
+	}
+	var cache *resultCache
+	if *timeChecks {
+		// Cached results would leave the checks untimed
+		opts.Timings = newTimings()
+	} else if !*noCache && len(opts.Plugins) == 0 {
+		// Plugin results are not cached: they can change while the document does not
+		cache = openResultCache()
+	}
 	if len(args) < 1 {
-		fmt.Println("Usage: xml_validator [--max-errors=N] [--debug] [--color] <xml-file-or-URL>")
-		os.Exit(1)
+		printUsage()
+		os.Exit(exitUsage)
+	}
+
+	if len(args) > 1 || batch.Summary {
+		if opts.Quarantine != "" || opts.EmitFixes != "" || opts.SaveDownload != "" || *interactive {
+			fmt.Println("❌ --quarantine, --emit-fixes, --save-download and --interactive work on a single input, without --summary")
+			os.Exit(exitUsage)
+		}
+		runBatch(args, opts, batch, cache)
+		return
 	}
 
 	filepath := args[0]
+	if *interactive && isRemote(filepath) {
+		fmt.Println("❌ --interactive needs a local file to apply fixes to")
+		os.Exit(exitUsage)
+	}
+	if opts.SaveDownload != "" && !isRemote(filepath) {
+		fmt.Println("❌ --save-download needs a URL input")
+		os.Exit(exitUsage)
+	}
 	fmt.Printf("Validating XML: %s\n", filepath)
-	fmt.Printf("Will report up to %d errors\n", opts.MaxErrors)
+	if opts.MaxErrors > 0 {
+		fmt.Printf("Will report up to %d errors\n", opts.MaxErrors)
+	} else {
+		fmt.Println("Will report all errors")
+	}
 
-	// Read the file content (local or remote)
-	content, err := readFileContent(filepath)
+	// Read the file content (local or remote), unless it is too large for
+	// the memory budget
+	var saved *os.File
+	var save io.Writer // Left nil, not a nil *os.File, without --save-download
+	if opts.SaveDownload != "" {
+		if saved, err = os.Create(opts.SaveDownload); err != nil {
+			fmt.Printf("❌ Cannot save the download: %v\n", err)
+			opts.Webhook.exit(exitIO)
+		}
+		save = saved
+	}
+	content, stream, size, err := loadInput(filepath, opts.MaxMemory, opts.StreamRemote && isRemote(filepath), save)
 	if err != nil {
+		if saved != nil {
+			saved.Close()
+			os.Remove(saved.Name())
+		}
 		fmt.Printf("❌ Error reading file: %v\n", err)
-		os.Exit(1)
+		opts.Webhook.add(filepath, nil, err, opts.FailOn)
+		opts.Webhook.exit(inputExitCode(err))
+	}
+
+	// Run the validation. Quarantining and reviewing need every bad item,
+	// not just the ones that fit in the report, a budget needs the full
+	// count, and with severity overrides an error may follow the warnings
+	// that fill it, or follow the ones on unchanged lines.
+	validateOpts := opts
+	if opts.Quarantine != "" || *interactive || opts.MaxAllowed > 0 || len(opts.Severities) > 0 || opts.DiffBase != "" {
+		validateOpts.MaxErrors = 0
+	}
+	// Shared by the checks and the reports; empty for a streamed input
+	ix := indexLines(content)
+	var allErrors []ValidationError
+	if stream != nil {
+		if opts.Quarantine != "" || *interactive {
+			fmt.Println("❌ --quarantine and --interactive need the whole document in memory; raise --max-memory")
+			os.Exit(exitUsage)
+		}
+		if opts.StreamRemote && isRemote(filepath) {
+			fmt.Printf("%s Streaming the download with the well-formedness and line-based checks only\n", highlightColor("Note:"))
+		} else {
+			described := "of unknown size"
+			if size >= 0 {
+				described = formatMB(size)
+			}
+			fmt.Printf("%s Input %s exceeds --max-memory; streaming it with the well-formedness and line-based checks only\n", highlightColor("Note:"), described)
+		}
+		if !isRemote(filepath) {
+			allErrors = validateStream(stream, validateOpts)
+			fillStreamContext(filepath, allErrors)
+		} else if opts.Spill {
+			spill, err := os.CreateTemp("", "xml-validator-*.xml")
+			if err != nil {
+				fmt.Printf("❌ Cannot create the spill file: %v\n", err)
+				opts.Webhook.exit(exitIO)
+			}
+			allErrors = validateStream(io.TeeReader(stream, spill), validateOpts)
+			spill.Close()
+			fillStreamContext(spill.Name(), allErrors)
+			os.Remove(spill.Name())
+		} else {
+			allErrors = validateStream(stream, validateOpts)
+		}
+		stream.Close()
+	} else if cached, ok := cache.lookup(filepath, content, validateOpts); ok {
+		fmt.Printf("%s Unchanged since the last run; showing cached results (use --no-cache to validate again)\n", highlightColor("Note:"))
+		allErrors = cached
+	} else {
+		allErrors = validateXML(content, ix, validateOpts)
+		cache.store(filepath, content, validateOpts, allErrors)
+		if err := cache.save(); err != nil && opts.Debug {
+			fmt.Printf("Cannot save the result cache: %v\n", err)
+		}
+	}
+
+	opts.Timings.print(os.Stdout)
+
+	if opts.DiffBase != "" && !isRemote(filepath) {
+		allErrors = onChangedLines(filepath, opts.DiffBase, allErrors)
+		fmt.Printf("%s Reporting only issues on lines changed since %s\n", highlightColor("Note:"), opts.DiffBase)
+	}
+
+	if saved != nil {
+		// Streamed downloads are only complete once validated
+		if err := saved.Close(); err != nil {
+			fmt.Printf("❌ Cannot save the download: %v\n", err)
+			opts.Webhook.exit(exitIO)
+		}
+		fmt.Printf("%s Saved the downloaded bytes to %s\n", highlightColor("Note:"), opts.SaveDownload)
 	}
 
-	// Run the validation
-	allErrors := validateXML(content, opts)
+	if opts.Quarantine != "" && len(allErrors) > 0 {
+		moved, total, err := writeQuarantine(content, allErrors, opts.Quarantine, opts.Cleaned)
+		if err != nil {
+			fmt.Printf("❌ Cannot quarantine items: %v\n", err)
+			opts.Webhook.exit(exitIO)
+		}
+		fmt.Printf("%s Quarantined %d of %d items to %s\n", highlightColor("Note:"), moved, total, opts.Quarantine)
+		if opts.Cleaned != "" {
+			fmt.Printf("%s Wrote the remaining %d items to %s\n", highlightColor("Note:"), total-moved, opts.Cleaned)
+		}
+	}
+
+	applySeverities(allErrors, opts.Severities)
+	opts.OnError.run(filepath, ix, allErrors, opts)
+	opts.Webhook.add(filepath, allErrors, nil, opts.FailOn)
+
+	if opts.EmitFixes != "" {
+		if err := writeSuggestedFixes(opts.EmitFixes, filepath, ix, allErrors, opts.ByteColumns); err != nil {
+			fmt.Printf("❌ Error writing fixes: %v\n", err)
+			opts.Webhook.exit(exitIO)
+		}
+	}
 	
 	// Display results
 	if len(allErrors) == 0 {
 		fmt.Println(successColor("✅ XML is well-formed!"))
-		os.Exit(0)
+		opts.Webhook.exit(exitOK)
+	}
+
+	if *interactive {
+		remaining, err := runReview(filepath, ix, allErrors, validateOpts)
+		if err != nil {
+			fmt.Printf("❌ Review failed: %v\n", err)
+			opts.Webhook.exit(exitIO)
+		}
+		if overBudget(countFailing(remaining, opts.FailOn), opts.MaxAllowed) {
+			opts.Webhook.exit(exitValidation)
+		}
+		opts.Webhook.exit(exitOK)
 	}
 
 	// Report errors
-	fmt.Printf("%s Found %d XML issues (showing up to %d):\n", errorColor("❌"), len(allErrors), opts.MaxErrors)
-	fmt.Println(headerColor("----------------------------------------"))
+	displayErrors(filepath, ix, allErrors, opts)
 	
-	maxToShow := opts.MaxErrors
-	if maxToShow > len(allErrors) {
-		maxToShow = len(allErrors)
+	// Print correction tips
+	if opts.ErrorFormat == nil {
+		printCorrectionTips()
 	}
-	
-	for i := 0; i < maxToShow; i++ {
-		displayError(content, allErrors[i], i+1)
+	if overBudget(countFailing(allErrors, opts.FailOn), opts.MaxAllowed) {
+		opts.Webhook.exit(exitValidation)
 	}
-	
-	if len(allErrors) > opts.MaxErrors {
-		fmt.Printf("\n%s Found more errors than displayed (%d total). Run with --max-errors=%d to see all.\n", 
-			infoColor("Note:"), len(allErrors), len(allErrors))
+	opts.Webhook.send(exitOK)
+}
+
+// printUsage lists the commands and their flags
+func printUsage() {
+	fmt.Println("Usage: xml_validator [validate] [--max-errors=N] [--max-errors-per-file=N] [--max-total-errors=N] [--recover] [--debug] [--color=WHEN] [--theme=NAME] [--error-format=TEMPLATE] [--interactive] [--no-pager] [--show-offsets] [--byte-columns] [--emit-fixes=FILE] [--quarantine=FILE [--cleaned=FILE]] [--profile=NAME] [--check-urls] [--check-dates] [--check-html] [--check-design] [--target=mysql-utf8mb3] [--svg-allow-hosts=HOSTS] [--embedded-svg=MODE] [--config=FILE] [--severity-map=FILE] [--fail-on=SEVERITY] [--max-allowed=N] [--diff-base=REV] [--warnings-as-errors] [--checks=LIST] [--disable=LIST] [--max-memory=SIZE] [--stream-remote [--spill]] [--manifest=FILE] [--download-concurrency=N] [--per-host=N] [--jobs=N] [--rate-limit=N] [--rate-limit-per-host=N] [--follow [--max-depth=N]] [--summary] [--details-for=LIST] [--timings] [--plugin=[ELEMENT=]PATH [--plugin-timeout=DURATION]] [--on-error-exec=CMD [--on-error-exec-per=error|file]] [--webhook=URL] [--no-cache] <xml-file-dir-or-URL>...")
+	fmt.Println("       xml_validator fix [--svg-self-closing] [--hex-colors=MODE] [--config=FILE] [--output-encoding=ENC] [--output=FILE | --in-place] <xml-file-or-URL>")
+	fmt.Println("       xml_validator format [--strip-comments] [--strip-pi] [--output=FILE] <xml-file-or-URL>")
+	fmt.Println("       xml_validator stats [--top=N] <xml-file-or-URL>")
+	fmt.Println("       xml_validator rules [--format=text|json] [--config=FILE]")
+	fmt.Println("       xml_validator rules test [--config=FILE] [--verbose] <rule-id> <fixture-dir>")
+	fmt.Println("       xml_validator explain [<rule-id>]")
+	fmt.Println("       xml_validator split [--max-size=SIZE] [--output-dir=DIR] <wxr-file-or-URL>")
+	fmt.Println("       xml_validator merge [--output=FILE] <wxr-file-or-URL> <wxr-file-or-URL>...")
+	fmt.Println("       xml_validator version")
+	fmt.Println("       xml_validator bench [--iterations=N] [--profile=NAME] [--target=TARGET] [--check-html] [--check-design] [--check-dates] <dir>")
+	fmt.Println("       xml_validator serve [--listen=ADDR] [--grpc-listen=ADDR] [--config=FILE] [--max-body=SIZE] [--max-errors=N] [--max-concurrent=N]")
+	fmt.Println("Downloading URL inputs (validate, fix, format, stats, split, merge): [--timeout=DURATION] [--connect-timeout=DURATION] [--retries=N] [--header='NAME: VALUE']... [--user-agent=UA] [--basic-auth=USER:PASS | --bearer-token=TOKEN] [--proxy=URL] [--ca-cert=FILE] [--client-cert=FILE --client-key=FILE] [--insecure] [--max-redirects=N | --no-follow-redirects] [--http-cache]")
+}
+
+// splitList returns the non-empty, trimmed items of a comma-separated list
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-	
-	// Print correction tips
-	printCorrectionTips()
-	os.Exit(1)
+	return items
+}
+
+// isRemote reports whether the input argument is a URL rather than a path
+func isRemote(filepath string) bool {
+	scheme, _, ok := strings.Cut(filepath, "://")
+	return ok && fetchers[strings.ToLower(scheme)] != nil
+}
+
+// isHTTP reports whether s is an http or https URL
+func isHTTP(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
 }
 
 // readFileContent reads content from a local file or remote URL
 func readFileContent(filepath string) ([]byte, error) {
-	if strings.HasPrefix(filepath, "http://") || strings.HasPrefix(filepath, "https://") {
-		fmt.Println(infoColor("Downloading from URL..."))
-		resp, err := http.Get(filepath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to download file: %v", err)
-		}
-		defer resp.Body.Close()
-		
-		if resp.StatusCode != 200 {
-			return nil, fmt.Errorf("HTTP error: %s", resp.Status)
-		}
-		
-		return io.ReadAll(resp.Body)
-	} else {
-		fmt.Println(infoColor("Reading local file..."))
-		return os.ReadFile(filepath)
-	}
+	content, _, _, err := loadInput(filepath, 0, false, nil)
+	return content, err
 }
 
-// validateXML performs all validation checks on the XML content
-func validateXML(content []byte, opts ValidationOptions) []ValidationError {
+// validateXML performs all validation checks on the XML content, whose
+// line index is ix. Every check looks up positions in it, as do the
+// reports of the errors.
+func validateXML(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	// First use Go's XML parser for basic well-formedness
 	var allErrors []ValidationError
-	
-	// 1. First use Go's XML parser for basic well-formedness
-	basicErrors := validateBasicXML(content)
-	allErrors = append(allErrors, basicErrors...)
-	if len(allErrors) >= opts.MaxErrors && opts.MaxErrors > 0 {
-		return allErrors[:opts.MaxErrors]
-	}
-	
-	// If there are no basic XML errors, run additional checks
-	if len(basicErrors) == 0 {
-		fmt.Println(successColor("Basic XML validation passed. Performing additional checks..."))
-		
-		// 2. Check CDATA sections
-		fmt.Println(infoColor("Checking CDATA sections..."))
-		cdataErrors := validateCDATASections(content, opts)
-		allErrors = append(allErrors, cdataErrors...)
-		if len(allErrors) >= opts.MaxErrors && opts.MaxErrors > 0 {
-			return allErrors[:opts.MaxErrors]
+	if opts.Detect {
+		if kind := detectDocumentType(content); kind != "" {
+			fmt.Fprintln(opts.progressOut(), infoColor(fmt.Sprintf("Detected %s; running its checks (--profile overrides)", documentTypes[kind])))
+			opts = opts.withDocumentType(kind)
 		}
-		
-		// 3. Check for control characters
-		fmt.Println(infoColor("Checking for control characters..."))
-		controlErrors := validateControlCharacters(content, opts)
-		allErrors = append(allErrors, controlErrors...)
-		if len(allErrors) >= opts.MaxErrors && opts.MaxErrors > 0 {
-			return allErrors[:opts.MaxErrors]
+	}
+	if opts.Skip["basic"] {
+		allErrors = runRules(content, ix, validationRules(opts), opts)
+	} else {
+		start := time.Now()
+		if opts.Recover {
+			allErrors = recoverBasicXML(content, ix, opts.MaxErrors)
+		} else {
+			allErrors = validateBasicXML(content, ix)
 		}
-		
-		// 4. Check hex color codes
-		fmt.Println(infoColor("Checking hex color codes..."))
-		hexErrors := validateHexColors(content, opts)
-		allErrors = append(allErrors, hexErrors...)
+		opts.Timings.since("basic", start)
 		if len(allErrors) >= opts.MaxErrors && opts.MaxErrors > 0 {
-			return allErrors[:opts.MaxErrors]
+			allErrors = allErrors[:opts.MaxErrors]
+		} else if len(allErrors) == 0 {
+			// If there are no basic XML errors, run additional checks
+			fmt.Fprintln(opts.progressOut(), successColor("Basic XML validation passed. Performing additional checks..."))
+			allErrors = runRules(content, ix, validationRules(opts), opts)
 		}
-		
-		// 5. Check SVG syntax
-		fmt.Println(infoColor("Checking SVG syntax..."))
-		svgErrors := validateSVG(content, opts)
-		allErrors = append(allErrors, svgErrors...)
-	}
-	
-	// Limit errors if needed
-	if opts.MaxErrors > 0 && len(allErrors) > opts.MaxErrors {
-		return allErrors[:opts.MaxErrors]
 	}
 	
+	setOffsets(ix, allErrors)
+	opts.Timings.validated()
 	return allErrors
 }
 
-// validateBasicXML uses Go's XML parser to check well-formedness
-func validateBasicXML(content []byte) []ValidationError {
+// setOffsets sets the byte offset of each error from its line and column
+func setOffsets(ix *lineIndex, errs []ValidationError) {
+	for i := range errs {
+		errs[i].Offset = ix.offset(errs[i].LineNumber, errs[i].Column)
+	}
+}
+
+// appendNewErrors appends the errors not already present in existing, so
+// checks that overlap (a profile and --check-dates both checking pubDate)
+// report each problem once
+func appendNewErrors(existing, errs []ValidationError) []ValidationError {
+	type key struct {
+		line, col int
+		message   string
+	}
+	seen := make(map[key]bool, len(existing))
+	for _, e := range existing {
+		seen[key{e.LineNumber, e.Column, e.Message}] = true
+	}
+	for _, e := range errs {
+		if !seen[key{e.LineNumber, e.Column, e.Message}] {
+			existing = append(existing, e)
+		}
+	}
+	return existing
+}
+
+// validateBasicXML uses Go's XML parser to check well-formedness; ix is
+// the line index of content
+func validateBasicXML(content []byte, ix *lineIndex) []ValidationError {
 	var errors []ValidationError
 	
-	decoder := xml.NewDecoder(bytes.NewReader(content))
+	bar := newProgressBar("Parsing", int64(len(content)), 1)
+	defer bar.finish()
+	decoder := xml.NewDecoder(&progressReader{r: bytes.NewReader(content), bar: bar})
 	
 	for {
 		token, err := decoder.Token()
@@ -194,7 +755,13 @@ func validateBasicXML(content []byte) []ValidationError {
 			// Try to extract error location
 			syntaxErr, ok := err.(*xml.SyntaxError)
 			if ok {
-				line, col, lineContent := findErrorPosition(content, int(syntaxErr.Line))
+				line, col, lineContent := findErrorPosition(ix, int(decoder.InputOffset()))
+				if line != syntaxErr.Line {
+					// The decoder stopped on a later line (e.g. at the end of
+					// the input), so only the line it reports is known
+					line, col = syntaxErr.Line, 0
+					lineContent = string(ix.line(line))
+				}
 				errors = append(errors, ValidationError{
 					LineNumber: line,
 					Column:     col,
@@ -222,315 +789,457 @@ func validateBasicXML(content []byte) []ValidationError {
 	return errors
 }
 
-// validateCDATASections checks for various CDATA section issues
-func validateCDATASections(content []byte, opts ValidationOptions) []ValidationError {
-	var errors []ValidationError
-	lines := bytes.Split(content, []byte("\n"))
-	
-	// Define regex patterns for various CDATA issues
-	reCDATAWithSpecialChar := regexp.MustCompile(`<!\[CDATA\[[^a-zA-Z0-9 ]`)
-	reCDATAWithExclamation := regexp.MustCompile(`<!\[CDATA\[!`)
-	reUnclosedCDATA := regexp.MustCompile(`<!\[CDATA\[(?:(?!\]\]>).)*$`)
-	reNestedCDATA := regexp.MustCompile(`<!\[CDATA\[.*<!\[CDATA\[`)
-	reMultiClosingCDATA := regexp.MustCompile(`<!\[CDATA\[.*\]\]>.*\]\]>`)
-	reEmptyCDATA := regexp.MustCompile(`<!\[CDATA\[\]\]>`)
-	
-	for i, line := range lines {
-		lineStr := string(line)
-		
-		// 1. Check for special characters after CDATA opening
-		if matches := reCDATAWithSpecialChar.FindStringIndex(lineStr); matches != nil {
-			badChar := lineStr[matches[0]+9] // Character after <![CDATA[
-			errors = append(errors, ValidationError{
-				LineNumber: i + 1,
-				Column:     matches[0] + 9,
-				Line:       lineStr,
-				ErrorType:  "Special character after CDATA opening",
-				Message:    fmt.Sprintf("Special character '%c' found immediately after CDATA opening", badChar),
-				Content:    "<![CDATA[" + string(badChar),
-			})
-		}
-		
-		// 2. Check specifically for exclamation marks (common in WP exports)
-		if matches := reCDATAWithExclamation.FindStringIndex(lineStr); matches != nil {
-			errors = append(errors, ValidationError{
-				LineNumber: i + 1,
-				Column:     matches[0] + 9,
-				Line:       lineStr,
-				ErrorType:  "Exclamation mark after CDATA opening",
-				Message:    "Exclamation mark found immediately after CDATA opening",
-				Content:    "<![CDATA[!",
-			})
-		}
-		
-		// 3. Check for unclosed CDATA sections
-		if matches := reUnclosedCDATA.FindStringIndex(lineStr); matches != nil {
-			errors = append(errors, ValidationError{
-				LineNumber: i + 1,
-				Column:     matches[0],
-				Line:       lineStr,
-				ErrorType:  "Unclosed CDATA section",
-				Message:    "CDATA section is not properly closed with ]]>",
-				Content:    lineStr[matches[0]:],
-			})
-		}
-		
-		// 4. Check for nested CDATA sections
-		if matches := reNestedCDATA.FindStringIndex(lineStr); matches != nil {
-			errors = append(errors, ValidationError{
-				LineNumber: i + 1,
-				Column:     matches[0],
-				Line:       lineStr,
-				ErrorType:  "Nested CDATA sections",
-				Message:    "CDATA sections cannot be nested",
-				Content:    lineStr[matches[0]:matches[1]],
-			})
-		}
-		
-		// 5. Check for multiple CDATA closing sequences
-		if matches := reMultiClosingCDATA.FindStringIndex(lineStr); matches != nil {
-			errors = append(errors, ValidationError{
-				LineNumber: i + 1,
-				Column:     matches[0],
-				Line:       lineStr,
-				ErrorType:  "Multiple CDATA closing sequences",
-				Message:    "Found multiple ']]>' sequences in a single CDATA block",
-				Content:    lineStr[matches[0]:matches[1]],
-			})
-		}
-		
-		// 6. Check for empty CDATA sections
-		if matches := reEmptyCDATA.FindStringIndex(lineStr); matches != nil {
-			errors = append(errors, ValidationError{
-				LineNumber: i + 1,
-				Column:     matches[0],
-				Line:       lineStr,
-				ErrorType:  "Empty CDATA section",
-				Message:    "CDATA section is empty",
-				Content:    "<![CDATA[]]>",
-			})
-		}
-		
-		// Stop if we've reached max errors
-		if opts.MaxErrors > 0 && len(errors) >= opts.MaxErrors {
-			break
-		}
+// CDATA and comment delimiters, as bytes so lines can be searched without copying
+var (
+	cdataOpen  = []byte("<![CDATA[")
+	cdataClose = []byte("]]>")
+	cdataEmpty = []byte("<![CDATA[]]>")
+
+	commentOpen  = []byte("<!--")
+	commentClose = []byte("-->")
+)
+
+// cdataSafeFirst marks the bytes that may directly follow "<![CDATA["
+// without being reported as a special character
+var cdataSafeFirst = func() (t [256]bool) {
+	for c := '0'; c <= '9'; c++ {
+		t[c] = true
 	}
-	
-	return errors
+	for c := 'a'; c <= 'z'; c++ {
+		t[c], t[c-'a'+'A'] = true, true
+	}
+	t[' '], t['\t'], t['\r'] = true, true, true
+	return t
+}()
+
+// cdataScanner checks CDATA sections for various issues. It follows the
+// document line by line, keeping track of open sections and comments, so
+// a section may open on one line and close on a later one. It works on
+// the lines' bytes and only copies a line into a string once it has
+// something to report, or when a section opened on it is still open at
+// its end.
+type cdataScanner struct {
+	inSection bool // Inside a CDATA section
+	inComment bool // Inside a comment, where markers are text
+	nested    bool // The open section has been reported as nested
+	closed    bool // A section closed and no other opened since, so ]]> is stray
+
+	// Where the open section starts, for reporting it unclosed at the end
+	openLine   int
+	openCol    int // 0-based
+	openOffset int
+	openText   string // The line, copied once it ends with the section open
+}
+
+// newCDATAScanner returns a scanner for the "cdata" check. Its line method
+// is fed every line in order; end reports what is still open afterwards.
+func newCDATAScanner() *cdataScanner {
+	return &cdataScanner{}
 }
 
-// validateControlCharacters checks for control characters in XML
-func validateControlCharacters(content []byte, opts ValidationOptions) []ValidationError {
+// line checks one line, continuing from the state the previous lines
+// left. Line 1 starts a new document.
+func (s *cdataScanner) line(lineNum, lineStart int, line []byte) []ValidationError {
+	if lineNum == 1 {
+		*s = cdataScanner{}
+	}
 	var errors []ValidationError
-	lines := bytes.Split(content, []byte("\n"))
-	
-	for i, line := range lines {
-		lineStr := string(line)
-		
-		// Look for control characters (except tab, CR, LF)
-		for j, r := range lineStr {
-			if r < 32 && r != '\t' && r != '\r' && r != '\n' {
-				// Found a control character
-				errors = append(errors, ValidationError{
-					LineNumber: i + 1,
-					Column:     j + 1,
-					Line:       lineStr,
-					ErrorType:  "Control character",
-					Message:    fmt.Sprintf("Control character (hex 0x%02X) found", r),
-					Content:    string(r),
-				})
-				
-				// Stop checking this line if we found a control character
-				break
+	report := func(col int, errorType, message, content string) {
+		errors = append(errors, ValidationError{
+			LineNumber: lineNum,
+			Column:     col + 1,
+			ErrorType:  errorType,
+			Message:    message,
+			Content:    content,
+		})
+	}
+
+	for i := 0; i < len(line); {
+		switch {
+		case s.inComment:
+			end := nextIndex(line, i, commentClose)
+			if end < 0 {
+				i = len(line)
+				continue
+			}
+			s.inComment = false
+			i = end + len(commentClose)
+
+		case s.inSection:
+			// The first ]]> ends the section, whatever opened before it
+			end := nextIndex(line, i, cdataClose)
+			if open := nextIndex(line, i, cdataOpen); open >= 0 && (end < 0 || open < end) {
+				if !s.nested {
+					report(open, "Nested CDATA sections", "CDATA sections cannot be nested", string(cdataOpen))
+					s.nested = true
+				}
+				i = open + len(cdataOpen)
+				continue
+			}
+			if end < 0 {
+				i = len(line)
+				continue
+			}
+			s.inSection, s.closed = false, true
+			i = end + len(cdataClose)
+
+		default:
+			open := nextIndex(line, i, cdataOpen)
+			comment := nextIndex(line, i, commentOpen)
+			stray := -1
+			if s.closed {
+				stray = nextIndex(line, i, cdataClose)
+			}
+			next := -1
+			for _, at := range []int{open, comment, stray} {
+				if at >= 0 && (next < 0 || at < next) {
+					next = at
+				}
+			}
+
+			switch next {
+			case -1:
+				i = len(line)
+			case comment:
+				s.inComment = true
+				i = comment + len(commentOpen)
+			case stray:
+				report(stray, "Multiple CDATA closing sequences",
+					"Found multiple ']]>' sequences in a single CDATA block", string(cdataClose))
+				s.closed = false
+				i = stray + len(cdataClose)
+			default:
+				// "]]><![CDATA[" splits one section in two, usually to
+				// escape a ]]> in the content, so what follows continues it
+				split := open >= len(cdataClose) && bytes.Equal(line[open-len(cdataClose):open], cdataClose)
+				s.inSection, s.nested, s.closed = true, false, false
+				s.openLine, s.openCol, s.openOffset = lineNum, open, lineStart+open
+				s.checkOpening(line, open, split, report)
+				i = open + len(cdataOpen)
 			}
 		}
-		
-		// Stop if we've reached max errors
-		if opts.MaxErrors > 0 && len(errors) >= opts.MaxErrors {
-			break
+	}
+
+	if s.inSection && s.openLine == lineNum {
+		// Keep what an unclosed report needs before the line is reused
+		s.openText = string(line)
+	}
+	if len(errors) > 0 {
+		lineStr := string(line)
+		for i := range errors {
+			errors[i].Line = lineStr
 		}
 	}
-	
 	return errors
 }
 
-// validateHexColors checks for malformed hex color codes
-func validateHexColors(content []byte, opts ValidationOptions) []ValidationError {
+// checkOpening checks what directly follows the "<![CDATA[" at open
+func (s *cdataScanner) checkOpening(line []byte, open int, split bool, report func(col int, errorType, message, content string)) {
+	after := open + len(cdataOpen)
+	if bytes.HasPrefix(line[after:], cdataClose) {
+		report(open, "Empty CDATA section", "CDATA section is empty", string(cdataEmpty))
+		return
+	}
+	// A section ending the line is followed by the newline
+	if after >= len(line) || split || cdataSafeFirst[line[after]] {
+		return
+	}
+	badChar := line[after]
+	report(open, "Special character after CDATA opening",
+		fmt.Sprintf("Special character '%c' found immediately after CDATA opening", badChar),
+		string(cdataOpen)+string(badChar))
+	// Common in WordPress exports
+	if badChar == '!' {
+		report(open, "Exclamation mark after CDATA opening",
+			"Exclamation mark found immediately after CDATA opening", "<![CDATA[!")
+	}
+}
+
+// end reports a section still open at the end of the document
+func (s *cdataScanner) end() []ValidationError {
+	if !s.inSection {
+		return nil
+	}
+	return []ValidationError{{
+		LineNumber: s.openLine,
+		Column:     s.openCol + 1,
+		Offset:     s.openOffset,
+		Line:       s.openText,
+		ErrorType:  "Unclosed CDATA section",
+		Message:    "CDATA section is not properly closed with ]]>",
+		Content:    s.openText[s.openCol:],
+	}}
+}
+
+// nextIndex returns the index of the first sep in line at or after from,
+// or -1
+func nextIndex(line []byte, from int, sep []byte) int {
+	if from > len(line) {
+		return -1
+	}
+	if i := bytes.Index(line[from:], sep); i >= 0 {
+		return from + i
+	}
+	return -1
+}
+
+// checkControlCharacterLine reports the first control character in a line
+func checkControlCharacterLine(lineNum, lineStart int, line []byte) []ValidationError {
+	// Look for control characters (except tab, CR, LF). They are single
+	// bytes in UTF-8, so the line needs no decoding.
+	for j, c := range line {
+		if c < 32 && c != '\t' && c != '\r' && c != '\n' {
+			return []ValidationError{{
+				LineNumber: lineNum,
+				Column:     j + 1,
+				Line:       string(line),
+				ErrorType:  "Control character",
+				Message:    fmt.Sprintf("Control character (hex 0x%02X) found", c),
+				Content:    string(rune(c)),
+				Fix:        &SuggestedFix{Start: lineStart + j, End: lineStart + j + 1},
+			}}
+		}
+	}
+	return nil
+}
+
+// checkUTF8MB3Line reports characters outside the Basic Multilingual Plane
+// (emoji, some CJK ideographs), which take four bytes in UTF-8 and are
+// truncated or rejected by MySQL's legacy utf8/utf8mb3 character set
+func checkUTF8MB3Line(lineNum, lineStart int, line []byte) []ValidationError {
 	var errors []ValidationError
-	lines := bytes.Split(content, []byte("\n"))
-	
-	// Valid hex colors: #RGB, #RRGGBB, #RRGGBBAA
-	// Invalid: #R, #RG, #RGBG, #RRGGB, anything with more than 8 chars
-	reInvalidHex := regexp.MustCompile(`#[0-9a-fA-F]{1,2}([^0-9a-fA-F]|$)|#[0-9a-fA-F]{4,5}([^0-9a-fA-F]|$)|#[0-9a-fA-F]{7,}`)
-	
-	for i, line := range lines {
-		lineStr := string(line)
-		
-		// Find all invalid hex colors on this line
-		matches := reInvalidHex.FindAllStringSubmatchIndex(lineStr, -1)
-		for _, match := range matches {
-			// Extract the hex code - careful to get just the hex part
-			hexStart := match[0]
-			hexEnd := match[1]
-			if match[2] != -1 { // If there's a character after the hex, don't include it
-				hexEnd = match[2]
-			}
-			hexCode := lineStr[hexStart:hexEnd]
-			
-			errors = append(errors, ValidationError{
-				LineNumber: i + 1,
-				Column:     hexStart + 1,
-				Line:       lineStr,
-				ErrorType:  "Invalid hex color",
-				Message:    fmt.Sprintf("Invalid hex color code: %s (should be #RGB, #RRGGBB, or #RRGGBBAA)", hexCode),
-				Content:    hexCode,
-			})
+	for j := 0; j < len(line); j++ {
+		// Only a lead byte of 0xF0 or above starts a 4-byte sequence
+		if line[j] < 0xF0 {
+			continue
 		}
-		
-		// Stop if we've reached max errors
-		if opts.MaxErrors > 0 && len(errors) >= opts.MaxErrors {
-			break
+		r, size := utf8.DecodeRune(line[j:])
+		if r == utf8.RuneError {
+			continue
 		}
+		// WordPress stores these as HTML entities (wp_encode_emoji) on
+		// utf8mb3 tables, so suggest the same
+		errors = append(errors, ValidationError{
+			LineNumber: lineNum,
+			Column:     j + 1,
+			Line:       string(line),
+			ErrorType:  "4-byte UTF-8 character",
+			Message:    fmt.Sprintf("Character U+%04X %q needs 4 bytes and cannot be stored in a utf8mb3 database", r, r),
+			Content:    string(r),
+			Fix: &SuggestedFix{
+				Start:       lineStart + j,
+				End:         lineStart + j + size,
+				Replacement: fmt.Sprintf("&#x%X;", r),
+			},
+		})
+		j += size - 1
 	}
-	
 	return errors
 }
 
-// validateSVG checks for SVG syntax issues in XML
-func validateSVG(content []byte, opts ValidationOptions) []ValidationError {
+// validateHexColors checks for malformed hex color codes in attribute
+// values and CSS properties that take colors
+func validateHexColors(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
 	var errors []ValidationError
-	lines := bytes.Split(content, []byte("\n"))
-	
-	// Pattern for SVG elements that should be self-closing
-	// This is simplified - real SVG validation would need more sophisticated parsing
-	reSVGSelfClosing := regexp.MustCompile(`<(path|rect|circle|ellipse|line|polyline|polygon|image|use)[^>]*[^/]>`)
-	reSVGUnquotedAttr := regexp.MustCompile(`<svg[^>]*(width|height|viewBox)=([^"'][^ >]*)`)
-	
-	for i, line := range lines {
-		lineStr := string(line)
-		
-		// Check for SVG elements that should be self-closing
-		matches := reSVGSelfClosing.FindAllStringSubmatchIndex(lineStr, -1)
-		for _, match := range matches {
-			// Make sure this isn't followed by a closing tag on the same line
-			tagName := lineStr[match[2]:match[3]]
-			if !regexp.MustCompile(`</`+tagName+`>`).MatchString(lineStr[match[1]:]) {
-				errors = append(errors, ValidationError{
-					LineNumber: i + 1,
-					Column:     match[0] + 1,
-					Line:       lineStr,
-					ErrorType:  "SVG self-closing tag issue",
-					Message:    fmt.Sprintf("SVG <%s> tag should be self-closing with />", tagName),
-					Content:    lineStr[match[0]:match[1]],
-				})
+
+	// Valid hex colors: #RGB, #RRGGBB, #RRGGBBAA
+	// Invalid: #R, #RG, #RGBA, #RRGGB, seven or more than eight digits
+	for _, run := range findHexColors(content) {
+		if !run.trailing && (len(run.digits) == 3 || len(run.digits) == 6 || len(run.digits) == 8) {
+			continue
+		}
+		hexCode := string(content[run.start:run.end])
+		if run.trailing {
+			end := run.end
+			for end < len(content) && (isWordByte(content[end]) || content[end] == '-') {
+				end++
 			}
+			hexCode = string(content[run.start:end])
 		}
-		
-		// Check for unquoted SVG attributes
-		matches = reSVGUnquotedAttr.FindAllStringSubmatchIndex(lineStr, -1)
-		for _, match := range matches {
-			attrName := lineStr[match[2]:match[3]]
-			attrValue := lineStr[match[4]:match[5]]
-			errors = append(errors, ValidationError{
-				LineNumber: i + 1,
-				Column:     match[2] + 1,
-				Line:       lineStr,
-				ErrorType:  "SVG unquoted attribute",
-				Message:    fmt.Sprintf("SVG attribute %s=%s should use quotes: %s=\"%s\"", attrName, attrValue, attrName, attrValue),
-				Content:    attrName + "=" + attrValue,
-			})
+
+		// Only #RGBA has an unambiguous correction
+		var fix *SuggestedFix
+		if len(run.digits) == 4 && !run.trailing {
+			fix = &SuggestedFix{
+				Start:       run.start,
+				End:         run.end,
+				Replacement: "#" + expandHexShorthand(run.digits),
+			}
 		}
-		
+
+		line, col, lineContent := findErrorPosition(ix, run.start)
+		errors = append(errors, ValidationError{
+			LineNumber: line,
+			Column:     col,
+			Line:       lineContent,
+			ErrorType:  "Invalid hex color",
+			Message:    fmt.Sprintf("Invalid hex color code: %s (should be #RGB, #RRGGBB, or #RRGGBBAA)", hexCode),
+			Content:    hexCode,
+			Fix:        fix,
+		})
+
 		// Stop if we've reached max errors
 		if opts.MaxErrors > 0 && len(errors) >= opts.MaxErrors {
 			break
 		}
 	}
-	
+
 	return errors
 }
 
+// isWordByte reports whether c is an ASCII letter, digit or underscore
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
 // findErrorPosition converts a byte offset to line/column
-func findErrorPosition(content []byte, offset int) (line, col int, lineContent string) {
-	// Default values
-	line = 1
-	col = 1
-	
+func findErrorPosition(ix *lineIndex, offset int) (line, col int, lineContent string) {
 	// Handle invalid offset
-	if offset < 0 || offset >= len(content) {
-		return line, col, ""
+	if offset < 0 || offset >= len(ix.content) {
+		return 1, 1, ""
 	}
 	
-	// Count lines and columns up to the offset
-	for i := 0; i < offset; i++ {
-		if content[i] == '\n' {
-			line++
-			col = 1
-		} else {
-			col++
+	line = ix.lineAt(offset)
+	col = offset - ix.lineStart(line) + 1
+	return line, col, string(ix.line(line))
+}
+
+// displayErrors prints the issue count and up to opts.MaxErrors issues
+// (all of them when it is 0). It returns how many it printed.
+func displayErrors(input string, ix *lineIndex, allErrors []ValidationError, opts ValidationOptions) int {
+	if opts.ErrorFormat != nil {
+		return printFormattedErrors(input, ix, allErrors, opts)
+	}
+	icon, found := errorColor("❌"), fmt.Sprintf("%d XML issues", len(allErrors))
+	if warnings := countWarnings(allErrors); warnings > 0 {
+		found = fmt.Sprintf("%d XML issues (%d errors, %d warnings)", len(allErrors), len(allErrors)-warnings, warnings)
+		if warnings == len(allErrors) {
+			icon = highlightColor("⚠️")
 		}
 	}
+	maxToShow := len(allErrors)
+	if opts.MaxErrors > 0 {
+		fmt.Printf("%s Found %s (showing up to %d):\n", icon, found, opts.MaxErrors)
+		maxToShow = min(maxToShow, opts.MaxErrors)
+	} else {
+		fmt.Printf("%s Found %s:\n", icon, found)
+	}
+	fmt.Println(headerColor("----------------------------------------"))
 	
-	// Extract the line content
-	scanner := bufio.NewScanner(bytes.NewReader(content))
-	currentLine := 1
-	for scanner.Scan() {
-		if currentLine == line {
-			lineContent = scanner.Text()
-			break
-		}
-		currentLine++
+	for i := 0; i < maxToShow; i++ {
+		displayError(ix, allErrors[i], i+1, opts)
 	}
 	
-	return line, col, lineContent
+	if len(allErrors) > maxToShow {
+		fmt.Printf("\n%s Found more errors than displayed (%d total). Run with --max-errors=0 to see all.\n", 
+			infoColor("Note:"), len(allErrors))
+	}
+	return maxToShow
+}
+
+// errorColumn returns the column to report for an error: its 1-based byte
+// column with byteColumns, otherwise the character (rune) column that
+// editors expect. ix indexes the document, which is nil when it was not
+// kept; columns on lines that are not known are left in bytes.
+func errorColumn(ix *lineIndex, e ValidationError, byteColumns bool) int {
+	if byteColumns || e.Column <= 1 {
+		return e.Column
+	}
+	line := e.Line
+	if ix.content != nil {
+		line = string(ix.line(e.LineNumber))
+	}
+	if line == "" {
+		return e.Column
+	}
+	return utf8.RuneCountInString(line[:min(e.Column-1, len(line))]) + 1
 }
 
-// displayError formats and prints a single validation error
-func displayError(content []byte, err ValidationError, index int) {
+// Tab stops are every tabWidth columns when lines are printed
+const tabWidth = 8
+
+// expandTabs replaces the tabs in s with spaces up to the next tab stop,
+// so the width of what is printed does not depend on where it starts
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := tabWidth - col%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col += runewidth.RuneWidth(r)
+	}
+	return b.String()
+}
+
+// displayError formats and prints a single validation error of the
+// document ix indexes
+func displayError(ix *lineIndex, err ValidationError, index int, opts ValidationOptions) {
 	fmt.Printf("\n%s #%d:\n", headerColor("Issue"), index)
-	fmt.Printf("%s %d, %s %d: %s\n", 
+	errorType := err.ErrorType
+	if id := err.ruleID(); id != "" {
+		errorType += " [" + id + "]"
+	}
+	kind := errorColor(errorType)
+	if err.Severity == severityWarning {
+		kind = highlightColor("warning: " + errorType)
+	}
+	offset := ""
+	if opts.ShowOffsets {
+		offset = fmt.Sprintf(", %s %d", infoColor("Offset"), err.Offset)
+	}
+	fmt.Printf("%s %d, %s %d%s: %s\n", 
 		infoColor("Line"), err.LineNumber, 
-		infoColor("Column"), err.Column, 
-		errorColor(err.ErrorType))
+		infoColor("Column"), errorColumn(ix, err, opts.ByteColumns), 
+		offset, kind)
 	fmt.Printf("%s %s\n", infoColor("Message:"), highlightColor(err.Message))
 	
 	// Show context (lines before and after the error)
 	fmt.Printf("\n%s\n", infoColor("Context:"))
 	fmt.Println(headerColor("----------------------------------------"))
 	
-	scanner := bufio.NewScanner(bytes.NewReader(content))
-	lineNum := 1
 	contextStart := err.LineNumber - 2
 	if contextStart < 1 {
 		contextStart = 1
 	}
-	contextEnd := err.LineNumber + 2
+	contextEnd := min(err.LineNumber+2, ix.lines())
+	lineText := func(n int) string { return string(ix.line(n)) }
+	if ix.content == nil {
+		// A streamed document was not kept; only the error's line is known
+		contextStart, contextEnd = err.LineNumber, err.LineNumber
+		lineText = func(int) string { return err.Line }
+	}
 	
-	for scanner.Scan() {
-		if lineNum >= contextStart && lineNum <= contextEnd {
-			line := scanner.Text()
-			
-			// Use different color for the line with the error
-			if lineNum == err.LineNumber {
-				fmt.Printf("%s: %s\n", infoColor(fmt.Sprintf("%4d", lineNum)), highlightColor(line))
-			} else {
-				fmt.Printf("%s: %s\n", infoColor(fmt.Sprintf("%4d", lineNum)), line)
-			}
-			
-			// If this is the error line, add a pointer
-			if lineNum == err.LineNumber && err.Column > 0 {
-				pointer := strings.Repeat(" ", err.Column+5) + errorColor("^")
-				if len(err.Content) > 1 {
-					// For multi-character errors, extend the pointer
-					pointer += errorColor(strings.Repeat("~", len(err.Content)-1))
-				}
-				fmt.Println(pointer)
-			}
+	for lineNum := contextStart; lineNum <= contextEnd; lineNum++ {
+		raw := lineText(lineNum)
+		line := expandTabs(raw)
+		
+		// Use different color for the line with the error
+		if lineNum == err.LineNumber {
+			fmt.Printf("%s: %s\n", infoColor(fmt.Sprintf("%4d", lineNum)), highlightColor(line))
+		} else {
+			fmt.Printf("%s: %s\n", infoColor(fmt.Sprintf("%4d", lineNum)), line)
 		}
-		lineNum++
-		if lineNum > contextEnd {
-			break
+		
+		// If this is the error line, add a pointer
+		if lineNum == err.LineNumber && err.Column > 0 {
+			// Line up with the expanded tabs and wide characters printed
+			// above, after the "%4d: " prefix
+			before := raw[:min(err.Column-1, len(raw))]
+			at := runewidth.StringWidth(expandTabs(before))
+			pointer := strings.Repeat(" ", at+6) + errorColor("^")
+			if width := runewidth.StringWidth(expandTabs(before+err.Content)) - at; width > 1 {
+				// For multi-character errors, extend the pointer
+				pointer += errorColor(strings.Repeat("~", width-1))
+			}
+			fmt.Println(pointer)
 		}
 	}
 	
@@ -558,5 +1267,7 @@ func printCorrectionTips() {
 		successColor("Control characters"), 
 		infoColor("go run xml_fixer.go yourfile.xml"))
 	
+	fmt.Printf("  - %s: xml_validator explain <ID>, e.g. explain CDATA003\n", successColor("Details on any issue"))
+	
 	fmt.Printf("\n%s\n", highlightColor("For WordPress import files, CDATA errors are particularly important to fix."))
 } 
\ No newline at end of file
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runMerge implements the "merge" subcommand
+func runMerge(args []string) {
+	var output string
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.StringVar(&output, "output", "", "Write the merged export to this file instead of stdout")
+	addFetchFlags(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() < 2 {
+		fmt.Println("Usage: xml_validator merge [--output=FILE] <wxr-file-or-URL> <wxr-file-or-URL>...")
+		os.Exit(exitUsage)
+	}
+
+	var layouts []*wxrLayout
+	for _, input := range fs.Args() {
+		content, err := readFileContent(input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error reading %s: %v\n", input, err)
+			os.Exit(inputExitCode(err))
+		}
+		layout, err := parseWXRLayout(content)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Cannot merge %s: %v\n", input, err)
+			os.Exit(exitValidation)
+		}
+		layouts = append(layouts, layout)
+	}
+
+	merged := mergeWXR(layouts)
+
+	// Validate the result the same way a single export would be
+	opts := ValidationOptions{MaxErrors: 5}
+	ix := indexLines(merged)
+	errs := validateBasicXML(merged, ix)
+	if len(errs) == 0 {
+		errs = validateWXR(merged, ix, opts)
+	}
+
+	if err := writeOutput(output, merged); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error writing output: %v\n", err)
+		os.Exit(exitIO)
+	}
+
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "%s Merged export has issues:\n", errorColor("❌"))
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "  Line %d: %s: %s\n", e.LineNumber, e.ErrorType, e.Message)
+		}
+		os.Exit(exitValidation)
+	}
+	fmt.Fprintf(os.Stderr, "%s Merged %d exports\n", successColor("✅"), len(layouts))
+}
+
+// mergeWXR combines exports into one document. The first export provides
+// the channel metadata and footer; namespace declarations and author/term
+// definitions missing from it are added from the others, and every item
+// is appended in input order.
+func mergeWXR(layouts []*wxrLayout) []byte {
+	first := layouts[0]
+	var out bytes.Buffer
+
+	// Declare every namespace prefix used by any of the inputs on <rss>
+	declared := make(map[string]string)
+	for _, ns := range first.namespaces {
+		declared[ns.Name.Local] = ns.Value
+	}
+	var extra bytes.Buffer
+	for _, layout := range layouts[1:] {
+		for _, ns := range layout.namespaces {
+			uri, ok := declared[ns.Name.Local]
+			if !ok {
+				declared[ns.Name.Local] = ns.Value
+				fmt.Fprintf(&extra, ` xmlns:%s="%s"`, ns.Name.Local, ns.Value)
+			} else if uri != ns.Value {
+				fmt.Fprintf(os.Stderr, "%s prefix %q is bound to %s and %s; keeping %s\n",
+					highlightColor("Warning:"), ns.Name.Local, uri, ns.Value, uri)
+			}
+		}
+	}
+
+	rssTag := first.header[first.rssTag[0]:first.rssTag[1]]
+	out.Write(first.header[:first.rssTag[0]])
+	out.Write(rssTag[:len(rssTag)-1])
+	out.Write(extra.Bytes())
+	out.WriteByte('>')
+	out.Write(first.header[first.rssTag[1]:])
+
+	// Add definitions the first export does not already contain
+	seen := make(map[string]bool)
+	for _, def := range first.definitions {
+		seen[def.key] = true
+	}
+	for _, layout := range layouts[1:] {
+		for _, def := range layout.definitions {
+			if seen[def.key] {
+				continue
+			}
+			seen[def.key] = true
+			out.Write(def.raw)
+			out.WriteString("\n\t")
+		}
+	}
+
+	for i, layout := range layouts {
+		if i > 0 {
+			out.WriteString("\n\t")
+		}
+		for _, item := range layout.items {
+			out.Write(item)
+		}
+	}
+	out.Write(first.footer)
+
+	return out.Bytes()
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fetchS3 downloads s3://BUCKET/KEY with a request signed (AWS Signature
+// Version 4) with the credentials of the environment or the shared
+// credentials file, the way the AWS CLI finds them. Without credentials
+// the request is sent unsigned, which works for public objects.
+// $AWS_ENDPOINT_URL_S3 or $AWS_ENDPOINT_URL selects an S3-compatible
+// service such as MinIO instead of AWS.
+func fetchS3(u *url.URL) (*http.Response, error) {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("%s: expected s3://BUCKET/KEY", u)
+	}
+	region := cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+
+	var endpoint, path string
+	if custom := cmp.Or(os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL")); custom != "" {
+		endpoint, path = strings.TrimSuffix(custom, "/"), "/"+bucket+"/"+key
+	} else if strings.Contains(bucket, ".") {
+		// Dotted bucket names do not match the wildcard certificate
+		endpoint, path = "https://s3."+region+".amazonaws.com", "/"+bucket+"/"+key
+	} else {
+		endpoint, path = "https://"+bucket+".s3."+region+".amazonaws.com", "/"+key
+	}
+	req, err := http.NewRequest(http.MethodGet, endpoint+awsEscapePath(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", fetchOptions.UserAgent)
+	if creds, ok := awsCredentialsFromEnvironment(); ok && fetchOptions.sendsCredentials(u) {
+		signAWSRequest(req, creds, region, "s3", time.Now())
+	}
+	return sendFetch(req)
+}
+
+// awsCredentials are the keys requests to AWS are signed with
+type awsCredentials struct {
+	accessKey, secretKey, sessionToken string
+}
+
+// awsCredentialsFromEnvironment returns the credentials in
+// $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY, or else those of the
+// $AWS_PROFILE (default) profile in the shared credentials file
+func awsCredentialsFromEnvironment() (awsCredentials, bool) {
+	creds := awsCredentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.accessKey != "" && creds.secretKey != "" {
+		return creds, true
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, false
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return awsCredentials{}, false
+	}
+	defer f.Close()
+	profile := cmp.Or(os.Getenv("AWS_PROFILE"), "default")
+	creds, section := awsCredentials{}, ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(name) {
+		case "aws_access_key_id":
+			creds.accessKey = value
+		case "aws_secret_access_key":
+			creds.secretKey = value
+		case "aws_session_token":
+			creds.sessionToken = value
+		}
+	}
+	return creds, creds.accessKey != "" && creds.secretKey != ""
+}
+
+// Hash of an empty body, the payload of every GET
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signAWSRequest adds an AWS Signature Version 4 Authorization header to
+// a bodiless request. Only Host and the X-Amz- headers are signed, so
+// headers added later, such as Range when resuming, keep it valid.
+func signAWSRequest(req *http.Request, creds awsCredentials, region, service string, now time.Time) {
+	stamp := now.UTC().Format("20060102T150405Z")
+	day := stamp[:8]
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if creds.sessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range headers {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(value))
+	}
+	signedHeaders := strings.Join(headers, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := []byte("AWS4" + creds.secretKey)
+	for _, part := range []string{day, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEscapePath percent-encodes every byte of path but the unreserved
+// characters and slashes, as Signature Version 4 expects of S3 keys
+func awsEscapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// fetchGCS downloads gs://BUCKET/OBJECT through the Cloud Storage JSON
+// API, with an access token from $GOOGLE_OAUTH_ACCESS_TOKEN or the
+// gcloud CLI's login. Without one the request is anonymous, which works
+// for public objects. $STORAGE_EMULATOR_HOST selects an emulator.
+func fetchGCS(u *url.URL) (*http.Response, error) {
+	bucket, object := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || object == "" {
+		return nil, fmt.Errorf("%s: expected gs://BUCKET/OBJECT", u)
+	}
+	endpoint := "https://storage.googleapis.com"
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		endpoint = strings.TrimSuffix(host, "/")
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet,
+		endpoint+"/storage/v1/b/"+url.PathEscape(bucket)+"/o/"+url.PathEscape(object)+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", fetchOptions.UserAgent)
+	if fetchOptions.sendsCredentials(u) {
+		if token := googleAccessToken(); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	return sendFetch(req)
+}
+
+// googleAccessToken returns $GOOGLE_OAUTH_ACCESS_TOKEN, or else a token
+// for the account gcloud is logged in with, or "" when there is neither
+func googleAccessToken() string {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token
+	}
+	if _, err := exec.LookPath("gcloud"); err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// Set in the environment of the command run under the pager, to whether
+// its output is colored ("always" or "never")
+const pagedEnv = "XML_VALIDATOR_PAGED"
+
+// pagerCommand returns the pager to use: $XML_VALIDATOR_PAGER, $PAGER or
+// less. An empty value or cat turns paging off.
+func pagerCommand() string {
+	if pager, ok := os.LookupEnv("XML_VALIDATOR_PAGER"); ok {
+		return pager
+	}
+	if pager, ok := os.LookupEnv("PAGER"); ok {
+		return pager
+	}
+	return "less"
+}
+
+// runPaged runs this command again with its output piped through the
+// pager, then exits with its status. Like git, it sets LESS=FRX unless
+// LESS is set, so less exits at once when the report fits on the screen.
+// It returns, for the report to be printed directly, when stdout is not
+// a terminal, no pager is configured or it cannot be started.
+func runPaged(colored bool) {
+	pager := strings.Fields(pagerCommand())
+	if os.Getenv(pagedEnv) != "" || !isTerminal(os.Stdout) || len(pager) == 0 || pager[0] == "cat" {
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+
+	pg := exec.Command(pager[0], pager[1:]...)
+	pg.Stdin, pg.Stdout, pg.Stderr = r, os.Stdout, os.Stderr
+	pg.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		pg.Env = append(pg.Env, "LESS=FRX")
+	}
+	if err := pg.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return
+	}
+
+	mode := "never"
+	if colored {
+		mode = "always"
+	}
+	child := exec.Command(exe, os.Args[1:]...)
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, w, os.Stderr
+	child.Env = append(os.Environ(), pagedEnv+"="+mode)
+	err = child.Start()
+	r.Close()
+	w.Close()
+	if err != nil {
+		pg.Wait()
+		return
+	}
+
+	// Ctrl-C stops the command but leaves the pager open, as in git
+	signal.Ignore(os.Interrupt)
+	child.Wait()
+	pg.Wait()
+	status := child.ProcessState.ExitCode()
+	if ws, ok := child.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		// Killed, e.g. by SIGPIPE when the pager was quit early
+		status = 128 + int(ws.Signal())
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// plugin is an external checker added with --plugin. It is run with the
+// document, or with the content of each matching element, on stdin and
+// prints the issues it finds to stdout as a JSON array of pluginIssue
+// objects (nothing or [] when there are none). Any other output, or a
+// non-zero exit status, is reported as an issue of the plugin.
+type plugin struct {
+	name    string // Check name, the executable's base name without extension
+	path    string
+	element string // Prefixed name of the elements to check one by one, or "" for the document
+}
+
+// pluginIssue is one issue reported by a plugin. Positions refer to its
+// stdin; offset, when given, is used instead of line and column.
+type pluginIssue struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Offset  *int   `json:"offset"`
+	Message string `json:"message"`
+	Content string `json:"content"`
+}
+
+// pluginFlags collects repeated --plugin=[ELEMENT=]PATH values
+type pluginFlags []plugin
+
+func (f *pluginFlags) String() string {
+	var parts []string
+	for _, p := range *f {
+		if p.element != "" {
+			parts = append(parts, p.element+"="+p.path)
+		} else {
+			parts = append(parts, p.path)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *pluginFlags) Set(value string) error {
+	p := plugin{path: value}
+	// Element names cannot contain a path separator, so ./a=b is a path
+	if element, path, ok := strings.Cut(value, "="); ok && !strings.ContainsAny(element, `/\`) {
+		p.element, p.path = element, path
+		if element == "" {
+			p.path = ""
+		}
+	}
+	if p.path == "" {
+		return fmt.Errorf("want [ELEMENT=]PATH, got %q", value)
+	}
+	p.name = strings.TrimSuffix(filepath.Base(p.path), filepath.Ext(p.path))
+	if p.name == "basic" || isRuleKey(p.name) {
+		return fmt.Errorf("plugin name %q is already used by another check; rename %s", p.name, p.path)
+	}
+	for _, other := range *f {
+		if other.name == p.name {
+			return fmt.Errorf("plugins %s and %s have the same name %q", other.path, p.path, p.name)
+		}
+	}
+	*f = append(*f, p)
+	return nil
+}
+
+// registerPlugins adds the plugins to the rule catalog, so their issues
+// get rule IDs and severity overrides like those of the built-in checks
+func registerPlugins(plugins []plugin) {
+	for _, p := range plugins {
+		info := &ruleInfo{
+			id:        p.name,
+			errorType: p.errorType(),
+			check:     p.name,
+			summary:   "Reported by the plugin " + p.path,
+		}
+		ruleByType[info.errorType] = info
+		ruleByID[info.id] = info
+	}
+}
+
+// errorType is the ErrorType the plugin's issues are reported with
+func (p plugin) errorType() string {
+	return "Plugin " + p.name
+}
+
+// rule returns the document rule that runs the plugin
+func (p plugin) rule() rule {
+	return rule{name: p.name, progress: fmt.Sprintf("Checking with plugin %s...", p.path), document: p.check}
+}
+
+// check runs the plugin over the document, or once per matching element,
+// and converts the positions it reports to ones in the document
+func (p plugin) check(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	spans := [][2]int{{0, len(content)}}
+	if p.element != "" {
+		spans = elementContents(content, p.element)
+	}
+
+	var errs []ValidationError
+	for _, span := range spans {
+		input := content[span[0]:span[1]]
+		issues, err := p.run(input, opts.PluginTimeout)
+		if err != nil {
+			line := ix.lineAt(span[0])
+			return append(errs, ValidationError{
+				LineNumber: line,
+				Column:     span[0] - ix.lineStart(line) + 1,
+				Line:       string(ix.line(line)),
+				ErrorType:  p.errorType(),
+				Message:    fmt.Sprintf("Plugin %s failed: %v", p.path, err),
+			})
+		}
+
+		inputIx := indexLines(input)
+		for _, issue := range issues {
+			at := inputIx.offset(max(issue.Line, 1), issue.Column)
+			if issue.Offset != nil {
+				at = min(max(*issue.Offset, 0), len(input))
+			}
+			line := ix.lineAt(span[0] + at)
+			errs = append(errs, ValidationError{
+				LineNumber: line,
+				Column:     span[0] + at - ix.lineStart(line) + 1,
+				Line:       string(ix.line(line)),
+				ErrorType:  p.errorType(),
+				Message:    issue.Message,
+				Content:    issue.Content,
+			})
+			if opts.MaxErrors > 0 && len(errs) >= opts.MaxErrors {
+				return errs
+			}
+		}
+	}
+	return errs
+}
+
+// run starts the plugin with input on stdin and decodes what it prints.
+// A plugin still running after timeout (unless it is 0) is killed.
+func (p plugin) run(input []byte, timeout time.Duration) ([]pluginIssue, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, p.path)
+	// Children left holding stdout must not keep us waiting after the kill
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("timed out after %s", timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	var issues []pluginIssue
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err := json.Unmarshal(out, &issues); err != nil {
+			return nil, fmt.Errorf("output is not a JSON array of issues: %v", err)
+		}
+	}
+	return issues, nil
+}
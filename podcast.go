@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Namespaces used by podcast feeds
+const (
+	itunesNamespace  = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+	podcastNamespace = "https://podcastindex.org/namespace/1.0"
+)
+
+// Apple Podcasts top-level categories accepted in <itunes:category text=...>
+var itunesCategories = map[string]bool{
+	"Arts": true, "Business": true, "Comedy": true, "Education": true, "Fiction": true,
+	"Government": true, "History": true, "Health & Fitness": true, "Kids & Family": true,
+	"Leisure": true, "Music": true, "News": true, "Religion & Spirituality": true,
+	"Science": true, "Society & Culture": true, "Sports": true, "Technology": true,
+	"True Crime": true, "TV & Film": true,
+}
+
+// Enclosure MIME types podcast directories accept
+var podcastEnclosureTypes = map[string]bool{
+	"audio/mpeg":      true,
+	"audio/x-m4a":     true,
+	"audio/mp4":       true,
+	"audio/aac":       true,
+	"audio/ogg":       true,
+	"audio/opus":      true,
+	"video/mp4":       true,
+	"video/quicktime": true,
+	"video/x-m4v":     true,
+	"application/pdf": true,
+}
+
+// validatePodcast checks a podcast feed: the RSS 2.0 rules plus the
+// itunes: and podcast: namespace requirements hosts enforce
+func validatePodcast(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	errors := validateRSS(content, ix, opts)
+
+	root, err := parseTree(content)
+	if err != nil || root == nil || root.Name.Local != "rss" {
+		return errors
+	}
+	channel := root.child("", "channel")
+	if channel == nil {
+		return errors
+	}
+
+	errors = append(errors, checkPodcastChannel(ix, channel)...)
+
+	type episodeKey struct{ season, episode int }
+	seen := make(map[episodeKey]*xmlNode)
+	for _, item := range channel.childrenNamed("", "item") {
+		errors = append(errors, checkPodcastEpisode(ix, item)...)
+
+		season, _ := podcastNumber(item, "season")
+		episode, ok := podcastNumber(item, "episode")
+		if !ok {
+			continue
+		}
+		key := episodeKey{season, episode}
+		if first, dup := seen[key]; dup {
+			label := fmt.Sprintf("Episode %d", episode)
+			if season > 0 {
+				label = fmt.Sprintf("Season %d episode %d", season, episode)
+			}
+			errors = append(errors, nodeError(ix, item, "Podcast episode numbering",
+				fmt.Sprintf("%s is used twice (first on line %d)", label, lineOf(ix, first.Offset))))
+		} else {
+			seen[key] = item
+		}
+	}
+
+	return errors
+}
+
+// checkPodcastChannel checks the show-level elements
+func checkPodcastChannel(ix *lineIndex, channel *xmlNode) []ValidationError {
+	var errors []ValidationError
+
+	if channel.child("", "language") == nil {
+		errors = append(errors, nodeError(ix, channel, "Podcast missing element", "<channel> is missing <language>"))
+	}
+
+	image := channel.child(itunesNamespace, "image")
+	if image == nil {
+		errors = append(errors, nodeError(ix, channel, "Podcast missing element", "<channel> is missing <itunes:image href=...>"))
+	} else if href, _ := image.attr("href"); href == "" {
+		errors = append(errors, nodeError(ix, image, "Podcast missing element", "<itunes:image> has no href attribute"))
+	}
+
+	categories := channel.childrenNamed(itunesNamespace, "category")
+	if len(categories) == 0 {
+		errors = append(errors, nodeError(ix, channel, "Podcast missing element", "<channel> is missing <itunes:category>"))
+	}
+	for _, category := range categories {
+		if text, _ := category.attr("text"); !itunesCategories[text] {
+			errors = append(errors, nodeError(ix, category, "Podcast invalid category",
+				fmt.Sprintf("<itunes:category text=%q> is not an Apple Podcasts category", text)))
+		}
+	}
+
+	explicit := channel.child(itunesNamespace, "explicit")
+	if explicit == nil {
+		errors = append(errors, nodeError(ix, channel, "Podcast missing element", "<channel> is missing <itunes:explicit>"))
+	} else if v := strings.ToLower(explicit.trimmedText()); v != "true" && v != "false" && v != "yes" && v != "no" {
+		errors = append(errors, nodeError(ix, explicit, "Podcast invalid value",
+			fmt.Sprintf("<itunes:explicit> must be true or false, not %q", explicit.trimmedText())))
+	}
+
+	if locked := channel.child(podcastNamespace, "locked"); locked != nil {
+		if v := locked.trimmedText(); v != "yes" && v != "no" {
+			errors = append(errors, nodeError(ix, locked, "Podcast invalid value",
+				fmt.Sprintf("<podcast:locked> must be yes or no, not %q", v)))
+		}
+	}
+
+	return errors
+}
+
+// checkPodcastEpisode checks one <item>: enclosure and numbering values
+func checkPodcastEpisode(ix *lineIndex, item *xmlNode) []ValidationError {
+	var errors []ValidationError
+
+	enclosure := item.child("", "enclosure")
+	if enclosure == nil {
+		errors = append(errors, nodeError(ix, item, "Podcast missing enclosure",
+			fmt.Sprintf("Episode %s has no <enclosure> with the media file", describeItem(item))))
+	} else if mime, _ := enclosure.attr("type"); mime != "" && !podcastEnclosureTypes[mime] {
+		errors = append(errors, nodeError(ix, enclosure, "Podcast invalid enclosure",
+			fmt.Sprintf("Enclosure type %q is not accepted by podcast directories", mime)))
+	}
+
+	for _, ns := range []string{itunesNamespace, podcastNamespace} {
+		for _, name := range []string{"season", "episode"} {
+			if n := item.child(ns, name); n != nil {
+				if v, err := strconv.Atoi(n.trimmedText()); err != nil || v < 1 {
+					errors = append(errors, nodeError(ix, n, "Podcast episode numbering",
+						fmt.Sprintf("<%s> must be a positive integer, not %q", n.Name.Local, n.trimmedText())))
+				}
+			}
+		}
+	}
+
+	if t := item.child(itunesNamespace, "episodeType"); t != nil {
+		if v := t.trimmedText(); v != "full" && v != "trailer" && v != "bonus" {
+			errors = append(errors, nodeError(ix, t, "Podcast invalid value",
+				fmt.Sprintf("<itunes:episodeType> must be full, trailer or bonus, not %q", v)))
+		}
+	}
+
+	return errors
+}
+
+// podcastNumber reads an itunes: or podcast: season/episode number
+func podcastNumber(item *xmlNode, name string) (int, bool) {
+	for _, ns := range []string{itunesNamespace, podcastNamespace} {
+		if n := item.child(ns, name); n != nil {
+			if v, err := strconv.Atoi(n.trimmedText()); err == nil {
+				return v, true
+			}
+		}
+	}
+	return 0, false
+}
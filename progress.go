@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Inputs smaller than this finish quickly enough not to need a progress bar
+const progressThreshold = 50 << 20
+
+// How often the bar is redrawn
+const progressInterval = 200 * time.Millisecond
+
+// progressBar draws bytes processed out of a known total, with a
+// percentage and an estimated time left, on one line of stderr. A nil
+// *progressBar does nothing, so callers need not check whether one is shown.
+type progressBar struct {
+	label string
+	total int64
+	scale int64 // Units of progress per byte shown
+
+	mu    sync.Mutex
+	done  int64
+	start time.Time
+	drawn time.Time
+}
+
+// showProgress is cleared when several inputs are validated at once, as
+// their bars would overwrite each other
+var showProgress = true
+
+// newProgressBar returns a bar for size bytes, or nil when the input is
+// below the threshold or stderr is not a terminal that can redraw a line.
+// Work done is counted in units of size*scale, for when each byte is
+// processed scale times.
+func newProgressBar(label string, size, scale int64) *progressBar {
+	if !showProgress || size < progressThreshold || !isTerminal(os.Stderr) {
+		return nil
+	}
+	return &progressBar{label: label, total: size * scale, scale: scale, start: time.Now()}
+}
+
+// isTerminal reports whether f is a character device such as a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// add records n more bytes processed and redraws the bar if it is due
+func (p *progressBar) add(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done = min(p.done+n, p.total)
+	if now := time.Now(); now.Sub(p.drawn) >= progressInterval {
+		p.drawn = now
+		p.draw(now)
+	}
+}
+
+// draw writes the bar over the current line. It is called with mu held.
+func (p *progressBar) draw(now time.Time) {
+	const width = 30
+	fraction := float64(p.done) / float64(p.total)
+	filled := int(fraction * width)
+
+	eta := "--"
+	if elapsed := now.Sub(p.start); p.done > 0 && elapsed > time.Second {
+		left := time.Duration(float64(elapsed) * float64(p.total-p.done) / float64(p.done))
+		eta = left.Round(time.Second).String()
+	}
+	fmt.Fprintf(os.Stderr, "\r%s [%s%s] %3.0f%% %s/%s ETA %s ",
+		p.label, strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+		fraction*100, formatMB(p.done/p.scale), formatMB(p.total/p.scale), eta)
+}
+
+// finish clears the bar so later output starts on a clean line
+func (p *progressBar) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "\r%s\r", strings.Repeat(" ", 80))
+}
+
+// formatMB formats a byte count in megabytes
+func formatMB(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+// progressReader advances a progress bar as data is read through it
+type progressReader struct {
+	r   io.Reader
+	bar *progressBar
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.bar.add(int64(n))
+	return n, err
+}
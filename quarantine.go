@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// writeQuarantine moves every <item> containing one of errs into its own
+// document at quarantinePath, wrapped in the original channel header and
+// footer so it can be fixed and imported separately. When cleanedPath is
+// set the remaining items are written there. It returns the number of
+// items quarantined and the total number of items.
+func writeQuarantine(content []byte, errs []ValidationError, quarantinePath, cleanedPath string) (int, int, error) {
+	layout, err := parseWXRLayout(content)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	bad := make(map[int]bool)
+	for _, e := range errs {
+		offset := e.Offset
+		for i, r := range layout.ranges {
+			if offset >= r[0] && offset < r[1] {
+				bad[i] = true
+				break
+			}
+		}
+	}
+
+	var quarantined, cleaned bytes.Buffer
+	quarantined.Write(layout.header)
+	cleaned.Write(layout.header)
+	for i, item := range layout.items {
+		if bad[i] {
+			quarantined.Write(item)
+		} else {
+			cleaned.Write(item)
+		}
+	}
+	quarantined.Write(layout.footer)
+	cleaned.Write(layout.footer)
+
+	if errs := validateBasicXML(quarantined.Bytes(), indexLines(quarantined.Bytes())); len(errs) > 0 {
+		return 0, 0, fmt.Errorf("quarantine file would not be well-formed: %s", errs[0].Message)
+	}
+
+	if err := os.WriteFile(quarantinePath, quarantined.Bytes(), 0644); err != nil {
+		return 0, 0, err
+	}
+	if cleanedPath != "" {
+		if err := os.WriteFile(cleanedPath, cleaned.Bytes(), 0644); err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(bad), len(layout.items), nil
+}
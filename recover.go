@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// recoverBasicXML checks well-formedness like validateBasicXML but keeps
+// going after an error. The parser cannot resume, so a new one starts at
+// the next tag, first fed the start tags of the elements that were open so
+// their end tags still match. An end tag that closes the wrong element
+// implicitly closes the elements inside the one it names, or is skipped
+// when nothing open has that name. Parsing stops once maxErrors errors
+// have been found, unless maxErrors is 0.
+func recoverBasicXML(content []byte, ix *lineIndex, maxErrors int) []ValidationError {
+	var errors []ValidationError
+	var open []string // Raw names of the open elements, outermost first
+
+	for pos := 0; pos < len(content); {
+		prefix := openTagsPrefix(open)
+		decoder := xml.NewDecoder(io.MultiReader(strings.NewReader(prefix), bytes.NewReader(content[pos:])))
+
+		var err error
+		for {
+			start := int(decoder.InputOffset())
+			var token xml.Token
+			if token, err = decoder.Token(); err != nil {
+				break
+			}
+			if start < len(prefix) {
+				continue // One of the start tags replayed from open
+			}
+			switch token.(type) {
+			case xml.StartElement:
+				open = append(open, rawTagName(content[pos+start-len(prefix)+1:]))
+			case xml.EndElement:
+				open = open[:len(open)-1]
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+
+		offset := min(pos+int(decoder.InputOffset())-len(prefix), len(content))
+		next := max(offset, pos+1)
+		at := offset
+		endTag := ""
+		if offset > pos && content[offset-1] == '>' {
+			if i := bytes.LastIndex(content[pos:offset], []byte("</")); i >= 0 {
+				at, endTag = pos+i, rawTagName(content[pos+i+2:offset])
+			}
+		}
+
+		message := err.Error()
+		line := ix.lineAt(min(at, max(len(content)-1, 0)))
+		if syntaxErr, ok := err.(*xml.SyntaxError); ok {
+			// The parser counts lines from where it was restarted
+			message = fmt.Sprintf("XML syntax error on line %d: %s", line, syntaxErr.Msg)
+		}
+		errors = append(errors, ValidationError{
+			LineNumber: line,
+			Column:     at - ix.lineStart(line) + 1,
+			Line:       string(ix.line(line)),
+			ErrorType:  "Basic XML Syntax Error",
+			Message:    message,
+		})
+
+		if offset >= len(content) {
+			break // Unexpected end of the document
+		}
+		if maxErrors > 0 && len(errors) >= maxErrors {
+			break
+		}
+		if endTag != "" {
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == endTag {
+					open = open[:i]
+					break
+				}
+			}
+			pos = next
+			continue
+		}
+		// Skip the rest of whatever could not be parsed
+		i := bytes.IndexByte(content[next:], '<')
+		if i < 0 {
+			break
+		}
+		pos = next + i
+	}
+
+	return errors
+}
+
+// openTagsPrefix returns start tags for the open elements
+func openTagsPrefix(open []string) string {
+	var b strings.Builder
+	for _, name := range open {
+		b.WriteString("<" + name + ">")
+	}
+	return b.String()
+}
+
+// rawTagName returns the name at the start of b, as written in the tag
+func rawTagName(b []byte) string {
+	end := 0
+	for end < len(b) && !strings.ContainsRune(" \t\r\n/>", rune(b[end])) {
+		end++
+	}
+	return string(b[:end])
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+)
+
+// Rule is a check compiled into the validator from Go code, e.g. a file
+// added to this package in a fork that calls RegisterRule from its init
+// function. Its ID is also its check name for --checks, --disable and
+// severity overrides. A Rule must implement LineRule, TokenRule or both.
+type Rule interface {
+	ID() string
+}
+
+// LineRule is a Rule that looks at one line at a time, in the pass the
+// built-in line checks share. It sets the Column (1-based, in bytes) and
+// Message of the errors it returns; the rest is filled in.
+type LineRule interface {
+	Rule
+	CheckLine(lineNum int, line []byte) []ValidationError
+}
+
+// TokenRule is a Rule that looks at the raw tokens of the document (see
+// xml.Decoder.RawToken). offset is where the token starts in the
+// document. Like the decoder's, the token's data is only valid until
+// CheckToken returns. It sets the Offset and Message of the errors it
+// returns; the rest is filled in.
+type TokenRule interface {
+	Rule
+	CheckToken(token xml.Token, offset int) []ValidationError
+}
+
+// registeredRules are the rules added with RegisterRule, which run on
+// every validation like the built-in checks
+var registeredRules []Rule
+
+// RegisterRule adds r to the checks validate runs. It panics if r has an
+// empty or already used ID, or is neither a LineRule nor a TokenRule, so a
+// mistake shows up on the first run rather than as a silently missing
+// check.
+func RegisterRule(r Rule) {
+	id := r.ID()
+	_, isLine := r.(LineRule)
+	_, isToken := r.(TokenRule)
+	switch {
+	case id == "" || id == "basic" || ruleByID[id] != nil || isCatalogKey(id):
+		panic(fmt.Sprintf("RegisterRule: rule ID %q is empty or already used", id))
+	case !isLine && !isToken:
+		panic(fmt.Sprintf("RegisterRule: rule %s has neither CheckLine nor CheckToken", id))
+	}
+	registeredRules = append(registeredRules, r)
+	info := &ruleInfo{id: id, errorType: "Rule " + id, check: id}
+	ruleByType[info.errorType] = info
+	ruleByID[id] = info
+}
+
+// isCatalogKey reports whether key is a rule ID or check name in
+// ruleCatalog. Unlike isRuleKey it does not depend on the indexes, which
+// another init function may not have built yet.
+func isCatalogKey(key string) bool {
+	for _, r := range ruleCatalog {
+		if r.id == key || r.check == key {
+			return true
+		}
+	}
+	return false
+}
+
+// registeredRule returns the engine rules that run r: a line rule, a
+// document rule feeding it tokens, or both
+func registeredRule(r Rule) []rule {
+	var rules []rule
+	errorType := "Rule " + r.ID()
+	progress := fmt.Sprintf("Checking rule %s...", r.ID())
+	if lr, ok := r.(LineRule); ok {
+		rules = append(rules, rule{name: r.ID(), progress: progress, line: func(lineNum, _ int, line []byte) []ValidationError {
+			errs := lr.CheckLine(lineNum, line)
+			for i := range errs {
+				errs[i].LineNumber, errs[i].Line, errs[i].ErrorType = lineNum, string(line), errorType
+			}
+			return errs
+		}})
+	}
+	if tr, ok := r.(TokenRule); ok {
+		rules = append(rules, rule{name: r.ID(), progress: progress, document: func(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+			var errs []ValidationError
+			decoder := xml.NewDecoder(bytes.NewReader(content))
+			for opts.MaxErrors == 0 || len(errs) < opts.MaxErrors {
+				offset := int(decoder.InputOffset())
+				token, err := decoder.RawToken()
+				if err != nil {
+					break
+				}
+				for _, e := range tr.CheckToken(token, offset) {
+					at := min(max(e.Offset, 0), len(content))
+					e.LineNumber = ix.lineAt(at)
+					e.Column = at - ix.lineStart(e.LineNumber) + 1
+					e.Line, e.ErrorType = string(ix.line(e.LineNumber)), errorType
+					errs = append(errs, e)
+				}
+			}
+			return errs
+		}})
+	}
+	return rules
+}
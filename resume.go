@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// resumingBody reads a response body and, when the connection drops part
+// way through, asks the server for the rest with a Range request instead
+// of starting over, as often as fetchOptions.Retries allows. If-Range
+// makes sure the rest comes from the same version of the document, so
+// only responses with a strong ETag or a Last-Modified date are resumed.
+type resumingBody struct {
+	io.ReadCloser
+	req       *http.Request // The request that got the body, after redirects
+	validator string        // ETag or Last-Modified of the body
+	read      int64         // Bytes read so far
+	attempts  int           // Resumes so far
+}
+
+// resumable returns resp with its body resumed after a dropped
+// connection, if the server sent a validator to resume it with
+func resumable(resp *http.Response) *http.Response {
+	if resp.StatusCode != http.StatusOK || fetchOptions.Retries == 0 {
+		return resp
+	}
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	if validator == "" {
+		return resp
+	}
+	resp.Body = &resumingBody{ReadCloser: resp.Body, req: resp.Request, validator: validator}
+	return resp
+}
+
+func (b *resumingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	for err != nil && err != io.EOF && b.attempts < fetchOptions.Retries && isTransient(nil, err) {
+		if n > 0 {
+			// Return what arrived; the next Read resumes
+			return n, nil
+		}
+		if !b.resume(err) {
+			break
+		}
+		n, err = b.ReadCloser.Read(p)
+		b.read += int64(n)
+	}
+	return n, err
+}
+
+// resume replaces the body with the rest of the document from the
+// server, reporting whether it could
+func (b *resumingBody) resume(cause error) bool {
+	b.attempts++
+	delay := retryDelay(b.attempts - 1)
+	if fetchOptions.Debug {
+		fmt.Fprintf(os.Stderr, "Download of %s interrupted after %s (%v); resuming in %v (attempt %d of %d)\n",
+			b.req.URL, formatMB(b.read), cause, delay.Round(time.Millisecond), b.attempts, fetchOptions.Retries)
+	}
+	time.Sleep(delay)
+
+	req := b.req.Clone(context.Background())
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", b.read))
+	req.Header.Set("If-Range", b.validator)
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return false
+	}
+	// A 200 means the document changed or the server ignores ranges
+	var start int64
+	if resp.StatusCode == http.StatusPartialContent {
+		fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start)
+	}
+	if resp.StatusCode != http.StatusPartialContent || start != b.read {
+		resp.Body.Close()
+		return false
+	}
+	b.ReadCloser.Close()
+	b.ReadCloser = resp.Body
+	return true
+}
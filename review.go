@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+// What the user decided for an issue in the review
+type reviewDecision int
+
+const (
+	reviewPending reviewDecision = iota
+	reviewApplied
+	reviewSkipped
+)
+
+// reviewModel is the --interactive terminal UI: a list of issues above a
+// source view scrolled to the selected one
+type reviewModel struct {
+	input     string
+	opts      ValidationOptions
+	content   []byte
+	ix        *lineIndex
+	errs      []ValidationError
+	decisions []reviewDecision
+	cursor    int // Selected issue
+	top       int // First line shown in the source view
+	width     int // Terminal size
+	height    int
+	status    string // Result of the last action, shown at the bottom
+	applied   int    // Fixes written to the file so far
+}
+
+// editorClosedMsg is sent when the $EDITOR started from the review exits
+type editorClosedMsg struct{ err error }
+
+// runReview lets the user go through the issues of a local file,
+// applying suggested fixes or opening them in $EDITOR, and returns the
+// issues left. Applied fixes are written to the file on quitting or
+// before the editor starts.
+func runReview(input string, ix *lineIndex, errs []ValidationError, opts ValidationOptions) ([]ValidationError, error) {
+	m := &reviewModel{input: input, opts: opts, width: 80, height: 24}
+	m.load(ix, errs)
+	final, err := tea.NewProgram(m).Run()
+	if err != nil {
+		return nil, err
+	}
+	m = final.(*reviewModel)
+	if err := m.save(); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Applied %d suggested fixes to %s; %d issues remain\n", m.applied, input, len(m.errs))
+	return m.errs, nil
+}
+
+// load shows the content ix indexes and its issues, selecting the first
+func (m *reviewModel) load(ix *lineIndex, errs []ValidationError) {
+	m.content, m.ix, m.errs = ix.content, ix, errs
+	m.decisions = make([]reviewDecision, len(errs))
+	m.cursor = 0
+	m.scrollToCursor()
+}
+
+// save writes the applied fixes to the file and checks it again, since
+// the byte ranges of the remaining fixes no longer match its content
+func (m *reviewModel) save() error {
+	var fixes []SuggestedFix
+	for i, d := range m.decisions {
+		if d == reviewApplied {
+			fixes = append(fixes, *m.errs[i].Fix)
+		}
+	}
+	if len(fixes) == 0 {
+		return nil
+	}
+	content := applySuggestedFixes(m.content, fixes)
+	if err := os.WriteFile(m.input, content, 0644); err != nil {
+		return err
+	}
+	m.applied += len(fixes)
+	m.revalidate(content)
+	return nil
+}
+
+// revalidate checks content again without printing progress lines over
+// the UI
+func (m *reviewModel) revalidate(content []byte) {
+	opts := m.opts
+	opts.Progress = io.Discard
+	ix := indexLines(content)
+	errs := validateXML(content, ix, opts)
+	applySeverities(errs, opts.Severities)
+	m.load(ix, errs)
+}
+
+func (m *reviewModel) Init() tea.Cmd { return nil }
+
+func (m *reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.scrollToCursor()
+	case editorClosedMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Editor failed: %v", msg.err)
+			break
+		}
+		content, err := os.ReadFile(m.input)
+		if err != nil {
+			m.status = fmt.Sprintf("Cannot read %s again: %v", m.input, err)
+			break
+		}
+		m.revalidate(content)
+		m.status = fmt.Sprintf("Checked again: %d issues", len(m.errs))
+	case tea.KeyPressMsg:
+		m.status = ""
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		case "down", "j", "tab":
+			m.selectIssue(m.cursor + 1)
+		case "up", "k", "shift+tab":
+			m.selectIssue(m.cursor - 1)
+		case "pgdown", "ctrl+d":
+			m.scroll(m.sourceHeight() / 2)
+		case "pgup", "ctrl+u":
+			m.scroll(-m.sourceHeight() / 2)
+		case "a", "enter":
+			if len(m.errs) == 0 {
+				break
+			}
+			if m.errs[m.cursor].Fix == nil {
+				m.status = "No suggested fix for this issue; press e to edit it"
+				break
+			}
+			m.decisions[m.cursor] = reviewApplied
+			m.selectIssue(m.cursor + 1)
+		case "s":
+			if len(m.errs) > 0 {
+				m.decisions[m.cursor] = reviewSkipped
+				m.selectIssue(m.cursor + 1)
+			}
+		case "e":
+			return m, m.edit()
+		}
+	}
+	return m, nil
+}
+
+// edit saves the applied fixes and opens the selected issue in $EDITOR
+func (m *reviewModel) edit() tea.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		m.status = "Set $EDITOR to open the file"
+		return nil
+	}
+	line := 1
+	if len(m.errs) > 0 {
+		line = m.errs[m.cursor].LineNumber
+	}
+	if err := m.save(); err != nil {
+		m.status = fmt.Sprintf("Cannot save fixes: %v", err)
+		return nil
+	}
+	// vi, nano and emacs all take +LINE before the file
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], "+"+strconv.Itoa(line), m.input)...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg { return editorClosedMsg{err} })
+}
+
+// selectIssue selects issue i, if there is one, and scrolls to it
+func (m *reviewModel) selectIssue(i int) {
+	if i < 0 || i >= len(m.errs) {
+		return
+	}
+	m.cursor = i
+	m.scrollToCursor()
+}
+
+// scrollToCursor centres the source view on the selected issue
+func (m *reviewModel) scrollToCursor() {
+	m.top = 1
+	if len(m.errs) > 0 {
+		m.top = m.errs[m.cursor].LineNumber - m.sourceHeight()/2
+	}
+	m.scroll(0)
+}
+
+// scroll moves the source view by n lines, keeping it within the file
+func (m *reviewModel) scroll(n int) {
+	m.top = min(m.top+n, m.ix.lines()-m.sourceHeight()+1)
+	m.top = max(m.top, 1)
+}
+
+// listHeight is how many issues the list shows at once
+func (m *reviewModel) listHeight() int {
+	return max(min(len(m.errs), m.height/3), 1)
+}
+
+// sourceHeight is how many lines the source view shows at once
+func (m *reviewModel) sourceHeight() int {
+	// Title, three rules and the help line
+	return max(m.height-m.listHeight()-5, 3)
+}
+
+func (m *reviewModel) View() tea.View {
+	var b strings.Builder
+	rule := headerColor(strings.Repeat("─", m.width))
+
+	b.WriteString(headerColor(clip(fmt.Sprintf("Reviewing %s: %d issues", m.input, len(m.errs)), m.width)) + "\n")
+	b.WriteString(rule + "\n")
+	m.viewList(&b)
+	b.WriteString(rule + "\n")
+	m.viewSource(&b)
+	b.WriteString(rule + "\n")
+	help := "↑/↓ select  a apply fix  s skip  e edit in $EDITOR  PgUp/PgDn scroll  q save and quit"
+	if m.status != "" {
+		help = m.status
+	}
+	b.WriteString(infoColor(clip(help, m.width)))
+	view := tea.NewView(b.String())
+	view.AltScreen = true
+	return view
+}
+
+// viewList renders the window of the issue list around the selection
+func (m *reviewModel) viewList(b *strings.Builder) {
+	if len(m.errs) == 0 {
+		b.WriteString(successColor("✅ No issues left") + "\n")
+		return
+	}
+	first := max(min(m.cursor-m.listHeight()/2, len(m.errs)-m.listHeight()), 0)
+	for i := first; i < first+m.listHeight(); i++ {
+		e := m.errs[i]
+		mark := "  "
+		switch m.decisions[i] {
+		case reviewApplied:
+			mark = "✔ "
+		case reviewSkipped:
+			mark = "– "
+		}
+		fix := ""
+		if e.Fix != nil {
+			fix = " (fix)"
+		}
+		text := clip(fmt.Sprintf("%s%4d:%-3d %s %s%s", mark, e.LineNumber, errorColumn(m.ix, e, m.opts.ByteColumns), e.ruleID(), e.Message, fix), m.width-2)
+		switch {
+		case i == m.cursor:
+			b.WriteString(highlightColor("> " + text))
+		case m.decisions[i] != reviewPending:
+			b.WriteString("  " + infoColor(text))
+		default:
+			b.WriteString("  " + text)
+		}
+		b.WriteString("\n")
+	}
+}
+
+// viewSource renders the visible source lines, marking the selected
+// issue's line and column
+func (m *reviewModel) viewSource(b *strings.Builder) {
+	var sel *ValidationError
+	if len(m.errs) > 0 {
+		sel = &m.errs[m.cursor]
+	}
+	shown := 0
+	for n := m.top; n <= m.ix.lines() && shown < m.sourceHeight(); n++ {
+		raw := string(m.ix.line(n))
+		line := expandTabs(raw)
+		number := infoColor(fmt.Sprintf("%5d ", n))
+		if sel != nil && n == sel.LineNumber {
+			b.WriteString(number + highlightColor(clip(line, m.width-6)) + "\n")
+			shown++
+			if sel.Column > 0 && shown < m.sourceHeight() {
+				at := runewidth.StringWidth(expandTabs(raw[:min(sel.Column-1, len(raw))]))
+				b.WriteString(strings.Repeat(" ", 6+at) + errorColor("^") + "\n")
+				shown++
+			}
+		} else {
+			b.WriteString(number + clip(line, m.width-6) + "\n")
+			shown++
+		}
+	}
+	for ; shown < m.sourceHeight(); shown++ {
+		b.WriteString("\n")
+	}
+}
+
+// clip shortens s to at most width terminal columns
+func clip(s string, width int) string {
+	return runewidth.Truncate(s, max(width, 0), "…")
+}
+
+// applySuggestedFixes returns content with fixes applied. Fixes refer to
+// byte ranges of content; one overlapping an earlier fix is left out.
+func applySuggestedFixes(content []byte, fixes []SuggestedFix) []byte {
+	sort.SliceStable(fixes, func(i, j int) bool { return fixes[i].Start < fixes[j].Start })
+	var out []byte
+	at := 0
+	for _, f := range fixes {
+		if f.Start < at || f.End > len(content) {
+			continue
+		}
+		out = append(out, content[at:f.Start]...)
+		out = append(out, f.Replacement...)
+		at = f.End
+	}
+	return append(out, content[at:]...)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RSS 2.0 elements allowed (without a namespace) inside each parent, from
+// the RSS 2.0 specification at https://www.rssboard.org/rss-specification
+var rssVocabulary = map[string][]string{
+	"channel": {"title", "link", "description", "language", "copyright", "managingEditor",
+		"webMaster", "pubDate", "lastBuildDate", "category", "generator", "docs", "cloud",
+		"ttl", "image", "rating", "textInput", "skipHours", "skipDays", "item"},
+	"item":      {"title", "link", "description", "author", "category", "comments", "enclosure", "guid", "pubDate", "source"},
+	"image":     {"url", "title", "link", "width", "height", "description"},
+	"textInput": {"title", "description", "name", "link"},
+	"skipHours": {"hour"},
+	"skipDays":  {"day"},
+}
+
+// Children each element must have
+var rssRequired = map[string][]string{
+	"channel":   {"title", "link", "description"},
+	"image":     {"url", "title", "link"},
+	"textInput": {"title", "description", "name", "link"},
+}
+
+// Date layouts accepted for RFC 822 dates. RSS allows the day of week and
+// seconds to be omitted and single-digit days are common in the wild.
+var rfc822Layouts = []string{
+	"Mon, 02 Jan 2006 15:04:05 -0700",
+	"Mon, 02 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"Mon, 02 Jan 2006 15:04 MST",
+	"02 Jan 2006 15:04:05 -0700",
+	"02 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+}
+
+// parseRFC822 parses an RSS date, returning false if no layout matches
+func parseRFC822(value string) (time.Time, bool) {
+	for _, layout := range rfc822Layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// validateRSS checks RSS 2.0 feed structure
+func validateRSS(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	root, err := parseTree(content)
+	if err != nil || root == nil {
+		return errors // Reported by validateBasicXML
+	}
+
+	if root.Name.Local != "rss" {
+		return append(errors, nodeError(ix, root, "RSS structure",
+			fmt.Sprintf("Root element is <%s>, an RSS feed must start with <rss>", root.Name.Local)))
+	}
+	if version, _ := root.attr("version"); version != "2.0" {
+		errors = append(errors, nodeError(ix, root, "RSS structure",
+			fmt.Sprintf("<rss> version is %q, expected \"2.0\"", version)))
+	}
+
+	channels := root.childrenNamed("", "channel")
+	if len(channels) != 1 {
+		return append(errors, nodeError(ix, root, "RSS structure",
+			fmt.Sprintf("<rss> must contain exactly one <channel>, found %d", len(channels))))
+	}
+
+	errors = append(errors, checkRSSElement(ix, channels[0])...)
+	errors = append(errors, checkRSSEnclosures(ix, channels[0], opts)...)
+	return errors
+}
+
+// checkRSSElement validates an element of the RSS vocabulary and recurses
+// into its RSS children; namespaced extension elements are left alone
+func checkRSSElement(ix *lineIndex, n *xmlNode) []ValidationError {
+	var errors []ValidationError
+	name := n.Name.Local
+
+	for _, req := range rssRequired[name] {
+		if c := n.child("", req); c == nil {
+			errors = append(errors, nodeError(ix, n, "RSS missing element",
+				fmt.Sprintf("<%s> is missing required <%s>", name, req)))
+		}
+	}
+
+	switch name {
+	case "item":
+		if n.child("", "title") == nil && n.child("", "description") == nil {
+			errors = append(errors, nodeError(ix, n, "RSS missing element",
+				"<item> must contain at least one of <title> or <description>"))
+		}
+	case "enclosure":
+		for _, attr := range []string{"url", "length", "type"} {
+			if _, ok := n.attr(attr); !ok {
+				errors = append(errors, nodeError(ix, n, "RSS invalid enclosure",
+					fmt.Sprintf("<enclosure> is missing the required %s attribute", attr)))
+			}
+		}
+	case "pubDate", "lastBuildDate":
+		if dateErr := checkDateNode(ix, n, name, "rfc822"); dateErr != nil {
+			errors = append(errors, *dateErr)
+		}
+	}
+
+	allowed := rssVocabulary[name]
+	for _, c := range n.Children {
+		switch {
+		case c.Name.Space != "" && !strings.Contains(c.Name.Space, ":"):
+			// The decoder leaves undeclared prefixes in place of a URI
+			errors = append(errors, nodeError(ix, c, "RSS unknown element",
+				fmt.Sprintf("<%s:%s> uses the undeclared namespace prefix %q", c.Name.Space, c.Name.Local, c.Name.Space)))
+		case c.Name.Space != "":
+			// Extension element in a declared namespace
+		case !slices.Contains(allowed, c.Name.Local):
+			errors = append(errors, nodeError(ix, c, "RSS unknown element",
+				fmt.Sprintf("<%s> is not an RSS 2.0 element inside <%s>; extensions must use a namespace", c.Name.Local, name)))
+		default:
+			errors = append(errors, checkRSSElement(ix, c)...)
+		}
+	}
+
+	return errors
+}
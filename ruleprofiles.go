@@ -0,0 +1,125 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// RuleProfile is a named selection of checks and severities, chosen with
+// --profile like the document profiles. Config files can add their own
+// under "profiles", usually extending a built-in one:
+//
+//	profiles:
+//	  our-blog:
+//	    extends: wordpress
+//	    disable: [css]
+//	    severity:
+//	      hex-colors: error
+type RuleProfile struct {
+	Extends     string            `yaml:"extends"`      // Profile whose settings this one starts from
+	Document    string            `yaml:"document"`     // Document profile to run, e.g. wxr
+	CheckHTML   bool              `yaml:"check-html"`   // Like --check-html
+	CheckDesign bool              `yaml:"check-design"` // Like --check-design
+	CheckDates  bool              `yaml:"check-dates"`  // Like --check-dates
+	Checks      []string          `yaml:"checks"`       // Like --checks; --checks on the command line replaces it
+	Disable     []string          `yaml:"disable"`      // Like --disable; --disable on the command line adds to it
+	Severity    map[string]string `yaml:"severity"`     // Severity overrides, under those of the config file
+	FailOn      string            `yaml:"fail-on"`      // Like --fail-on, unless that is given
+}
+
+// ruleProfiles are the built-in rule profiles
+var ruleProfiles = map[string]RuleProfile{
+	// Everything that applies to any document, and warnings fail the run
+	"strict": {CheckHTML: true, CheckDesign: true, CheckDates: true, FailOn: severityWarning},
+	// Only what stops a parser or importer outright
+	"minimal": {Checks: []string{"basic", "cdata", "control-chars"}},
+	// WordPress exports: WXR structure, embedded HTML and post dates, with
+	// styling problems WordPress tolerates as warnings
+	"wordpress": {
+		Document: "wxr", CheckHTML: true, CheckDesign: true, CheckDates: true,
+		Severity: map[string]string{"hex-colors": severityWarning, "css": severityWarning},
+	},
+	// SVG files and inline SVG: markup, colors and styles
+	"svg": {CheckDesign: true, Checks: []string{"basic", "control-chars", "svg", "hex-colors", "css"}},
+	// Documents carrying styled content: the default checks plus colors,
+	// SVG and CSS
+	"design": {CheckDesign: true},
+	// RSS and Atom feeds: dates matter, embedded styling is not checked
+	"feed": {CheckDates: true},
+}
+
+// isProfileName reports whether --profile already accepts name without a
+// config file
+func isProfileName(name string) bool {
+	_, document := profiles[name]
+	_, rules := ruleProfiles[name]
+	return document || rules
+}
+
+// resolveRuleProfile returns the rule profile called name, from the config
+// file or built in, with what it extends merged in
+func resolveRuleProfile(name string, custom map[string]RuleProfile) (RuleProfile, error) {
+	var chain []RuleProfile
+	for seen := map[string]bool{}; name != ""; {
+		if seen[name] {
+			return RuleProfile{}, fmt.Errorf("profile %q extends itself", name)
+		}
+		seen[name] = true
+		p, ok := custom[name]
+		if !ok {
+			if p, ok = ruleProfiles[name]; !ok {
+				return RuleProfile{}, fmt.Errorf("unknown profile %q (want %s)", name, strings.Join(profileNames(custom), ", "))
+			}
+		}
+		chain = append(chain, p)
+		name = p.Extends
+	}
+
+	// From the base profile up, each setting overrides or adds to the last
+	var merged RuleProfile
+	for _, p := range slices.Backward(chain) {
+		merged.Document = cmp.Or(p.Document, merged.Document)
+		merged.CheckHTML = merged.CheckHTML || p.CheckHTML
+		merged.CheckDesign = merged.CheckDesign || p.CheckDesign
+		merged.CheckDates = merged.CheckDates || p.CheckDates
+		if p.Checks != nil {
+			merged.Checks = p.Checks
+		}
+		merged.Disable = append(slices.Clone(merged.Disable), p.Disable...)
+		merged.Severity = mergeSeverities(merged.Severity, p.Severity)
+		merged.FailOn = cmp.Or(p.FailOn, merged.FailOn)
+	}
+	return merged, nil
+}
+
+// mergeSeverities returns base with the overrides in top replacing its own
+func mergeSeverities(base, top map[string]string) map[string]string {
+	if len(top) == 0 {
+		return base
+	}
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	maps.Copy(merged, top)
+	return merged
+}
+
+// profileNames lists the names --profile accepts
+func profileNames(custom map[string]RuleProfile) []string {
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	for name := range ruleProfiles {
+		names = append(names, name)
+	}
+	for name := range custom {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// ruleSwitch is a flag that enables optional rules, with the options it sets
+type ruleSwitch struct {
+	flag string
+	opts ValidationOptions
+}
+
+// optionalRules returns a switch for each flag that adds rules
+func optionalRules() []ruleSwitch {
+	switches := []ruleSwitch{
+		{"--target=mysql-utf8mb3", ValidationOptions{Target: "mysql-utf8mb3"}},
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		switches = append(switches, ruleSwitch{"--profile=" + name, ValidationOptions{Profile: name}})
+	}
+	return append(switches,
+		ruleSwitch{"--check-design", ValidationOptions{CheckDesign: true}},
+		ruleSwitch{"--check-html", ValidationOptions{CheckHTML: true}},
+		ruleSwitch{"--check-dates", ValidationOptions{CheckDates: true}},
+	)
+}
+
+// How the issues of a rule can be corrected, in "rules --format=json"
+const (
+	fixAuto      = "auto"      // The fix subcommand corrects them
+	fixSuggested = "suggested" // Each carries a fix for --emit-fixes and --interactive
+	fixManual    = "manual"
+)
+
+// fixableRules are the rules whose issues are not only fixed by hand
+var fixableRules = map[string]string{
+	"CHAR001":  fixSuggested,
+	"CHAR002":  fixSuggested,
+	"COLOR001": fixAuto,
+	"SVG001":   fixSuggested,
+	"SVG002":   fixAuto,
+	"SVG005":   fixSuggested,
+}
+
+// catalogEntry describes one rule in "rules --format=json"
+type catalogEntry struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	Description     string `json:"description"`
+	Category        string `json:"category"`   // The check that reports it, for --checks and --disable
+	EnabledBy       string `json:"enabled_by"` // "always", or the flag that adds the check
+	DefaultSeverity string `json:"default_severity"`
+	Fixable         string `json:"fixable"` // auto, suggested or manual
+}
+
+// runRuleList implements the "rules" subcommand, listing the checks
+// validate runs and the flags that enable the optional ones, or every
+// rule in JSON for tools that document or configure them. "rules test"
+// runs one rule over fixtures instead.
+func runRuleList(args []string) {
+	if len(args) > 0 && args[0] == "test" {
+		runRuleTest(args[1:])
+		return
+	}
+	rfs := flag.NewFlagSet("rules", flag.ContinueOnError)
+	format := rfs.String("format", "text", "Output format: text (the checks) or json (every rule, with its check, default severity and fixability)")
+	configPath := rfs.String("config", "", "Also list the custom rules of this config file")
+	parseFlags(rfs, args)
+
+	var custom []CustomRule
+	if *configPath != "" {
+		config, err := loadConfig(*configPath, true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error reading config: %v\n", err)
+			os.Exit(configExitCode(err))
+		}
+		custom = config.Rules
+	}
+	opts := ValidationOptions{CustomRules: custom}
+
+	switch *format {
+	case "text":
+		listChecks(opts)
+	case "json":
+		if err := writeRuleCatalog(os.Stdout, custom); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitIO)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Unknown --format %q (want text or json)\n", *format)
+		os.Exit(exitUsage)
+	}
+}
+
+// listChecks prints the checks validate always runs, those the optional
+// rule flags add and the custom rules in opts
+func listChecks(opts ValidationOptions) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Rule\tEnabled\tChecks")
+	fmt.Fprintln(w, "basic\talways\twell-formedness")
+	listed := make(map[string]bool)
+	list := func(opts ValidationOptions, enabled string) {
+		for _, r := range validationRules(opts) {
+			if listed[r.name] {
+				continue
+			}
+			listed[r.name] = true
+			checks := strings.TrimSuffix(strings.TrimPrefix(r.progress, "Checking "), "...")
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.name, enabled, checks)
+		}
+	}
+	list(ValidationOptions{}, "always")
+	for _, s := range optionalRules() {
+		list(s.opts, s.flag)
+	}
+	list(opts, "config")
+	w.Flush()
+}
+
+// writeRuleCatalog writes every built-in rule, then those registered from
+// Go and the custom rules, as a JSON array
+func writeRuleCatalog(w io.Writer, custom []CustomRule) error {
+	enabledBy := map[string]string{"basic": "always"}
+	for _, r := range validationRules(ValidationOptions{}) {
+		enabledBy[r.name] = "always"
+	}
+	for _, s := range optionalRules() {
+		for _, r := range validationRules(s.opts) {
+			if enabledBy[r.name] == "" {
+				enabledBy[r.name] = s.flag
+			}
+		}
+	}
+
+	var entries []catalogEntry
+	for _, r := range ruleCatalog {
+		entries = append(entries, catalogEntry{
+			ID:              r.id,
+			Type:            r.errorType,
+			Description:     r.summary,
+			Category:        r.check,
+			EnabledBy:       cmp.Or(enabledBy[r.check], "always"),
+			DefaultSeverity: severityError,
+			Fixable:         cmp.Or(fixableRules[r.id], fixManual),
+		})
+	}
+	for _, r := range registeredRules {
+		entries = append(entries, catalogEntry{
+			ID:              r.ID(),
+			Type:            "Rule " + r.ID(),
+			Category:        r.ID(),
+			EnabledBy:       "always",
+			DefaultSeverity: severityError,
+			Fixable:         fixManual,
+		})
+	}
+	for _, r := range custom {
+		fixable := fixManual
+		if r.Replacement != nil {
+			fixable = fixAuto
+		}
+		entries = append(entries, catalogEntry{
+			ID:              r.ID,
+			Type:            r.errorType(),
+			Description:     r.Message,
+			Category:        r.Name,
+			EnabledBy:       "config",
+			DefaultSeverity: cmp.Or(r.Severity, severityError),
+			Fixable:         fixable,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false) // Examples and descriptions quote markup
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ruleFixture is a document "rules test" runs a rule against: one named
+// *.good.xml must not get any issue of the rule, one named *.bad.xml must
+// get at least one
+type ruleFixture struct {
+	path string
+	bad  bool
+}
+
+// runRuleTest implements "rules test", which runs the check reporting one
+// rule over a directory of fixtures and reports those where the rule does
+// not behave as their name says, to develop built-in and custom rules
+// against
+func runRuleTest(args []string) {
+	tfs := flag.NewFlagSet("rules test", flag.ContinueOnError)
+	configPath := tfs.String("config", "", "Config file with the custom rules and scopes to use")
+	verbose := tfs.Bool("verbose", false, "List the fixtures that pass too, and the issues of each")
+	parseFlags(tfs, args)
+	if tfs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "❌ Usage: xml_validator rules test [--config=FILE] <rule-id> <fixture-dir>")
+		os.Exit(exitUsage)
+	}
+
+	opts := ValidationOptions{Progress: io.Discard}
+	if *configPath != "" {
+		config, err := loadConfig(*configPath, true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error reading config: %v\n", err)
+			os.Exit(configExitCode(err))
+		}
+		opts.CustomRules, opts.Scopes = config.Rules, config.Scope
+		registerCustomRules(config.Rules)
+	}
+
+	id := tfs.Arg(0)
+	info := ruleByID[id]
+	if info == nil {
+		info = ruleByID[strings.ToUpper(id)]
+	}
+	if info == nil {
+		fmt.Fprintf(os.Stderr, "❌ Unknown rule ID %q (run \"xml_validator rules --format=json\" for the list)\n", id)
+		os.Exit(exitUsage)
+	}
+	opts, err := ruleTestOptions(info, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	fixtures, err := findRuleFixtures(tfs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitIO)
+	}
+	if len(fixtures) == 0 {
+		fmt.Fprintf(os.Stderr, "❌ No *.good.xml or *.bad.xml fixtures in %s\n", tfs.Arg(1))
+		os.Exit(exitUsage)
+	}
+
+	failed := 0
+	for _, f := range fixtures {
+		content, err := os.ReadFile(f.path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitIO)
+		}
+		var found []ValidationError
+		for _, e := range validateXML(content, indexLines(content), opts) {
+			if e.ruleID() == info.id {
+				found = append(found, e)
+			}
+		}
+
+		switch {
+		case f.bad && len(found) == 0:
+			failed++
+			fmt.Printf("%s %s: expected %s to report an issue, got none\n", errorColor("❌"), f.path, info.id)
+		case !f.bad && len(found) > 0:
+			failed++
+			fmt.Printf("%s %s: expected no %s issues, got %d\n", errorColor("❌"), f.path, info.id, len(found))
+			for _, e := range found {
+				fmt.Printf("    line %d, column %d: %s\n", e.LineNumber, e.Column, e.Message)
+			}
+		case *verbose:
+			fmt.Printf("%s %s\n", successColor("✅"), f.path)
+			for _, e := range found {
+				fmt.Printf("    line %d, column %d: %s\n", e.LineNumber, e.Column, e.Message)
+			}
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n%s %d of %d fixtures do not match %s\n", errorColor("❌"), failed, len(fixtures), info.id)
+		os.Exit(exitValidation)
+	}
+	fmt.Printf("%s All %d fixtures match %s\n", successColor("✅"), len(fixtures), info.id)
+}
+
+// ruleTestOptions returns opts with only the check reporting the rule
+// enabled, turning on the flag that adds it if it is optional. Well-
+// formedness is skipped too unless it is the rule under test, so a bad
+// fixture can be as small as the problem.
+func ruleTestOptions(info *ruleInfo, opts ValidationOptions) (ValidationOptions, error) {
+	if info.check != "basic" && !slices.ContainsFunc(validationRules(opts), func(r rule) bool { return r.name == info.check }) {
+		for _, s := range optionalRules() {
+			if slices.ContainsFunc(validationRules(s.opts), func(r rule) bool { return r.name == info.check }) {
+				opts.Target, opts.Profile = s.opts.Target, s.opts.Profile
+				opts.CheckDesign, opts.CheckHTML, opts.CheckDates = s.opts.CheckDesign, s.opts.CheckHTML, s.opts.CheckDates
+				break
+			}
+		}
+	}
+	skip, err := skippedChecks(opts, []string{info.check}, nil)
+	opts.Skip = skip
+	return opts, err
+}
+
+// findRuleFixtures returns the *.good.xml and *.bad.xml files in dir, in
+// name order
+func findRuleFixtures(dir string) ([]ruleFixture, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []ruleFixture
+	for _, e := range entries {
+		switch name := e.Name(); {
+		case e.IsDir():
+		case strings.HasSuffix(name, ".good.xml"):
+			fixtures = append(fixtures, ruleFixture{path: filepath.Join(dir, name)})
+		case strings.HasSuffix(name, ".bad.xml"):
+			fixtures = append(fixtures, ruleFixture{path: filepath.Join(dir, name), bad: true})
+		}
+	}
+	return fixtures, nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// reLooksSerialized mirrors WordPress's is_serialized(): a type marker
+// followed by a colon, or the null value
+var reLooksSerialized = regexp.MustCompile(`^([aOCsSEidbrR]:|N;)`)
+
+// serializedError describes where a PHP serialized value stops parsing
+type serializedError struct {
+	pos      int
+	message  string
+	declared int // Declared string length, when the problem is a length mismatch
+	actual   int
+}
+
+func (e *serializedError) Error() string {
+	return fmt.Sprintf("%s at byte %d", e.message, e.pos)
+}
+
+// phpUnserializer walks PHP serialize() output without building values,
+// only checking that every declared length and count is consistent
+type phpUnserializer struct {
+	data string
+	pos  int
+}
+
+// checkWXRSerializedMeta reports <wp:meta_value> contents that look like
+// PHP serialized data but cannot be unserialized. The usual cause is a
+// search-replace on the database dump that changed a string without
+// updating its s:N: byte length, which silently breaks widgets and options.
+func checkWXRSerializedMeta(ix *lineIndex, channel *xmlNode, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	var walk func(n *xmlNode, item *xmlNode)
+	walk = func(n *xmlNode, item *xmlNode) {
+		for _, c := range n.Children {
+			if c.Name.Local == "item" && c.Name.Space == "" {
+				walk(c, c)
+				continue
+			}
+			if c.Name.Local != "meta_value" || !isWPNamespace(c.Name.Space) {
+				walk(c, item)
+				continue
+			}
+
+			value := strings.TrimSpace(c.Text)
+			if !reLooksSerialized.MatchString(value) {
+				continue
+			}
+			err := checkSerialized(value)
+			if err == nil {
+				continue
+			}
+
+			key := "(no key)"
+			if k := wpChild(n, "meta_key"); k != nil {
+				key = k.trimmedText()
+			}
+			where := fmt.Sprintf("meta %q", key)
+			if item != nil {
+				where = fmt.Sprintf("meta %q of item %s", key, describeItem(item))
+			}
+
+			message := fmt.Sprintf("Serialized %s is corrupted: %s", where, err)
+			if err.declared >= 0 {
+				message = fmt.Sprintf("Serialized %s declares a %d-byte string but it is %d bytes (at byte %d); PHP cannot unserialize it, usually after a search-replace",
+					where, err.declared, err.actual, err.pos)
+			}
+			errors = append(errors, nodeError(ix, c, "WXR corrupted serialized data", message))
+		}
+	}
+	walk(channel, nil)
+
+	return errors
+}
+
+// checkSerialized parses one serialized value and requires that nothing
+// follows it
+func checkSerialized(data string) *serializedError {
+	p := &phpUnserializer{data: data}
+	if err := p.value(); err != nil {
+		return err
+	}
+	if p.pos != len(p.data) {
+		return p.fail("unexpected data after the value")
+	}
+	return nil
+}
+
+func (p *phpUnserializer) fail(message string) *serializedError {
+	return &serializedError{pos: p.pos, message: message, declared: -1}
+}
+
+// expect consumes s or fails
+func (p *phpUnserializer) expect(s string) *serializedError {
+	if !strings.HasPrefix(p.data[p.pos:], s) {
+		return p.fail(fmt.Sprintf("expected %q", s))
+	}
+	p.pos += len(s)
+	return nil
+}
+
+// until consumes and returns everything up to the next terminator byte
+func (p *phpUnserializer) until(terminator byte) (string, *serializedError) {
+	end := strings.IndexByte(p.data[p.pos:], terminator)
+	if end < 0 {
+		return "", p.fail(fmt.Sprintf("missing %q", terminator))
+	}
+	s := p.data[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// count reads a non-negative integer terminated by a colon
+func (p *phpUnserializer) count() (int, *serializedError) {
+	start := p.pos
+	s, err := p.until(':')
+	if err != nil {
+		return 0, err
+	}
+	n, convErr := strconv.Atoi(s)
+	if convErr != nil || n < 0 {
+		p.pos = start
+		return 0, p.fail(fmt.Sprintf("invalid length %q", s))
+	}
+	return n, nil
+}
+
+// quoted reads a "..." string of the declared byte length
+func (p *phpUnserializer) quoted(length int, terminator string) *serializedError {
+	if err := p.expect(`"`); err != nil {
+		return err
+	}
+	start := p.pos
+	closing := `"` + terminator
+	// Compared before adding, which a huge declared length would overflow
+	if length <= len(p.data)-start && strings.HasPrefix(p.data[start+length:], closing) {
+		p.pos = start + length + len(closing)
+		return nil
+	}
+
+	// Report the length the string really has, assuming it ends at the
+	// first closing quote after its start
+	actual := strings.Index(p.data[start:], closing)
+	if actual < 0 {
+		return &serializedError{pos: start, message: "unterminated string", declared: -1}
+	}
+	return &serializedError{pos: start, message: "string length mismatch", declared: length, actual: actual}
+}
+
+// value parses one serialized value of any type
+func (p *phpUnserializer) value() *serializedError {
+	if p.pos+1 >= len(p.data) {
+		return p.fail("unexpected end of data")
+	}
+	kind := p.data[p.pos]
+	if kind == 'N' {
+		return p.expect("N;")
+	}
+	p.pos++
+	if err := p.expect(":"); err != nil {
+		return err
+	}
+
+	switch kind {
+	case 'b', 'i', 'd', 'r', 'R':
+		start := p.pos
+		s, err := p.until(';')
+		if err != nil {
+			return err
+		}
+		if kind == 'd' {
+			if _, convErr := strconv.ParseFloat(s, 64); convErr != nil && s != "INF" && s != "-INF" && s != "NAN" {
+				p.pos = start
+				return p.fail(fmt.Sprintf("invalid float %q", s))
+			}
+		} else if _, convErr := strconv.Atoi(s); convErr != nil || (kind == 'b' && s != "0" && s != "1") {
+			p.pos = start
+			return p.fail(fmt.Sprintf("invalid %c value %q", kind, s))
+		}
+		return nil
+
+	case 's', 'S', 'E':
+		length, err := p.count()
+		if err != nil {
+			return err
+		}
+		return p.quoted(length, ";")
+
+	case 'a':
+		n, err := p.count()
+		if err != nil {
+			return err
+		}
+		return p.members(n)
+
+	case 'O':
+		length, err := p.count()
+		if err != nil {
+			return err
+		}
+		if err := p.quoted(length, ":"); err != nil {
+			return err
+		}
+		n, err := p.count()
+		if err != nil {
+			return err
+		}
+		return p.members(n)
+
+	case 'C':
+		length, err := p.count()
+		if err != nil {
+			return err
+		}
+		if err := p.quoted(length, ":"); err != nil {
+			return err
+		}
+		// Custom serialization: an opaque payload of the declared length
+		dataLength, err := p.count()
+		if err != nil {
+			return err
+		}
+		if err := p.expect("{"); err != nil {
+			return err
+		}
+		if dataLength >= len(p.data)-p.pos {
+			return p.fail("declared length exceeds data")
+		}
+		if p.data[p.pos+dataLength] != '}' {
+			return p.fail(fmt.Sprintf("object payload is not %d bytes long", dataLength))
+		}
+		p.pos += dataLength + 1
+		return nil
+	}
+
+	p.pos--
+	return p.fail(fmt.Sprintf("unknown type %q", kind))
+}
+
+// members parses the {key value ...} body of an array or object with n
+// entries
+func (p *phpUnserializer) members(n int) *serializedError {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if strings.HasPrefix(p.data[p.pos:], "}") {
+			return p.fail(fmt.Sprintf("declares %d entries but has %d", n, i))
+		}
+		if err := p.value(); err != nil {
+			return err
+		}
+		if err := p.value(); err != nil {
+			return err
+		}
+	}
+	if p.pos >= len(p.data) || p.data[p.pos] != '}' {
+		return p.fail(fmt.Sprintf("expected %d entries", n))
+	}
+	p.pos++
+	return nil
+}
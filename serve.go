@@ -0,0 +1,334 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/yourusername/go-xml-validator/validatorpb"
+	"google.golang.org/grpc"
+)
+
+// serveConfig is what every request to the server shares
+type serveConfig struct {
+	config  Config
+	maxBody int64
+	base    ValidationOptions
+	slots   chan struct{} // One for each document being validated, see --max-concurrent
+}
+
+// runServe implements the "serve" subcommand: an HTTP server that
+// validates documents posted to /validate and answers with JSON or SARIF,
+// and with --grpc-listen a gRPC server streaming back the issues of a
+// streamed document, so other services can validate XML without running
+// the binary
+func runServe(args []string) {
+	sfs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := sfs.String("listen", ":8080", "Address to listen on, e.g. :8080 or 127.0.0.1:9000; empty for no HTTP server")
+	grpcListen := sfs.String("grpc-listen", "", "Also serve the Validator gRPC service (validatorpb/validator.proto) on this address, e.g. :9090")
+	configPath := sfs.String("config", defaultConfigFile, "Config file with severity overrides, custom rules and rule profiles")
+	maxBody := sfs.String("max-body", "50MB", "Largest document accepted")
+	maxErrors := sfs.Int("max-errors", 0, "Maximum number of issues to report for each document, 0 for no limit")
+	maxConcurrent := sfs.Int("max-concurrent", runtime.NumCPU(), "Documents validated at once; further requests wait their turn. Each can take about 3 times --max-body of memory")
+	parseFlags(sfs, args)
+	if sfs.NArg() != 0 || *maxErrors < 0 || *maxConcurrent < 1 || *listen == "" && *grpcListen == "" {
+		fmt.Fprintln(os.Stderr, "❌ Usage: xml_validator serve [--listen=ADDR] [--grpc-listen=ADDR] [--config=FILE] [--max-body=SIZE] [--max-errors=N] [--max-concurrent=N]")
+		os.Exit(exitUsage)
+	}
+	limit, err := parseByteSize(*maxBody)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid --max-body: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	explicit := make(map[string]bool)
+	sfs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	config, err := loadConfig(*configPath, explicit["config"])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading config: %v\n", err)
+		os.Exit(configExitCode(err))
+	}
+	registerCustomRules(config.Rules)
+	// Several documents are validated at once; no progress bars
+	showProgress = false
+
+	s := &serveConfig{
+		config:  config,
+		maxBody: limit,
+		base: ValidationOptions{
+			MaxErrors:   *maxErrors,
+			Progress:    io.Discard,
+			EmbeddedSVG: "xml",
+			FailOn:      severityError,
+			CustomRules: config.Rules,
+			Scopes:      config.Scope,
+			Severities:  config.Severity,
+		},
+		slots: make(chan struct{}, *maxConcurrent),
+	}
+	// Either server stopping ends the command
+	stopped := make(chan error, 2)
+	if *grpcListen != "" {
+		lis, err := net.Listen("tcp", *grpcListen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitIO)
+		}
+		server := grpc.NewServer()
+		validatorpb.RegisterValidatorServer(server, grpcValidator{s: s})
+		fmt.Fprintln(os.Stderr, infoColor(fmt.Sprintf("Serving gRPC on %s", lis.Addr())))
+		go func() { stopped <- server.Serve(lis) }()
+	}
+	if *listen != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/validate", s.handleValidate)
+		server := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		fmt.Fprintln(os.Stderr, infoColor(fmt.Sprintf("Listening on %s; POST documents to /validate", *listen)))
+		go func() { stopped <- server.ListenAndServe() }()
+	}
+	fmt.Fprintf(os.Stderr, "❌ %v\n", <-stopped)
+	os.Exit(exitIO)
+}
+
+// handleValidate validates the document in the request body, or in the
+// first file of a multipart upload. The profile parameter works like
+// --profile; format is json (the default) or sarif; name is the file name
+// to report, by default the upload's.
+func (s *serveConfig) handleValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		serveError(w, http.StatusMethodNotAllowed, "POST the document to validate")
+		return
+	}
+	format := cmp.Or(r.URL.Query().Get("format"), "json")
+	if format != "json" && format != "sarif" {
+		serveError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q (want json or sarif)", format))
+		return
+	}
+	opts, err := s.options(r.URL.Query().Get("profile"), r.URL.Query().Has("profile"))
+	if err != nil {
+		serveError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The body is only read once there is room to validate it
+	if !s.acquire(r.Context()) {
+		return
+	}
+	defer s.release()
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBody)
+	content, name, err := readUpload(r)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		serveError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("document larger than %s", formatMB(s.maxBody)))
+		return
+	case err != nil:
+		serveError(w, http.StatusBadRequest, err.Error())
+		return
+	case len(content) == 0:
+		serveError(w, http.StatusBadRequest, "empty document")
+		return
+	}
+	name = cmp.Or(r.URL.Query().Get("name"), name, "document.xml")
+
+	ix := indexLines(content)
+	errs := validateXML(content, ix, opts)
+	applySeverities(errs, opts.Severities)
+	if format == "sarif" {
+		writeServeJSON(w, "application/sarif+json", sarifReport(name, ix, errs))
+		return
+	}
+	writeServeJSON(w, "application/json", jsonReport(name, ix, errs, opts.FailOn))
+}
+
+// acquire waits for one of the --max-concurrent slots, or returns false
+// when ctx is done first, e.g. because the client went away
+func (s *serveConfig) acquire(ctx context.Context) bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees the slot taken by acquire
+func (s *serveConfig) release() {
+	<-s.slots
+}
+
+// options returns the validation options for a request's profile
+// parameter, applied the way validate applies --profile
+func (s *serveConfig) options(profile string, given bool) (ValidationOptions, error) {
+	opts := s.base
+	opts.Detect = !given
+	if profile == "none" || !given {
+		return opts, nil
+	}
+	if _, ok := profiles[profile]; ok {
+		opts.Profile = profile
+		return opts, nil
+	}
+	rp, err := resolveRuleProfile(profile, s.config.Profiles)
+	if err != nil {
+		return opts, err
+	}
+	opts.Profile = rp.Document
+	opts.CheckHTML, opts.CheckDesign, opts.CheckDates = rp.CheckHTML, rp.CheckDesign, rp.CheckDates
+	opts.Severities = mergeSeverities(rp.Severity, opts.Severities)
+	opts.FailOn = cmp.Or(rp.FailOn, opts.FailOn)
+	if len(rp.Checks) > 0 || len(rp.Disable) > 0 {
+		if opts.Skip, err = skippedChecks(opts, rp.Checks, rp.Disable); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// readUpload returns the document a request carries and its file name,
+// if it was uploaded as a file
+func readUpload(r *http.Request) ([]byte, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		content, err := io.ReadAll(r.Body)
+		return content, "", err
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, "", errors.New("no file in the upload")
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		content, err := io.ReadAll(part)
+		return content, part.FileName(), err
+	}
+}
+
+// serveIssue is one issue in a JSON response
+type serveIssue struct {
+	Line     int           `json:"line"`
+	Column   int           `json:"column"`
+	Offset   int           `json:"offset"`
+	RuleID   string        `json:"ruleId,omitempty"`
+	Type     string        `json:"type"`
+	Message  string        `json:"message"`
+	Content  string        `json:"content,omitempty"`
+	Severity string        `json:"severity"`
+	Fix      *SuggestedFix `json:"fix,omitempty"`
+}
+
+// serveResult is the JSON response to a validation request. Valid is
+// false when an issue is at the failing severity, like exit status 1.
+type serveResult struct {
+	File     string       `json:"file"`
+	Valid    bool         `json:"valid"`
+	Errors   int          `json:"errors"`
+	Warnings int          `json:"warnings"`
+	Issues   []serveIssue `json:"issues"`
+}
+
+func jsonReport(name string, ix *lineIndex, errs []ValidationError, failOn string) serveResult {
+	warnings := countWarnings(errs)
+	res := serveResult{
+		File:     name,
+		Valid:    countFailing(errs, failOn) == 0,
+		Errors:   len(errs) - warnings,
+		Warnings: warnings,
+		Issues:   []serveIssue{},
+	}
+	for _, e := range errs {
+		res.Issues = append(res.Issues, serveIssue{
+			Line:     e.LineNumber,
+			Column:   errorColumn(ix, e, false),
+			Offset:   e.Offset,
+			RuleID:   e.ruleID(),
+			Type:     e.ErrorType,
+			Message:  e.Message,
+			Content:  e.Content,
+			Severity: cmp.Or(e.Severity, severityError),
+			Fix:      e.Fix,
+		})
+	}
+	return res
+}
+
+// sarifReport returns errs as a SARIF 2.1.0 log, the format code scanning
+// tools read, with the rules that reported them
+func sarifReport(name string, ix *lineIndex, errs []ValidationError) map[string]any {
+	v, _, _ := buildInfo()
+	var rules []map[string]any
+	seen := make(map[string]bool)
+	results := []map[string]any{}
+	for _, e := range errs {
+		id := cmp.Or(e.ruleID(), e.ErrorType)
+		if !seen[id] {
+			seen[id] = true
+			rule := map[string]any{"id": id, "name": e.ErrorType}
+			if info := ruleByID[id]; info != nil && info.summary != "" {
+				rule["shortDescription"] = map[string]string{"text": info.summary}
+			}
+			rules = append(rules, rule)
+		}
+		region := map[string]int{"startLine": max(e.LineNumber, 1)}
+		if col := errorColumn(ix, e, false); col > 0 {
+			region["startColumn"] = col
+		}
+		results = append(results, map[string]any{
+			"ruleId":  id,
+			"level":   cmp.Or(e.Severity, severityError),
+			"message": map[string]string{"text": e.Message},
+			"locations": []any{map[string]any{
+				"physicalLocation": map[string]any{
+					"artifactLocation": map[string]string{"uri": name},
+					"region":           region,
+				},
+			}},
+		})
+	}
+	driver := map[string]any{"name": "xml_validator", "version": cmp.Or(v, "dev")}
+	if len(rules) > 0 {
+		driver["rules"] = rules
+	}
+	return map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []any{map[string]any{
+			"tool":    map[string]any{"driver": driver},
+			"results": results,
+		}},
+	}
+}
+
+func writeServeJSON(w http.ResponseWriter, contentType string, v any) {
+	w.Header().Set("Content-Type", contentType)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false) // Messages quote markup
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func serveError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// sitemapNamespace is the sitemaps.org protocol namespace
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Protocol limits for a single sitemap or sitemap index file
+const (
+	sitemapMaxURLs  = 50000
+	sitemapMaxBytes = 50 * 1024 * 1024
+	sitemapMaxLoc   = 2048
+)
+
+// Values allowed in <changefreq>
+var sitemapChangeFreqs = map[string]bool{
+	"always": true, "hourly": true, "daily": true, "weekly": true,
+	"monthly": true, "yearly": true, "never": true,
+}
+
+// W3C Datetime (https://www.w3.org/TR/NOTE-datetime) layouts, from year
+// only up to full precision with a time zone
+var w3cDatetimeLayouts = []string{
+	"2006",
+	"2006-01",
+	"2006-01-02",
+	"2006-01-02T15:04Z07:00",
+	time.RFC3339,
+	time.RFC3339Nano,
+}
+
+// parseW3CDatetime parses a W3C Datetime value as used by <lastmod>
+func parseW3CDatetime(value string) (time.Time, bool) {
+	for _, layout := range w3cDatetimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// validateSitemap checks a sitemaps.org <urlset> or <sitemapindex>
+func validateSitemap(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	root, err := parseTree(content)
+	if err != nil || root == nil {
+		return errors // Reported by validateBasicXML
+	}
+
+	var entryName string
+	switch root.Name.Local {
+	case "urlset":
+		entryName = "url"
+	case "sitemapindex":
+		entryName = "sitemap"
+	default:
+		return append(errors, nodeError(ix, root, "Sitemap structure",
+			fmt.Sprintf("Root element is <%s>, expected <urlset> or <sitemapindex>", root.Name.Local)))
+	}
+	if root.Name.Space != sitemapNamespace {
+		errors = append(errors, nodeError(ix, root, "Sitemap structure",
+			fmt.Sprintf("<%s> must declare xmlns=%q", root.Name.Local, sitemapNamespace)))
+	}
+
+	if len(content) > sitemapMaxBytes {
+		errors = append(errors, nodeError(ix, root, "Sitemap limit",
+			fmt.Sprintf("File is %d bytes; sitemaps may be at most 50 MB uncompressed", len(content))))
+	}
+
+	entries := root.childrenNamed("", entryName)
+	if len(entries) > sitemapMaxURLs {
+		errors = append(errors, nodeError(ix, entries[sitemapMaxURLs], "Sitemap limit",
+			fmt.Sprintf("Found %d <%s> entries; a single file may list at most %d", len(entries), entryName, sitemapMaxURLs)))
+	}
+
+	for _, entry := range entries {
+		errors = append(errors, checkSitemapEntry(ix, entry)...)
+		if opts.MaxErrors > 0 && len(errors) >= opts.MaxErrors {
+			break
+		}
+	}
+
+	return errors
+}
+
+// checkSitemapEntry validates the children of a <url> or <sitemap>
+func checkSitemapEntry(ix *lineIndex, entry *xmlNode) []ValidationError {
+	var errors []ValidationError
+
+	loc := entry.child("", "loc")
+	if loc == nil {
+		errors = append(errors, nodeError(ix, entry, "Sitemap missing element",
+			fmt.Sprintf("<%s> is missing required <loc>", entry.Name.Local)))
+	} else if problem := checkSitemapLoc(loc.trimmedText()); problem != "" {
+		errors = append(errors, nodeError(ix, loc, "Sitemap invalid URL", problem))
+	}
+
+	if lastmod := entry.child("", "lastmod"); lastmod != nil {
+		if dateErr := checkDateNode(ix, lastmod, "lastmod", "w3c"); dateErr != nil {
+			errors = append(errors, *dateErr)
+		}
+	}
+
+	if entry.Name.Local != "url" {
+		return errors
+	}
+
+	if freq := entry.child("", "changefreq"); freq != nil && !sitemapChangeFreqs[freq.trimmedText()] {
+		errors = append(errors, nodeError(ix, freq, "Sitemap invalid value",
+			fmt.Sprintf("<changefreq> %q must be one of always, hourly, daily, weekly, monthly, yearly, never", freq.trimmedText())))
+	}
+
+	if priority := entry.child("", "priority"); priority != nil {
+		if p, err := strconv.ParseFloat(priority.trimmedText(), 64); err != nil || p < 0 || p > 1 {
+			errors = append(errors, nodeError(ix, priority, "Sitemap invalid value",
+				fmt.Sprintf("<priority> %q must be a number between 0.0 and 1.0", priority.trimmedText())))
+		}
+	}
+
+	return errors
+}
+
+// checkSitemapLoc returns a description of what is wrong with a <loc>
+// value, or "" if it is a usable absolute URL
+func checkSitemapLoc(loc string) string {
+	if len(loc) > sitemapMaxLoc {
+		return fmt.Sprintf("<loc> is %d characters; the limit is %d", len(loc), sitemapMaxLoc)
+	}
+	u, err := url.Parse(loc)
+	if err != nil {
+		return fmt.Sprintf("<loc> %q is not a valid URL: %v", loc, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Sprintf("<loc> %q must be an absolute http or https URL", loc)
+	}
+	return ""
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// reValidSlug matches what WordPress's sanitize_title() produces: lowercase
+// ASCII letters, digits, hyphens and underscores, with anything else
+// percent-encoded as lowercase hex octets
+var reValidSlug = regexp.MustCompile(`^([a-z0-9_-]|%[0-9a-f]{2})+$`)
+
+// Maximum slug length; longer values are truncated by the 200-character
+// slug columns of wp_posts and wp_terms
+const wxrMaxSlug = 200
+
+// wxrSlugSource describes a channel-level term definition and which of its
+// children holds the slug
+type wxrSlugSource struct {
+	element  string
+	slug     string
+	taxonomy string // Child naming the taxonomy, or "" for a fixed one
+}
+
+// Term definitions whose slugs must be valid and unique per taxonomy
+var wxrSlugSources = []wxrSlugSource{
+	{"category", "category_nicename", ""},
+	{"tag", "tag_slug", ""},
+	{"term", "term_slug", "term_taxonomy"},
+}
+
+// checkWXRSlugs validates category, tag and term slugs and <wp:post_name>
+// values against WordPress slug rules, and reports term slugs defined twice
+// in one taxonomy, which the importer merges into a single term
+func checkWXRSlugs(ix *lineIndex, channel *xmlNode, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+	seen := make(map[string]*xmlNode)
+
+	for _, c := range channel.Children {
+		if !isWPNamespace(c.Name.Space) {
+			continue
+		}
+		for _, source := range wxrSlugSources {
+			if c.Name.Local != source.element {
+				continue
+			}
+			slug := wpChild(c, source.slug)
+			if slug == nil {
+				continue
+			}
+			if problem := checkSlug(slug.trimmedText()); problem != "" {
+				errors = append(errors, nodeError(ix, slug, "WXR invalid slug",
+					fmt.Sprintf("<wp:%s> %s", source.slug, problem)))
+			}
+
+			taxonomy := source.element
+			if source.taxonomy != "" {
+				if t := wpChild(c, source.taxonomy); t != nil {
+					taxonomy = t.trimmedText()
+				}
+			} else if taxonomy == "tag" {
+				taxonomy = "post_tag"
+			}
+			key := taxonomy + "\x00" + slug.trimmedText()
+			if first, ok := seen[key]; ok {
+				errors = append(errors, nodeError(ix, slug, "WXR duplicate slug",
+					fmt.Sprintf("%s slug %q is already defined on line %d; the importer will merge both into one term",
+						taxonomy, slug.trimmedText(), lineOf(ix, first.Offset))))
+			} else {
+				seen[key] = slug
+			}
+		}
+	}
+
+	for _, item := range channel.childrenNamed("", "item") {
+		// Drafts and auto-drafts are exported without a slug
+		if name := wpChild(item, "post_name"); name != nil && name.trimmedText() != "" {
+			if problem := checkSlug(name.trimmedText()); problem != "" {
+				errors = append(errors, nodeError(ix, name, "WXR invalid slug",
+					fmt.Sprintf("<wp:post_name> of item %s %s", describeItem(item), problem)))
+			}
+		}
+	}
+
+	return errors
+}
+
+// checkSlug returns a description of what is wrong with a slug, or ""
+func checkSlug(slug string) string {
+	switch {
+	case slug == "":
+		return "is empty"
+	case len(slug) > wxrMaxSlug:
+		return fmt.Sprintf("is %d characters; WordPress truncates slugs to %d", len(slug), wxrMaxSlug)
+	case !reValidSlug.MatchString(slug):
+		return fmt.Sprintf("%q is not a valid slug (lowercase letters, digits, - and _ only); WordPress would use %q",
+			slug, sanitizeSlug(slug))
+	}
+	return ""
+}
+
+// sanitizeSlug approximates sanitize_title_with_dashes(): lowercase,
+// whitespace and dots become hyphens, other ASCII punctuation is dropped
+// and non-ASCII bytes are percent-encoded
+func sanitizeSlug(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(strings.ToLower(strings.TrimSpace(s))) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '_', c == '-':
+			b.WriteByte(c)
+		case c == ' ', c == '\t', c == '.', c == '/':
+			b.WriteByte('-')
+		case c >= 0x80:
+			fmt.Fprintf(&b, "%%%02x", c)
+		}
+	}
+	slug := b.String()
+	for strings.Contains(slug, "--") {
+		slug = strings.ReplaceAll(slug, "--", "-")
+	}
+	return strings.Trim(slug, "-")
+}
+
+// checkWXRCollisions lists items of the same post type that share a slug
+// or a title. The importer renames later slugs (hello-world-2) and skips a
+// post whose title and date match one it has already imported.
+func checkWXRCollisions(ix *lineIndex, channel *xmlNode, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	type group struct {
+		key   string
+		items []*xmlNode
+	}
+	var slugs, titles []*group
+	slugIndex := make(map[string]*group)
+	titleIndex := make(map[string]*group)
+	add := func(groups *[]*group, index map[string]*group, key string, item *xmlNode) {
+		g, ok := index[key]
+		if !ok {
+			g = &group{key: key}
+			index[key] = g
+			*groups = append(*groups, g)
+		}
+		g.items = append(g.items, item)
+	}
+
+	for _, item := range channel.childrenNamed("", "item") {
+		postType := "post"
+		if t := wpChild(item, "post_type"); t != nil && t.trimmedText() != "" {
+			postType = t.trimmedText()
+		}
+		if postType == "nav_menu_item" {
+			continue // Menu items are identified by ID, not slug or title
+		}
+
+		// Hierarchical posts only collide with siblings under the same parent
+		if name := wpChild(item, "post_name"); name != nil && name.trimmedText() != "" {
+			parent := "0"
+			if p := wpChild(item, "post_parent"); p != nil && p.trimmedText() != "" {
+				parent = p.trimmedText()
+			}
+			add(&slugs, slugIndex, postType+"\x00"+parent+"\x00"+name.trimmedText(), item)
+		}
+		if title := item.child("", "title"); title != nil && title.trimmedText() != "" {
+			add(&titles, titleIndex, postType+"\x00"+title.trimmedText(), item)
+		}
+	}
+
+	describe := func(g *group) (postType, value, lines string) {
+		parts := strings.Split(g.key, "\x00")
+		var numbers []string
+		for _, item := range g.items {
+			numbers = append(numbers, fmt.Sprint(lineOf(ix, item.Offset)))
+		}
+		return parts[0], parts[len(parts)-1], strings.Join(numbers, ", ")
+	}
+
+	for _, g := range slugs {
+		if len(g.items) < 2 {
+			continue
+		}
+		postType, slug, lines := describe(g)
+		errors = append(errors, nodeError(ix, g.items[1], "WXR slug collision",
+			fmt.Sprintf("%d %s items share the slug %q (lines %s); the importer will rename all but the first",
+				len(g.items), postType, slug, lines)))
+	}
+
+	for _, g := range titles {
+		if len(g.items) < 2 {
+			continue
+		}
+		postType, title, lines := describe(g)
+		message := fmt.Sprintf("%d %s items share the title %q (lines %s)", len(g.items), postType, title, lines)
+		if sameDate(g.items) {
+			message += "; some also share a date, and the importer skips a post whose title and date match one it already imported"
+		}
+		errors = append(errors, nodeError(ix, g.items[1], "WXR title collision", message))
+	}
+
+	return errors
+}
+
+// sameDate reports whether at least two items have the same <wp:post_date>
+func sameDate(items []*xmlNode) bool {
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if d := wpChild(item, "post_date"); d != nil && d.trimmedText() != "" {
+			if seen[d.trimmedText()] {
+				return true
+			}
+			seen[d.trimmedText()] = true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SplitOptions controls how the split subcommand chunks a WXR export
+type SplitOptions struct {
+	MaxSize   int64  // Upper bound for each output file in bytes
+	OutputDir string // Directory the chunks are written to
+}
+
+// wxrLayout records where the items of a WXR export live so the file can
+// be reassembled from byte ranges without re-serializing anything
+type wxrLayout struct {
+	header []byte   // Everything before the first item (channel metadata, authors, terms)
+	items  [][]byte // Each item, preceded by whatever separated it from the previous one
+	footer []byte   // Everything after the last item
+	ranges [][2]int // Byte range of each <item> element in the document
+
+	rssTag      [2]int          // Byte range of the <rss> start tag within header
+	namespaces  []xml.Attr      // xmlns declarations on <rss>, in document order
+	definitions []wxrDefinition // Author and term definitions in <channel>
+}
+
+// wxrDefinition is a channel-level <wp:author>, <wp:category>, <wp:tag> or
+// <wp:term> block together with the key WordPress identifies it by
+type wxrDefinition struct {
+	key string
+	raw []byte
+}
+
+// wxrDefinitionElements maps the definition element names to the child
+// elements that make up their identity
+var wxrDefinitionElements = map[string][]string{
+	"author":   {"author_login"},
+	"category": {"category_nicename"},
+	"tag":      {"tag_slug"},
+	"term":     {"term_taxonomy", "term_slug"},
+}
+
+// runSplit implements the "split" subcommand
+func runSplit(args []string) {
+	opts := SplitOptions{}
+	maxSize := "2MB"
+	fs := flag.NewFlagSet("split", flag.ContinueOnError)
+	fs.StringVar(&maxSize, "max-size", maxSize, "Maximum size of each chunk (e.g. 500KB, 2MB, 1GB)")
+	fs.StringVar(&opts.OutputDir, "output-dir", ".", "Directory to write the chunks to")
+	addFetchFlags(fs)
+	parseFlags(fs, args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: xml_validator split [--max-size=SIZE] [--output-dir=DIR] <wxr-file-or-URL>")
+		os.Exit(exitUsage)
+	}
+
+	size, err := parseByteSize(maxSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid --max-size: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	opts.MaxSize = size
+
+	input := fs.Arg(0)
+	content, err := readFileContent(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading file: %v\n", err)
+		os.Exit(inputExitCode(err))
+	}
+
+	layout, err := parseWXRLayout(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Cannot split document: %v\n", err)
+		os.Exit(exitValidation)
+	}
+
+	chunks := layout.chunk(opts.MaxSize)
+	base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	for i, chunk := range chunks {
+		if errs := validateBasicXML(chunk, indexLines(chunk)); len(errs) > 0 {
+			fmt.Fprintf(os.Stderr, "❌ Chunk %d is not well-formed: %s\n", i+1, errs[0].Message)
+			os.Exit(exitValidation)
+		}
+		if int64(len(chunk)) > opts.MaxSize {
+			fmt.Fprintf(os.Stderr, "%s chunk %d holds a single item larger than --max-size\n", highlightColor("Warning:"), i+1)
+		}
+
+		name := filepath.Join(opts.OutputDir, fmt.Sprintf("%s-%03d.xml", base, i+1))
+		if err := os.WriteFile(name, chunk, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error writing output: %v\n", err)
+			os.Exit(exitIO)
+		}
+		fmt.Printf("Wrote %s (%d bytes)\n", name, len(chunk))
+	}
+
+	fmt.Printf("%s Split %d items into %d files\n", successColor("✅"), len(layout.items), len(chunks))
+}
+
+// parseWXRLayout locates the <item> elements directly inside <channel>
+func parseWXRLayout(content []byte) (*wxrLayout, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	var path []string
+	var ranges [][2]int
+	itemStart, defStart := -1, -1
+	layout := &wxrLayout{}
+
+	for {
+		start := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			if len(path) == 1 && t.Name.Local == "rss" {
+				layout.rssTag = [2]int{start, int(decoder.InputOffset())}
+				for _, attr := range t.Attr {
+					if attr.Name.Space == "xmlns" {
+						layout.namespaces = append(layout.namespaces, attr)
+					}
+				}
+			}
+			if len(path) == 3 && path[0] == "rss" && path[1] == "channel" {
+				if t.Name.Local == "item" {
+					itemStart = start
+				} else if _, ok := wxrDefinitionElements[t.Name.Local]; ok && isWPNamespace(t.Name.Space) {
+					defStart = start
+				}
+			}
+		case xml.EndElement:
+			if len(path) == 3 && itemStart >= 0 {
+				ranges = append(ranges, [2]int{itemStart, int(decoder.InputOffset())})
+				itemStart = -1
+			}
+			if len(path) == 3 && defStart >= 0 {
+				raw := content[defStart:decoder.InputOffset()]
+				layout.definitions = append(layout.definitions, wxrDefinition{
+					key: definitionKey(t.Name.Local, raw),
+					raw: raw,
+				})
+				defStart = -1
+			}
+			path = path[:len(path)-1]
+		}
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no <item> elements found in <rss><channel>")
+	}
+
+	layout.ranges = ranges
+	layout.header = content[:ranges[0][0]]
+	layout.footer = content[ranges[len(ranges)-1][1]:]
+	prevEnd := ranges[0][0]
+	for _, r := range ranges {
+		layout.items = append(layout.items, content[prevEnd:r[1]])
+		prevEnd = r[1]
+	}
+	return layout, nil
+}
+
+// definitionKey identifies a definition block by its element name and the
+// values of its identity children, e.g. "author:admin"
+func definitionKey(element string, raw []byte) string {
+	key := element
+	// The snippet's wp: prefix is undeclared on its own, which the
+	// tokenizer tolerates; children are matched by local name only
+	node, err := parseTree(raw)
+	if err != nil || node == nil {
+		return key + ":" + string(raw)
+	}
+	for _, name := range wxrDefinitionElements[element] {
+		if c := node.child("", name); c != nil {
+			key += ":" + c.trimmedText()
+		}
+	}
+	return key
+}
+
+// chunk packs items into documents of at most maxSize bytes, each with its
+// own copy of the header and footer. An item too large to fit on its own
+// still gets a document rather than being dropped.
+func (l *wxrLayout) chunk(maxSize int64) [][]byte {
+	var chunks [][]byte
+	overhead := int64(len(l.header) + len(l.footer))
+
+	var current bytes.Buffer
+	count := 0
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		current.Write(l.footer)
+		chunks = append(chunks, bytes.Clone(current.Bytes()))
+		current.Reset()
+		count = 0
+	}
+
+	for _, item := range l.items {
+		if count > 0 && int64(current.Len()+len(item))+int64(len(l.footer)) > maxSize {
+			flush()
+		}
+		if count == 0 {
+			current.Grow(int(overhead) + len(item))
+			current.Write(l.header)
+		}
+		current.Write(item)
+		count++
+	}
+	flush()
+
+	return chunks
+}
+
+// parseByteSize parses sizes such as "512", "500KB", "2MB" or "1.5GB"
+// using binary (1024-based) multiples
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		factor int64
+	}{
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.factor
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("%q is not a positive size", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"text/tabwriter"
+)
+
+// documentStats summarizes the structure of a document
+type documentStats struct {
+	size       int
+	lines      int
+	elements   int
+	attributes int
+	maxDepth   int
+	cdata      int
+	comments   int
+	pis        int
+	textBytes  int
+	names      map[string]int // Elements per raw name, e.g. "wp:post_id"
+	namespaces map[string]bool
+}
+
+// runStats implements the "stats" subcommand
+func runStats(args []string) {
+	top := 10
+	sfs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	sfs.IntVar(&top, "top", top, "Number of most frequent element names to list")
+	addFetchFlags(sfs)
+	parseFlags(sfs, args)
+
+	if sfs.NArg() < 1 {
+		fmt.Println("Usage: xml_validator stats [--top=N] <xml-file-or-URL>")
+		os.Exit(exitUsage)
+	}
+
+	content, err := readFileContent(sfs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading file: %v\n", err)
+		os.Exit(inputExitCode(err))
+	}
+
+	stats, err := collectStats(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Document is not well-formed (%v); run validate for details\n", err)
+		os.Exit(exitValidation)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Size:\t%d bytes\n", stats.size)
+	fmt.Fprintf(w, "Lines:\t%d\n", stats.lines)
+	fmt.Fprintf(w, "Elements:\t%d (%d distinct names)\n", stats.elements, len(stats.names))
+	fmt.Fprintf(w, "Attributes:\t%d\n", stats.attributes)
+	fmt.Fprintf(w, "Maximum depth:\t%d\n", stats.maxDepth)
+	fmt.Fprintf(w, "Text:\t%d bytes\n", stats.textBytes)
+	fmt.Fprintf(w, "CDATA sections:\t%d\n", stats.cdata)
+	fmt.Fprintf(w, "Comments:\t%d\n", stats.comments)
+	fmt.Fprintf(w, "Processing instructions:\t%d\n", stats.pis)
+	fmt.Fprintf(w, "Namespace prefixes:\t%d\n", len(stats.namespaces))
+	w.Flush()
+
+	if top <= 0 || len(stats.names) == 0 {
+		return
+	}
+	names := make([]string, 0, len(stats.names))
+	for name := range stats.names {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		if c := cmp.Compare(stats.names[b], stats.names[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	fmt.Printf("\n%s\n", headerColor("Most frequent elements:"))
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, name := range names[:min(top, len(names))] {
+		fmt.Fprintf(w, "  %s\t%d\n", name, stats.names[name])
+	}
+	w.Flush()
+}
+
+// collectStats walks the document's raw tokens, so names keep the prefixes
+// they are written with
+func collectStats(content []byte) (documentStats, error) {
+	stats := documentStats{
+		size:       len(content),
+		lines:      indexLines(content).lines(),
+		names:      make(map[string]int),
+		namespaces: make(map[string]bool),
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	depth := 0
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			stats.maxDepth = max(stats.maxDepth, depth)
+			stats.elements++
+			stats.attributes += len(t.Attr)
+			stats.names[rawName(t.Name)]++
+			if t.Name.Space != "" {
+				stats.namespaces[t.Name.Space] = true
+			}
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			stats.textBytes += len(bytes.TrimSpace(t))
+			if bytes.HasPrefix(content[start:], cdataOpen) {
+				stats.cdata++
+			}
+		case xml.Comment:
+			stats.comments++
+		case xml.ProcInst:
+			stats.pis++
+		}
+	}
+	return stats, nil
+}
+
+// rawName returns a name as written, with its prefix
+func rawName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
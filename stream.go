@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"strings"
+	"time"
+)
+
+// Validating a document in memory takes about this many times its size:
+// the content, its line index and the parsed trees of the profile checks
+const memoryFactor = 3
+
+// openInput opens a local file or starts downloading a URL, returning the
+// body, its size in bytes, or -1 when the server does not say, and the
+// charset of a download's Content-Type, if any
+func openInput(filepath string) (io.ReadCloser, int64, string, error) {
+	if isRemote(filepath) {
+		resp, err := fetchURL(filepath)
+		if err != nil {
+			return nil, 0, "", &networkError{fmt.Errorf("failed to download file: %v", err)}
+		}
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
+			if location := resp.Header.Get("Location"); location != "" {
+				return nil, 0, "", &networkError{fmt.Errorf("HTTP error: %s, redirecting to %s", resp.Status, location)}
+			}
+			return nil, 0, "", &networkError{fmt.Errorf("HTTP error: %s", resp.Status)}
+		}
+		if n := redirects(resp); n > 0 {
+			fmt.Fprintln(os.Stderr, infoColor(fmt.Sprintf("Redirected %d time(s) to %s", n, resp.Request.URL)))
+		}
+		var charset string
+		if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+			charset = params["charset"]
+		}
+		return resp.Body, resp.ContentLength, charset, nil
+	}
+
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, "", err
+	}
+	return f, info.Size(), "", nil
+}
+
+// loadInput reads the whole input when validating it fits in maxMemory
+// bytes (or maxMemory is 0) and stream is false. Otherwise it returns the
+// open input for validateStream, together with its size. A download read
+// whole is converted to UTF-8 from the charset of its Content-Type. The
+// bytes read are copied to save, if not nil, as they arrive.
+func loadInput(filepath string, maxMemory int64, stream bool, save io.Writer) ([]byte, io.ReadCloser, int64, error) {
+	if isRemote(filepath) {
+		fmt.Fprintln(os.Stderr, infoColor("Downloading from URL..."))
+	} else {
+		fmt.Fprintln(os.Stderr, infoColor("Reading local file..."))
+	}
+	r, size, charset, err := openInput(filepath)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if save != nil {
+		r = readCloser{io.TeeReader(r, save), r}
+	}
+	if stream || maxMemory > 0 && (size < 0 || size*memoryFactor > maxMemory) {
+		return nil, r, size, nil
+	}
+	defer r.Close()
+
+	var bar *progressBar
+	if isRemote(filepath) {
+		bar = newProgressBar("Downloading", size, 1)
+		defer bar.finish()
+	}
+	content, err := io.ReadAll(&progressReader{r: r, bar: bar})
+	if err != nil && isRemote(filepath) {
+		return content, nil, size, &networkError{err}
+	}
+	if err == nil {
+		content, err = applyCharset(content, charset, filepath)
+	}
+	return content, nil, size, err
+}
+
+// readCloser reads from one reader and closes another, e.g. the input
+// under a TeeReader
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// validateStream checks a document too large to hold in memory. The
+// well-formedness parse and the line rules read it in one pass, through a
+// pipe, so memory use does not depend on the input size. Document rules
+// need the whole content and are skipped.
+func validateStream(r io.Reader, opts ValidationOptions) []ValidationError {
+	var rules []rule
+	for _, rl := range validationRules(opts) {
+		if rl.line != nil {
+			rules = append(rules, rl)
+			fmt.Println(infoColor(rl.progress))
+		}
+	}
+
+	pr, pw := io.Pipe()
+	lineErrors := make(chan [][]ValidationError)
+	go func() {
+		lineErrors <- scanLineStream(pr, rules, opts)
+	}()
+
+	tee := io.TeeReader(r, pw)
+	var basicErrors []ValidationError
+	if !opts.Skip["basic"] {
+		start := time.Now()
+		basicErrors = decodeStream(tee)
+		opts.Timings.since("basic", start)
+	}
+	// Feed the rest of the document to the line rules
+	_, err := io.Copy(io.Discard, tee)
+	pw.CloseWithError(err)
+
+	allErrors := basicErrors
+	for _, errs := range <-lineErrors {
+		allErrors = append(allErrors, errs...)
+	}
+	sortByPosition(allErrors)
+	opts.Timings.validated()
+	if opts.MaxErrors > 0 && len(allErrors) > opts.MaxErrors {
+		return allErrors[:opts.MaxErrors]
+	}
+	return allErrors
+}
+
+// Longest part of a line fillStreamContext keeps, so a document on one
+// huge line is not read into memory after all
+const maxContextLine = 64 << 10
+
+// fillStreamContext sets the Line of the errors validateStream could not
+// give one, such as syntax errors, by reading their lines from the file at
+// path: the streamed file itself, or a copy of a streamed download. The
+// column of a syntax error is found from its offset too.
+func fillStreamContext(path string, errs []ValidationError) {
+	wanted := make(map[int]bool)
+	for _, e := range errs {
+		if e.Line == "" && e.LineNumber > 0 {
+			wanted[e.LineNumber] = true
+		}
+	}
+	if len(wanted) == 0 {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	type lineInfo struct {
+		text       string
+		start, end int // Byte offsets of the line in the file
+	}
+	lines := make(map[int]lineInfo, len(wanted))
+	br := bufio.NewReaderSize(f, 64<<10)
+	var line []byte
+	for lineNum, start, offset := 1, 0, 0; len(lines) < len(wanted); {
+		chunk, err := br.ReadSlice('\n')
+		offset += len(chunk)
+		if wanted[lineNum] && len(line) < maxContextLine {
+			line = append(line, chunk[:min(len(chunk), maxContextLine-len(line))]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if wanted[lineNum] {
+			lines[lineNum] = lineInfo{strings.TrimRight(string(line), "\r\n"), start, offset}
+			line = line[:0]
+		}
+		if err != nil {
+			break
+		}
+		lineNum, start = lineNum+1, offset
+	}
+	for i := range errs {
+		e := &errs[i]
+		info, ok := lines[e.LineNumber]
+		if e.Line != "" || !ok {
+			continue
+		}
+		e.Line = info.text
+		if e.Column == 0 && e.Offset >= info.start && e.Offset < info.end {
+			e.Column = e.Offset - info.start + 1
+		}
+	}
+}
+
+// decodeStream runs the XML decoder over r and returns the first syntax
+// error. Without the content only the line of the error is known.
+func decodeStream(r io.Reader) []ValidationError {
+	decoder := xml.NewDecoder(r)
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			line := 0
+			if syntaxErr, ok := err.(*xml.SyntaxError); ok {
+				line = syntaxErr.Line
+			}
+			return []ValidationError{{
+				LineNumber: line,
+				Offset:     int(decoder.InputOffset()),
+				ErrorType:  "Basic XML Syntax Error",
+				Message:    err.Error(),
+			}}
+		}
+	}
+}
+
+// scanLineStream is scanLines over a reader: it feeds each line to every
+// rule, holding only the current line in memory
+func scanLineStream(r io.Reader, rules []rule, opts ValidationOptions) [][]ValidationError {
+	results := make([][]ValidationError, len(rules))
+	br := bufio.NewReaderSize(r, 64<<10)
+	var long []byte // A line longer than the reader's buffer
+	offset := 0
+	var elapsed []time.Duration // Time in each rule, with --timings
+	if opts.Timings != nil {
+		elapsed = make([]time.Duration, len(rules))
+		defer func() {
+			for i, d := range elapsed {
+				opts.Timings.add(rules[i].name, d)
+			}
+		}()
+	}
+
+	for lineNum := 1; ; {
+		chunk, err := br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			long = append(long, chunk...)
+			continue
+		}
+		line := chunk
+		if long != nil {
+			line = append(long, chunk...)
+			long = nil
+		}
+
+		content := line
+		if len(content) > 0 && content[len(content)-1] == '\n' {
+			content = content[:len(content)-1]
+		}
+		if err == nil || len(line) > 0 || lineNum == 1 {
+			for i, rl := range rules {
+				if opts.MaxErrors > 0 && len(results[i]) >= opts.MaxErrors {
+					continue
+				}
+				var start time.Time
+				if elapsed != nil {
+					start = time.Now()
+				}
+				errs := rl.line(lineNum, offset, content)
+				if elapsed != nil {
+					elapsed[i] += time.Since(start)
+				}
+				for j := range errs {
+					errs[j].Offset = offset + max(errs[j].Column, 1) - 1
+				}
+				results[i] = append(results[i], errs...)
+			}
+		}
+		offset += len(line)
+		lineNum++
+
+		if err != nil {
+			// Let the decoder side finish writing instead of blocking it
+			io.Copy(io.Discard, br)
+			if err != io.EOF {
+				// The document is cut short, so what is open is no error
+				return results
+			}
+			for i, rl := range rules {
+				if rl.end != nil && (opts.MaxErrors == 0 || len(results[i]) < opts.MaxErrors) {
+					results[i] = append(results[i], rl.end()...)
+				}
+			}
+			return results
+		}
+	}
+}
@@ -0,0 +1,719 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// svgCategory groups SVG elements by where they may appear
+type svgCategory int
+
+const (
+	svgGeneral      svgCategory = iota // Graphics, containers and everything else
+	svgDescriptive                     // desc, title, metadata
+	svgAnimation                       // animate, set, animateMotion...
+	svgTextChild                       // tspan, textPath: only inside text
+	svgStop                            // stop: only inside gradients
+	svgPrimitive                       // fe* filter primitives: only inside filter
+	svgTransferFunc                    // feFuncR/G/B/A: only inside feComponentTransfer
+	svgMergeNode                       // feMergeNode: only inside feMerge
+	svgLightSource                     // feDistantLight, fePointLight, feSpotLight
+	svgMotionPath                      // mpath: only inside animateMotion
+)
+
+// svgAttrGroup is a set of attribute groups an element accepts in
+// addition to its own attributes
+type svgAttrGroup int
+
+const (
+	svgCore svgAttrGroup = 1 << iota
+	svgPresentation
+	svgConditional
+	svgTiming // Animation timing, value and target attributes
+	svgPrimitiveRegion
+	svgAnyAttr // Legacy elements whose attributes are not checked
+)
+
+// svgElementSpec describes one SVG element: its category, the categories
+// of children it may contain (nil means no checking) and its attributes
+type svgElementSpec struct {
+	category svgCategory
+	content  []svgCategory
+	extra    []string // Individual child elements allowed beyond content
+	groups   svgAttrGroup
+	attrs    []string
+}
+
+// Attribute groups shared by many elements
+var (
+	svgCoreAttrs = strings.Fields("id class style lang tabindex autofocus nonce xml:space xml:lang xml:base")
+
+	svgPresentationAttrs = strings.Fields(`alignment-baseline baseline-shift clip clip-path clip-rule color
+		color-interpolation color-interpolation-filters color-profile color-rendering cursor direction display
+		dominant-baseline enable-background fill fill-opacity fill-rule filter flood-color flood-opacity
+		font font-family font-size font-size-adjust font-stretch font-style font-variant font-weight
+		glyph-orientation-horizontal glyph-orientation-vertical image-rendering isolation kerning letter-spacing
+		lighting-color marker-end marker-mid marker-start mask mask-type mix-blend-mode opacity overflow
+		paint-order pointer-events shape-rendering stop-color stop-opacity stroke stroke-dasharray
+		stroke-dashoffset stroke-linecap stroke-linejoin stroke-miterlimit stroke-opacity stroke-width
+		text-anchor text-decoration text-overflow text-rendering transform transform-box transform-origin
+		unicode-bidi vector-effect visibility white-space word-spacing writing-mode`)
+
+	svgConditionalAttrs = strings.Fields("requiredExtensions requiredFeatures systemLanguage")
+
+	svgTimingAttrs = strings.Fields(`attributeName attributeType begin dur end min max restart repeatCount
+		repeatDur fill calcMode values keyTimes keySplines from to by additive accumulate href`)
+
+	svgPrimitiveRegionAttrs = strings.Fields("x y width height result")
+
+	svgXLinkAttrs = strings.Fields("xlink:href xlink:title xlink:show xlink:actuate xlink:type xlink:role xlink:arcrole")
+)
+
+// Child categories allowed by the common content models
+var (
+	svgContainerContent = []svgCategory{svgGeneral, svgDescriptive, svgAnimation}
+	svgLeafContent      = []svgCategory{svgDescriptive, svgAnimation}
+	svgTextContent      = []svgCategory{svgDescriptive, svgAnimation, svgTextChild}
+)
+
+// svgElements lists the SVG 1.1 and 2.0 elements
+var svgElements = map[string]svgElementSpec{
+	// Structure and containers
+	"svg": {svgGeneral, svgContainerContent, nil, svgCore | svgPresentation | svgConditional,
+		strings.Fields("x y width height viewBox preserveAspectRatio zoomAndPan version baseProfile contentScriptType contentStyleType playbackorder timelinebegin")},
+	"g":      {svgGeneral, svgContainerContent, nil, svgCore | svgPresentation | svgConditional, nil},
+	"defs":   {svgGeneral, svgContainerContent, nil, svgCore | svgPresentation, nil},
+	"symbol": {svgGeneral, svgContainerContent, nil, svgCore | svgPresentation, strings.Fields("x y width height viewBox preserveAspectRatio refX refY")},
+	"use":    {svgGeneral, svgLeafContent, nil, svgCore | svgPresentation | svgConditional, strings.Fields("x y width height href")},
+	"a": {svgGeneral, svgContainerContent, nil, svgCore | svgPresentation | svgConditional,
+		strings.Fields("href target download ping rel hreflang type referrerpolicy")},
+	"switch":        {svgGeneral, svgContainerContent, nil, svgCore | svgPresentation | svgConditional, nil},
+	"foreignObject": {svgGeneral, nil, nil, svgCore | svgPresentation | svgConditional, strings.Fields("x y width height")},
+	"view":          {svgGeneral, svgLeafContent, nil, svgCore, strings.Fields("viewBox preserveAspectRatio zoomAndPan viewTarget")},
+
+	// Descriptive
+	"title":    {svgDescriptive, nil, nil, svgCore, nil},
+	"desc":     {svgDescriptive, nil, nil, svgCore, nil},
+	"metadata": {svgDescriptive, nil, nil, svgCore, nil},
+
+	// Shapes and graphics
+	"path":     {svgGeneral, svgLeafContent, nil, svgCore | svgPresentation | svgConditional, strings.Fields("d pathLength")},
+	"rect":     {svgGeneral, svgLeafContent, nil, svgCore | svgPresentation | svgConditional, strings.Fields("x y width height rx ry pathLength")},
+	"circle":   {svgGeneral, svgLeafContent, nil, svgCore | svgPresentation | svgConditional, strings.Fields("cx cy r pathLength")},
+	"ellipse":  {svgGeneral, svgLeafContent, nil, svgCore | svgPresentation | svgConditional, strings.Fields("cx cy rx ry pathLength")},
+	"line":     {svgGeneral, svgLeafContent, nil, svgCore | svgPresentation | svgConditional, strings.Fields("x1 y1 x2 y2 pathLength")},
+	"polyline": {svgGeneral, svgLeafContent, nil, svgCore | svgPresentation | svgConditional, strings.Fields("points pathLength")},
+	"polygon":  {svgGeneral, svgLeafContent, nil, svgCore | svgPresentation | svgConditional, strings.Fields("points pathLength")},
+	"image": {svgGeneral, svgLeafContent, nil, svgCore | svgPresentation | svgConditional,
+		strings.Fields("x y width height href preserveAspectRatio crossorigin decoding")},
+
+	// Text
+	"text":  {svgGeneral, svgTextContent, []string{"a"}, svgCore | svgPresentation | svgConditional, strings.Fields("x y dx dy rotate textLength lengthAdjust")},
+	"tspan": {svgTextChild, svgTextContent, []string{"a"}, svgCore | svgPresentation | svgConditional, strings.Fields("x y dx dy rotate textLength lengthAdjust")},
+	"textPath": {svgTextChild, svgTextContent, []string{"a"}, svgCore | svgPresentation | svgConditional,
+		strings.Fields("href startOffset method spacing side path textLength lengthAdjust")},
+
+	// Paint servers, clipping and masking
+	"linearGradient": {svgGeneral, []svgCategory{svgDescriptive, svgAnimation, svgStop}, nil, svgCore | svgPresentation,
+		strings.Fields("x1 y1 x2 y2 gradientUnits gradientTransform spreadMethod href")},
+	"radialGradient": {svgGeneral, []svgCategory{svgDescriptive, svgAnimation, svgStop}, nil, svgCore | svgPresentation,
+		strings.Fields("cx cy r fx fy fr gradientUnits gradientTransform spreadMethod href")},
+	"stop": {svgStop, []svgCategory{svgAnimation}, nil, svgCore | svgPresentation, strings.Fields("offset")},
+	"pattern": {svgGeneral, svgContainerContent, nil, svgCore | svgPresentation,
+		strings.Fields("x y width height patternUnits patternContentUnits patternTransform viewBox preserveAspectRatio href")},
+	"clipPath": {svgGeneral, svgContainerContent, nil, svgCore | svgPresentation | svgConditional, strings.Fields("clipPathUnits")},
+	"mask":     {svgGeneral, svgContainerContent, nil, svgCore | svgPresentation | svgConditional, strings.Fields("x y width height maskUnits maskContentUnits")},
+	"marker": {svgGeneral, svgContainerContent, nil, svgCore | svgPresentation,
+		strings.Fields("viewBox preserveAspectRatio refX refY markerUnits markerWidth markerHeight orient")},
+
+	// Scripting and styling
+	"script": {svgGeneral, []svgCategory{}, nil, svgCore, strings.Fields("type href crossorigin")},
+	"style":  {svgGeneral, []svgCategory{}, nil, svgCore, strings.Fields("type media title")},
+
+	// Animation
+	"animate":          {svgAnimation, svgLeafContent, nil, svgCore | svgConditional | svgTiming, nil},
+	"animateColor":     {svgAnimation, svgLeafContent, nil, svgCore | svgConditional | svgTiming, nil},
+	"set":              {svgAnimation, svgLeafContent, nil, svgCore | svgConditional | svgTiming, nil},
+	"animateTransform": {svgAnimation, svgLeafContent, nil, svgCore | svgConditional | svgTiming, strings.Fields("type")},
+	"animateMotion": {svgAnimation, []svgCategory{svgDescriptive, svgMotionPath}, nil, svgCore | svgConditional | svgTiming,
+		strings.Fields("path keyPoints rotate origin")},
+	"mpath":   {svgMotionPath, []svgCategory{svgDescriptive}, nil, svgCore, strings.Fields("href")},
+	"discard": {svgAnimation, svgLeafContent, nil, svgCore | svgConditional, strings.Fields("begin href")},
+
+	// Filters
+	"filter": {svgGeneral, []svgCategory{svgDescriptive, svgAnimation, svgPrimitive}, nil, svgCore | svgPresentation,
+		strings.Fields("x y width height filterUnits primitiveUnits filterRes href")},
+	"feBlend":        {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion, strings.Fields("in in2 mode")},
+	"feColorMatrix":  {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion, strings.Fields("in type values")},
+	"feComposite":    {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion, strings.Fields("in in2 operator k1 k2 k3 k4")},
+	"feDropShadow":   {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion, strings.Fields("in dx dy stdDeviation")},
+	"feFlood":        {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion, nil},
+	"feGaussianBlur": {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion, strings.Fields("in stdDeviation edgeMode")},
+	"feImage":        {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion, strings.Fields("href preserveAspectRatio crossorigin")},
+	"feMorphology":   {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion, strings.Fields("in operator radius")},
+	"feOffset":       {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion, strings.Fields("in dx dy")},
+	"feTile":         {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion, strings.Fields("in")},
+	"feTurbulence": {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion,
+		strings.Fields("baseFrequency numOctaves seed stitchTiles type")},
+	"feConvolveMatrix": {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion,
+		strings.Fields("in order kernelMatrix divisor bias targetX targetY edgeMode kernelUnitLength preserveAlpha")},
+	"feDisplacementMap": {svgPrimitive, svgLeafContent, nil, svgCore | svgPresentation | svgPrimitiveRegion,
+		strings.Fields("in in2 scale xChannelSelector yChannelSelector")},
+	"feComponentTransfer": {svgPrimitive, []svgCategory{svgDescriptive, svgTransferFunc}, nil, svgCore | svgPresentation | svgPrimitiveRegion, strings.Fields("in")},
+	"feMerge":             {svgPrimitive, []svgCategory{svgDescriptive, svgMergeNode}, nil, svgCore | svgPresentation | svgPrimitiveRegion, nil},
+	"feDiffuseLighting": {svgPrimitive, []svgCategory{svgDescriptive, svgLightSource}, nil, svgCore | svgPresentation | svgPrimitiveRegion,
+		strings.Fields("in surfaceScale diffuseConstant kernelUnitLength")},
+	"feSpecularLighting": {svgPrimitive, []svgCategory{svgDescriptive, svgLightSource}, nil, svgCore | svgPresentation | svgPrimitiveRegion,
+		strings.Fields("in surfaceScale specularConstant specularExponent kernelUnitLength")},
+	"feFuncR":        {svgTransferFunc, svgLeafContent, nil, svgCore, strings.Fields("type tableValues slope intercept amplitude exponent offset")},
+	"feFuncG":        {svgTransferFunc, svgLeafContent, nil, svgCore, strings.Fields("type tableValues slope intercept amplitude exponent offset")},
+	"feFuncB":        {svgTransferFunc, svgLeafContent, nil, svgCore, strings.Fields("type tableValues slope intercept amplitude exponent offset")},
+	"feFuncA":        {svgTransferFunc, svgLeafContent, nil, svgCore, strings.Fields("type tableValues slope intercept amplitude exponent offset")},
+	"feMergeNode":    {svgMergeNode, svgLeafContent, nil, svgCore, strings.Fields("in")},
+	"feDistantLight": {svgLightSource, svgLeafContent, nil, svgCore, strings.Fields("azimuth elevation")},
+	"fePointLight":   {svgLightSource, svgLeafContent, nil, svgCore, strings.Fields("x y z")},
+	"feSpotLight": {svgLightSource, svgLeafContent, nil, svgCore,
+		strings.Fields("x y z pointsAtX pointsAtY pointsAtZ specularExponent limitingConeAngle")},
+
+	// SVG 1.1 fonts and other legacy elements, removed in SVG 2
+	"cursor":           {svgGeneral, svgLeafContent, nil, svgCore | svgConditional, strings.Fields("x y href")},
+	"color-profile":    {svgGeneral, svgLeafContent, nil, svgCore, strings.Fields("local name rendering-intent href")},
+	"font":             {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"font-face":        {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"font-face-src":    {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"font-face-uri":    {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"font-face-format": {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"font-face-name":   {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"glyph":            {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"missing-glyph":    {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"hkern":            {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"vkern":            {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"glyphRef":         {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"altGlyph":         {svgTextChild, nil, nil, svgAnyAttr, nil},
+	"altGlyphDef":      {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"altGlyphItem":     {svgGeneral, nil, nil, svgAnyAttr, nil},
+	"tref":             {svgTextChild, svgLeafContent, nil, svgAnyAttr, nil},
+}
+
+// svgValueCheck validates the syntax of one attribute's value
+type svgValueCheck struct {
+	errorType string
+	elements  []string // Elements the check applies to; nil means all
+	check     func(value string) (int, string)
+}
+
+// Attribute value grammars checked by validateSVG, by attribute name. The
+// first check whose elements include the element applies. A check returns
+// the byte offset of the first problem in the value and a description of
+// it, or -1.
+var svgValueChecks = map[string][]svgValueCheck{
+	"d":    {{"SVG invalid path data", []string{"path"}, checkPathData}},
+	"path": {{"SVG invalid path data", []string{"animateMotion", "textPath"}, checkPathData}},
+
+	"transform":         {{"SVG invalid transform", nil, checkTransformList}},
+	"viewBox":           {{"SVG invalid viewBox", nil, checkViewBox}},
+	"gradientTransform": {{"SVG invalid transform", nil, checkTransformList}},
+	"patternTransform":  {{"SVG invalid transform", nil, checkTransformList}},
+
+	"x":      svgPositionChecks,
+	"y":      svgPositionChecks,
+	"dx":     {{"SVG invalid length", nil, checkLengthList}},
+	"dy":     {{"SVG invalid length", nil, checkLengthList}},
+	"width":  {{"SVG invalid length", nil, checkLengthOrAuto}},
+	"height": {{"SVG invalid length", nil, checkLengthOrAuto}},
+	"rx":     {{"SVG invalid length", nil, checkLengthOrAuto}},
+	"ry":     {{"SVG invalid length", nil, checkLengthOrAuto}},
+	"r":      {{"SVG invalid length", nil, checkLength}},
+	"cx":     {{"SVG invalid length", nil, checkLength}},
+	"cy":     {{"SVG invalid length", nil, checkLength}},
+	"fx":     {{"SVG invalid length", nil, checkLength}},
+	"fy":     {{"SVG invalid length", nil, checkLength}},
+	"fr":     {{"SVG invalid length", nil, checkLength}},
+	"x1":     {{"SVG invalid length", nil, checkLength}},
+	"y1":     {{"SVG invalid length", nil, checkLength}},
+	"x2":     {{"SVG invalid length", nil, checkLength}},
+	"y2":     {{"SVG invalid length", nil, checkLength}},
+}
+
+// x and y take one length, except on text elements where each value
+// positions the next character
+var svgPositionChecks = []svgValueCheck{
+	{"SVG invalid length", []string{"text", "tspan", "tref", "altGlyph"}, checkLengthList},
+	{"SVG invalid length", nil, checkLength},
+}
+
+// svgAttr is an attribute parsed from an SVG start tag
+type svgAttr struct {
+	name     string
+	value    string
+	offset   int  // Offset of the attribute name in the document
+	valueAt  int  // Offset of the value, excluding any quote
+	quoted   bool // Whether the value was quoted
+	hasValue bool
+}
+
+// svgReporter records an error at a byte offset in the document
+type svgReporter func(offset int, errorType, message, highlight string, fix *SuggestedFix)
+
+// svgOpenElement is an element on the SVG parse stack
+type svgOpenElement struct {
+	name       string
+	offset     int  // Offset of the start tag
+	tagEnd     int  // Offset just past the start tag's ">"
+	hasContent bool // Whether child elements or text followed
+	foreign    bool // Inside content whose children are not checked
+}
+
+// validateSVG finds each <svg> fragment in the document, whether it is
+// part of the XML tree or HTML inside a CDATA section, and checks it
+// against the SVG element table: unknown elements, children an element may
+// not contain, attributes it does not accept, unquoted attribute values
+// and elements that are never closed
+func validateSVG(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+	sections := findCDATASections(content)
+
+	pos := 0
+	for {
+		start := findSVGStart(content, pos)
+		if start < 0 {
+			break
+		}
+		limit := len(content)
+		inCDATA := false
+		for _, s := range sections {
+			if start >= s[0] && start < s[1] {
+				limit, inCDATA = s[1], true
+				break
+			}
+		}
+		if inCDATA && opts.EmbeddedSVG == "off" {
+			pos = limit
+			continue
+		}
+
+		var fragmentErrors []ValidationError
+		fragmentErrors, pos = checkSVGFragment(content, ix, start, limit, inCDATA && opts.EmbeddedSVG == "html", opts)
+		errors = append(errors, fragmentErrors...)
+		if opts.MaxErrors > 0 && len(errors) >= opts.MaxErrors {
+			break
+		}
+	}
+
+	return errors
+}
+
+// findSVGStart returns the offset of the next <svg start tag at or after
+// pos, or -1
+func findSVGStart(content []byte, pos int) int {
+	for pos < len(content) {
+		i := bytes.Index(content[pos:], []byte("<svg"))
+		if i < 0 {
+			return -1
+		}
+		at := pos + i
+		if next := at + len("<svg"); next < len(content) && strings.IndexByte(" \t\r\n/>", content[next]) >= 0 {
+			return at
+		}
+		pos = at + 1
+	}
+	return -1
+}
+
+// checkSVGFragment scans the <svg> element starting at start, stopping at
+// its end tag or at limit, and returns its errors and where scanning ended.
+// With htmlRules the fragment is treated the way an HTML parser reads
+// inline SVG: names are matched case-insensitively and attribute values
+// need no quotes.
+func checkSVGFragment(content []byte, ix *lineIndex, start, limit int, htmlRules bool, opts ValidationOptions) ([]ValidationError, int) {
+	var errors []ValidationError
+	var stack []svgOpenElement
+
+	report := func(offset int, errorType, message, highlight string, fix *SuggestedFix) {
+		line, col, lineContent := findErrorPosition(ix, offset)
+		errors = append(errors, ValidationError{
+			LineNumber: line,
+			Column:     col,
+			Line:       lineContent,
+			ErrorType:  errorType,
+			Message:    message,
+			Content:    highlight,
+			Fix:        fix,
+		})
+	}
+
+	// closeUnclosed reports the elements above depth that were never
+	// closed before the end tag or boundary at offset
+	closeUnclosed := func(depth, offset int) {
+		for i := len(stack) - 1; i >= depth; i-- {
+			open := stack[i]
+			tag := string(content[open.offset:open.tagEnd])
+			spec, known := svgElements[open.name]
+			if !known || open.foreign || !slices.Equal(spec.content, svgLeafContent) {
+				report(open.offset, "SVG unclosed element",
+					fmt.Sprintf("SVG <%s> is never closed", open.name), tag,
+					&SuggestedFix{Start: offset, End: offset, Replacement: "</" + open.name + ">"})
+				continue
+			}
+			// Graphics elements are normally written self-closing
+			if open.hasContent {
+				report(open.offset, "SVG self-closing tag issue",
+					fmt.Sprintf("SVG <%s> has content but is never closed; add </%s>", open.name, open.name), tag,
+					&SuggestedFix{Start: offset, End: offset, Replacement: "</" + open.name + ">"})
+			} else {
+				trimmed := strings.TrimRight(tag[:len(tag)-1], " \t")
+				report(open.offset, "SVG self-closing tag issue",
+					fmt.Sprintf("SVG <%s> tag should be self-closing with />", open.name), tag,
+					&SuggestedFix{Start: open.offset, End: open.tagEnd, Replacement: trimmed + " />"})
+			}
+		}
+		stack = stack[:depth]
+	}
+
+	pos := start
+	for pos < limit {
+		lt := bytes.IndexByte(content[pos:limit], '<')
+		if lt < 0 {
+			if len(stack) > 0 && len(bytes.TrimSpace(content[pos:limit])) > 0 {
+				stack[len(stack)-1].hasContent = true
+			}
+			pos = limit
+			break
+		}
+		if len(stack) > 0 && len(bytes.TrimSpace(content[pos:pos+lt])) > 0 {
+			stack[len(stack)-1].hasContent = true
+		}
+		pos += lt
+		rest := content[pos:limit]
+
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			end := bytes.Index(rest, []byte("-->"))
+			if end < 0 {
+				pos = limit
+			} else {
+				pos += end + len("-->")
+			}
+			continue
+
+		case bytes.HasPrefix(rest, []byte("<![CDATA[")):
+			end := bytes.Index(rest, []byte("]]>"))
+			if end < 0 {
+				pos = limit
+			} else {
+				pos += end + len("]]>")
+			}
+			if len(stack) > 0 {
+				stack[len(stack)-1].hasContent = true
+			}
+			continue
+
+		case bytes.HasPrefix(rest, []byte("<!")), bytes.HasPrefix(rest, []byte("<?")):
+			end := bytes.IndexByte(rest, '>')
+			if end < 0 {
+				pos = limit
+			} else {
+				pos += end + 1
+			}
+			continue
+
+		case bytes.HasPrefix(rest, []byte("</")):
+			end := bytes.IndexByte(rest, '>')
+			if end < 0 {
+				pos = limit
+				continue
+			}
+			name := strings.TrimSpace(string(rest[2:end]))
+			if htmlRules {
+				name = htmlSVGName(name, isSVGElement)
+			}
+			match := -1
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == name {
+					match = i
+					break
+				}
+			}
+			if match < 0 {
+				// An end tag from the surrounding markup: the fragment
+				// ended without closing everything
+				closeUnclosed(0, pos)
+				return errors, pos
+			}
+			closeUnclosed(match+1, pos)
+			stack = stack[:match]
+			pos += end + 1
+			if len(stack) == 0 {
+				return errors, pos
+			}
+			continue
+		}
+
+		name, attrs, tagEnd, selfClosing := parseSVGTag(content, pos, limit)
+		if name == "" {
+			pos++ // A stray "<" in text
+			continue
+		}
+		if htmlRules {
+			name = htmlSVGName(name, isSVGElement)
+			if spec, ok := svgElements[name]; ok {
+				for i := range attrs {
+					attrs[i].name = htmlSVGName(attrs[i].name, func(s string) bool { return svgAttrAllowed(spec, s) })
+				}
+			}
+		}
+
+		// A graphics element followed by a sibling it cannot contain was
+		// meant to be empty: close it here, as the fix will
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			topSpec, topKnown := svgElements[top.name]
+			spec, known := svgElements[name]
+			if top.foreign || !topKnown || !known || !slices.Equal(topSpec.content, svgLeafContent) ||
+				slices.Contains(topSpec.content, spec.category) {
+				break
+			}
+			closeUnclosed(len(stack)-1, pos)
+		}
+
+		var parent *svgOpenElement
+		if len(stack) > 0 {
+			parent = &stack[len(stack)-1]
+			parent.hasContent = true
+		}
+		checkSVGScripting(name, attrs, pos, report)
+		checkSVGExternalRefs(name, attrs, opts.SVGAllowHosts, report)
+		foreign := parent != nil && parent.foreign
+		if !foreign {
+			foreign = checkSVGElement(content, name, attrs, pos, tagEnd, parent, htmlRules, report)
+		}
+
+		if !selfClosing {
+			stack = append(stack, svgOpenElement{name: name, offset: pos, tagEnd: tagEnd, foreign: foreign})
+		}
+		pos = tagEnd
+
+		// Script and style content is not markup; skip to the end tag
+		if !selfClosing && (name == "script" || name == "style") {
+			end := bytes.Index(content[pos:limit], []byte("</"+name))
+			if end < 0 {
+				end = limit - pos
+			}
+			if name == "style" {
+				checkCSSExternalRefs(string(content[pos:pos+end]), pos, "<style>", opts.SVGAllowHosts, report)
+			}
+			pos += end
+			continue
+		}
+		if len(stack) == 0 {
+			return errors, pos
+		}
+	}
+
+	closeUnclosed(0, limit)
+	return errors, pos
+}
+
+// checkSVGElement checks one start tag against the element table and
+// reports whether its content should be left unchecked
+func checkSVGElement(content []byte, name string, attrs []svgAttr, offset, tagEnd int, parent *svgOpenElement, htmlRules bool, report svgReporter) bool {
+	tag := string(content[offset:tagEnd])
+
+	// Elements from other namespaces (inkscape:, sodipodi:) are allowed
+	if strings.Contains(name, ":") {
+		return true
+	}
+
+	spec, known := svgElements[name]
+	if !known {
+		message := fmt.Sprintf("<%s> is not an SVG element", name)
+		if suggestion := svgCaseMatch(name, isSVGElement); suggestion != "" {
+			message += fmt.Sprintf("; SVG names are case-sensitive, did you mean <%s>?", suggestion)
+		}
+		report(offset, "SVG unknown element", message, tag, nil)
+		return true
+	}
+
+	if parent != nil {
+		if parentSpec, ok := svgElements[parent.name]; ok && parentSpec.content != nil &&
+			!slices.Contains(parentSpec.content, spec.category) && !slices.Contains(parentSpec.extra, name) {
+			report(offset, "SVG misplaced element",
+				fmt.Sprintf("<%s> is not allowed inside <%s>", name, parent.name), tag, nil)
+		}
+	} else if name != "svg" {
+		report(offset, "SVG misplaced element", fmt.Sprintf("<%s> must be inside an <svg>", name), tag, nil)
+	}
+
+	for _, attr := range attrs {
+		if !attr.quoted && attr.hasValue && !htmlRules {
+			report(attr.offset, "SVG unquoted attribute",
+				fmt.Sprintf("SVG attribute %s=%s should use quotes: %s=\"%s\"", attr.name, attr.value, attr.name, attr.value),
+				attr.name+"="+attr.value,
+				&SuggestedFix{Start: attr.valueAt, End: attr.valueAt + len(attr.value), Replacement: `"` + attr.value + `"`})
+		}
+		if !svgAttrAllowed(spec, attr.name) {
+			message := fmt.Sprintf("<%s> does not accept the %s attribute", name, attr.name)
+			if suggestion := svgCaseMatch(attr.name, func(s string) bool { return svgAttrAllowed(spec, s) }); suggestion != "" {
+				message += fmt.Sprintf("; SVG names are case-sensitive, did you mean %s?", suggestion)
+			}
+			report(attr.offset, "SVG disallowed attribute", message, attr.name, nil)
+			continue
+		}
+		for _, vc := range svgValueChecks[attr.name] {
+			if !attr.hasValue || vc.elements != nil && !slices.Contains(vc.elements, name) {
+				continue
+			}
+			if at, problem := vc.check(attr.value); at >= 0 {
+				report(attr.valueAt+at, vc.errorType,
+					fmt.Sprintf("<%s %s> at offset %d: %s", name, attr.name, at, problem), attr.name+"="+attr.value, nil)
+			}
+			break
+		}
+	}
+
+	if attr, problem := checkViewBoxViewport(attrs); attr != nil {
+		report(attr.offset, "SVG viewBox mismatch", fmt.Sprintf("<%s>: %s", name, problem), attr.name+"="+attr.value, nil)
+	}
+
+	return spec.content == nil
+}
+
+// svgAttrAllowed reports whether an element accepts the named attribute
+func svgAttrAllowed(spec svgElementSpec, name string) bool {
+	switch {
+	case spec.groups&svgAnyAttr != 0:
+		return true
+	case name == "xmlns", name == "role", strings.HasPrefix(name, "xmlns:"),
+		strings.HasPrefix(name, "on"), strings.HasPrefix(name, "aria-"), strings.HasPrefix(name, "data-"):
+		return true
+	case slices.Contains(spec.attrs, name):
+		return true
+	case strings.HasPrefix(name, "xlink:"):
+		return slices.Contains(svgXLinkAttrs, name) && (slices.Contains(spec.attrs, "href") || spec.groups&svgTiming != 0)
+	case strings.Contains(name, ":") && !strings.HasPrefix(name, "xml:"):
+		return true // Attributes from other namespaces
+	}
+	for _, g := range []struct {
+		group svgAttrGroup
+		attrs []string
+	}{
+		{svgCore, svgCoreAttrs},
+		{svgPresentation, svgPresentationAttrs},
+		{svgConditional, svgConditionalAttrs},
+		{svgTiming, svgTimingAttrs},
+		{svgPrimitiveRegion, svgPrimitiveRegionAttrs},
+	} {
+		if spec.groups&g.group != 0 && slices.Contains(g.attrs, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSVGElement reports whether name is in the SVG element table
+func isSVGElement(name string) bool {
+	_, ok := svgElements[name]
+	return ok
+}
+
+// htmlSVGName returns the name an HTML parser gives an SVG element or
+// attribute: the known name that matches it case-insensitively, such as
+// viewBox for viewbox, or name itself
+func htmlSVGName(name string, known func(string) bool) string {
+	if known(name) {
+		return name
+	}
+	if match := svgCaseMatch(name, known); match != "" {
+		return match
+	}
+	return name
+}
+
+// svgCaseMatch returns the correctly-cased name that known accepts when
+// name differs from it only in case, e.g. "viewbox" for "viewBox"
+func svgCaseMatch(name string, known func(string) bool) string {
+	candidates := slices.Concat(svgCoreAttrs, svgPresentationAttrs, svgConditionalAttrs, svgTimingAttrs, svgPrimitiveRegionAttrs)
+	for element, spec := range svgElements {
+		candidates = append(candidates, element)
+		candidates = append(candidates, spec.attrs...)
+	}
+	for _, c := range candidates {
+		if c != name && strings.EqualFold(c, name) && known(c) {
+			return c
+		}
+	}
+	return ""
+}
+
+// parseSVGTag parses the start tag at pos. It is lenient in the way HTML
+// parsers are so that unquoted values can be reported rather than
+// stopping the scan. name is empty if pos does not start a tag.
+func parseSVGTag(content []byte, pos, limit int) (name string, attrs []svgAttr, tagEnd int, selfClosing bool) {
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\r' || c == '\n' }
+
+	i := pos + 1
+	for i < limit && !isSpace(content[i]) && content[i] != '>' && content[i] != '/' {
+		i++
+	}
+	name = string(content[pos+1 : i])
+	if name == "" || !(content[pos+1] == '_' || content[pos+1] >= 'A' && content[pos+1] <= 'Z' || content[pos+1] >= 'a' && content[pos+1] <= 'z') {
+		return "", nil, 0, false
+	}
+
+	for i < limit {
+		for i < limit && isSpace(content[i]) {
+			i++
+		}
+		if i >= limit {
+			break
+		}
+		if content[i] == '>' {
+			return name, attrs, i + 1, false
+		}
+		if content[i] == '/' {
+			if i+1 < limit && content[i+1] == '>' {
+				return name, attrs, i + 2, true
+			}
+			i++
+			continue
+		}
+
+		attr := svgAttr{offset: i}
+		for i < limit && !isSpace(content[i]) && content[i] != '=' && content[i] != '>' && content[i] != '/' {
+			i++
+		}
+		attr.name = string(content[attr.offset:i])
+		for i < limit && isSpace(content[i]) {
+			i++
+		}
+		if i < limit && content[i] == '=' {
+			i++
+			for i < limit && isSpace(content[i]) {
+				i++
+			}
+			attr.hasValue = true
+			if i < limit && (content[i] == '"' || content[i] == '\'') {
+				quote := content[i]
+				end := bytes.IndexByte(content[i+1:limit], quote)
+				if end < 0 {
+					end = limit - i - 1
+				}
+				attr.quoted = true
+				attr.valueAt = i + 1
+				attr.value = string(content[i+1 : i+1+end])
+				i += end + 2
+			} else {
+				attr.valueAt = i
+				for i < limit && !isSpace(content[i]) && content[i] != '>' {
+					i++
+				}
+				attr.value = string(content[attr.valueAt:i])
+			}
+		}
+		attrs = append(attrs, attr)
+	}
+
+	return name, attrs, limit, false
+}
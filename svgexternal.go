@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// reCSSURL matches url(...) references in CSS, capturing the URL
+var reCSSURL = regexp.MustCompile(`url\(\s*['"]?([^'")\s]+)`)
+
+// Attributes that make an element load a resource
+var svgResourceAttrs = map[string]bool{"href": true, "xlink:href": true, "src": true}
+
+// checkSVGExternalRefs reports resources an element loads from another
+// host: href/src on elements such as <image>, <use> and <feImage>, and
+// CSS url() references in style and presentation attributes. Links on
+// <a> only load when followed, so they are not reported.
+func checkSVGExternalRefs(name string, attrs []svgAttr, allow []string, report svgReporter) {
+	for _, attr := range attrs {
+		if svgResourceAttrs[attr.name] && name != "a" {
+			if host, ok := externalHost(attr.value, allow); ok {
+				report(attr.offset, "SVG external reference",
+					fmt.Sprintf("<%s %s> loads %s from %s; it will not render offline and the request reveals the reader to that host",
+						name, attr.name, attr.value, host), attr.name+"="+attr.value, nil)
+			}
+			continue
+		}
+		checkCSSExternalRefs(attr.value, attr.valueAt, fmt.Sprintf("<%s %s>", name, attr.name), allow, report)
+	}
+}
+
+// checkCSSExternalRefs reports url() references to other hosts in CSS
+// text that starts at offset in the document
+func checkCSSExternalRefs(css string, offset int, where string, allow []string, report svgReporter) {
+	for _, match := range reCSSURL.FindAllStringSubmatchIndex(css, -1) {
+		ref := css[match[2]:match[3]]
+		if host, ok := externalHost(ref, allow); ok {
+			report(offset+match[0], "SVG external reference",
+				fmt.Sprintf("%s url(%s) loads from %s; it will not render offline and the request reveals the reader to that host",
+					where, ref, host), css[match[0]:match[1]], nil)
+		}
+	}
+}
+
+// externalHost returns the host of an absolute or protocol-relative URL
+// unless it is in allow; subdomains of an allowed host are allowed too
+func externalHost(ref string, allow []string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || u.Host == "" {
+		return "", false // Fragments, data: URIs and relative paths
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range allow {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return "", false
+		}
+	}
+	return host, true
+}
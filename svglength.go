@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Units accepted after a length: the SVG 1.1 units plus the CSS units that
+// SVG 2 renderers understand. Units are case-insensitive, as in CSS.
+var svgLengthUnits = map[string]bool{
+	"px": true, "em": true, "ex": true, "in": true, "cm": true, "mm": true,
+	"pt": true, "pc": true, "%": true,
+	"rem": true, "ch": true, "q": true, "vw": true, "vh": true, "vmin": true, "vmax": true,
+}
+
+// checkLength validates a single length such as 100, 50% or 1.5em
+func checkLength(value string) (int, string) {
+	i := skipWSP(value, 0)
+	if i == len(value) {
+		return i, "empty length"
+	}
+	end, problem := scanLength(value, i)
+	if problem != "" {
+		return end, problem
+	}
+	if end = skipWSP(value, end); end < len(value) {
+		return end, fmt.Sprintf("unexpected %q after the length", value[end:])
+	}
+	return -1, ""
+}
+
+// checkLengthOrAuto validates a length that may also be auto, as SVG 2
+// allows for width, height, rx and ry
+func checkLengthOrAuto(value string) (int, string) {
+	if strings.TrimSpace(value) == "auto" {
+		return -1, ""
+	}
+	return checkLength(value)
+}
+
+// checkLengthList validates the comma or space separated lengths that
+// <text> and <tspan> accept to position individual characters
+func checkLengthList(value string) (int, string) {
+	i := skipWSP(value, 0)
+	for i < len(value) {
+		end, problem := scanLength(value, i)
+		if problem != "" {
+			return end, problem
+		}
+		i = skipCommaWSP(value, end)
+	}
+	return -1, ""
+}
+
+// scanLength scans a number and an optional unit at i. It returns the
+// offset just past the length, or the offset and description of a problem.
+func scanLength(s string, i int) (int, string) {
+	end, ok := scanNumber(s, i)
+	if !ok {
+		// scanNumber reads the e of em and ex as an exponent
+		if e := strings.IndexAny(s[i:], "eE"); e > 0 {
+			end, ok = scanNumber(s[:i+e], i)
+			ok = ok && end == i+e
+		}
+		if !ok {
+			return i, fmt.Sprintf("invalid length %q", transformToken(s, i))
+		}
+	}
+
+	unitEnd := scanUnit(s, end)
+	if unit := s[end:unitEnd]; unit != "" && !svgLengthUnits[strings.ToLower(unit)] {
+		return end, fmt.Sprintf("unknown unit %q in %q", unit, s[i:unitEnd])
+	}
+	if unitEnd == end {
+		// Renderers disagree on "50 px": some ignore the unit, some the value
+		if next := skipWSP(s, end); next > end {
+			if unit := s[next:scanUnit(s, next)]; svgLengthUnits[strings.ToLower(unit)] {
+				return end, fmt.Sprintf("space between %s and its unit %s", s[i:end], unit)
+			}
+		}
+	}
+	return unitEnd, ""
+}
+
+// scanUnit returns the offset past the letters or % at i
+func scanUnit(s string, i int) int {
+	for i < len(s) && (s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || s[i] == '%') {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,139 @@
+package main
+
+import "fmt"
+
+// Number of arguments each path command takes per segment
+var pathCommandArgs = map[byte]int{
+	'M': 2, 'm': 2, 'L': 2, 'l': 2, 'T': 2, 't': 2,
+	'H': 1, 'h': 1, 'V': 1, 'v': 1,
+	'C': 6, 'c': 6, 'S': 4, 's': 4, 'Q': 4, 'q': 4,
+	'A': 7, 'a': 7,
+	'Z': 0, 'z': 0,
+}
+
+// checkPathData validates SVG path data against the grammar of SVG 2,
+// section 9.3.9. It returns the byte offset in d and a description of the
+// first invalid token, or -1 when the data is valid.
+func checkPathData(d string) (int, string) {
+	i := skipWSP(d, 0)
+	if i == len(d) {
+		return -1, "" // Empty path data disables rendering but is allowed
+	}
+	if d[i] != 'M' && d[i] != 'm' {
+		return i, fmt.Sprintf("path data must start with a moveto (M or m), not %q", d[i])
+	}
+
+	for i < len(d) {
+		command := d[i]
+		argc, ok := pathCommandArgs[command]
+		if !ok {
+			return i, fmt.Sprintf("unknown path command %q", command)
+		}
+		i = skipWSP(d, i+1)
+		if argc == 0 {
+			continue
+		}
+
+		for segments := 0; ; segments++ {
+			if i == len(d) || isPathCommand(d[i]) {
+				if segments == 0 {
+					return i, fmt.Sprintf("%c has no arguments (expects %d)", command, argc)
+				}
+				break
+			}
+			for arg := 0; arg < argc; arg++ {
+				if arg > 0 {
+					i = skipCommaWSP(d, i)
+				}
+				if i == len(d) || isPathCommand(d[i]) {
+					return i, fmt.Sprintf("%c segment has %d of its %d arguments", command, arg, argc)
+				}
+				if (command == 'A' || command == 'a') && (arg == 3 || arg == 4) {
+					// Flags are a single 0 or 1 and need no separator
+					if d[i] != '0' && d[i] != '1' {
+						return i, fmt.Sprintf("arc flag must be 0 or 1, not %q", d[i])
+					}
+					i++
+					continue
+				}
+				end, ok := scanNumber(d, i)
+				if !ok {
+					return i, fmt.Sprintf("invalid number %q", pathToken(d, i))
+				}
+				i = end
+			}
+			i = skipCommaWSP(d, i)
+		}
+	}
+
+	return -1, ""
+}
+
+// isPathCommand reports whether c is a letter that starts a command
+func isPathCommand(c byte) bool {
+	_, ok := pathCommandArgs[c]
+	return ok || (c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') && c != 'e' && c != 'E'
+}
+
+// scanNumber scans a number with optional sign, fraction and exponent
+// starting at i, returning the offset just past it
+func scanNumber(s string, i int) (int, bool) {
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return i, false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j == len(s) || s[j] < '0' || s[j] > '9' {
+			return j, false
+		}
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		i = j
+	}
+	return i, true
+}
+
+// skipWSP skips SVG whitespace
+func skipWSP(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\r' || s[i] == '\n' || s[i] == '\f') {
+		i++
+	}
+	return i
+}
+
+// skipCommaWSP skips whitespace with at most one comma
+func skipCommaWSP(s string, i int) int {
+	i = skipWSP(s, i)
+	if i < len(s) && s[i] == ',' {
+		i = skipWSP(s, i+1)
+	}
+	return i
+}
+
+// pathToken returns the run of non-separator characters at i for messages
+func pathToken(s string, i int) string {
+	end := i + 1
+	for end < len(s) && s[end] != ' ' && s[end] != ',' && !isPathCommand(s[end]) {
+		end++
+	}
+	return s[i:min(end, len(s))]
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Attributes whose value is a URL that could use the javascript: scheme
+var svgURLAttrs = map[string]bool{"href": true, "xlink:href": true}
+
+// checkSVGScripting flags the ways an SVG can run script when served to a
+// browser: <script> elements, on* event handler attributes, javascript:
+// links, and animations that set a link to a javascript: URL. It runs on
+// every element inside an <svg>, including foreign content.
+func checkSVGScripting(name string, attrs []svgAttr, offset int, report svgReporter) {
+	if strings.EqualFold(name, "script") {
+		report(offset, "SVG script", "<script> inside SVG runs when the file is opened in a browser", "<"+name, nil)
+	}
+
+	animatesHref := false
+	for _, attr := range attrs {
+		if attr.name == "attributeName" && svgURLAttrs[strings.TrimSpace(attr.value)] {
+			animatesHref = true
+		}
+	}
+
+	for _, attr := range attrs {
+		lower := strings.ToLower(attr.name)
+		switch {
+		case strings.HasPrefix(lower, "on") && len(lower) > 2:
+			report(attr.offset, "SVG event handler",
+				fmt.Sprintf("<%s %s> runs script when the event fires", name, attr.name), attr.name, nil)
+
+		case svgURLAttrs[lower] && isJavaScriptURL(attr.value):
+			report(attr.offset, "SVG javascript URL",
+				fmt.Sprintf("<%s %s> links to a javascript: URL", name, attr.name), attr.name+"="+attr.value, nil)
+
+		case animatesHref && (attr.name == "to" || attr.name == "from" || attr.name == "values") && containsJavaScriptURL(attr.value):
+			report(attr.offset, "SVG javascript URL",
+				fmt.Sprintf("<%s> sets a link to a javascript: URL", name), attr.name+"="+attr.value, nil)
+		}
+	}
+}
+
+// isJavaScriptURL reports whether a URL attribute uses the javascript:
+// scheme the way a browser would see it: after decoding character
+// references and ignoring the whitespace and control characters browsers
+// strip from URLs
+func isJavaScriptURL(value string) bool {
+	decoded := html.UnescapeString(value)
+	var b strings.Builder
+	for _, r := range decoded {
+		if r > ' ' {
+			b.WriteRune(r)
+		}
+	}
+	return strings.HasPrefix(strings.ToLower(b.String()), "javascript:")
+}
+
+// containsJavaScriptURL checks each entry of a semicolon-separated
+// animation values list
+func containsJavaScriptURL(value string) bool {
+	for _, v := range strings.Split(value, ";") {
+		if isJavaScriptURL(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Argument counts each transform function accepts
+var transformArgs = map[string][]int{
+	"matrix":    {6},
+	"translate": {1, 2},
+	"scale":     {1, 2},
+	"rotate":    {1, 3},
+	"skewX":     {1},
+	"skewY":     {1},
+}
+
+// checkTransformList validates a transform, gradientTransform or
+// patternTransform value against the SVG transform-list grammar. It
+// returns the byte offset of the first problem and a description of it,
+// or -1 when the list is valid.
+func checkTransformList(value string) (int, string) {
+	i := skipWSP(value, 0)
+	for first := true; i < len(value); first = false {
+		if !first {
+			i = skipCommaWSP(value, i)
+			if i == len(value) {
+				return i - 1, "trailing separator after the last transform"
+			}
+		}
+
+		start := i
+		for i < len(value) && (value[i] >= 'a' && value[i] <= 'z' || value[i] >= 'A' && value[i] <= 'Z') {
+			i++
+		}
+		name := value[start:i]
+		counts, ok := transformArgs[name]
+		if !ok {
+			if name == "" {
+				return start, fmt.Sprintf("expected a transform function, found %q", value[start])
+			}
+			return start, fmt.Sprintf("unknown transform function %q", name)
+		}
+
+		i = skipWSP(value, i)
+		if i == len(value) || value[i] != '(' {
+			return i, fmt.Sprintf("%s must be followed by (", name)
+		}
+		i = skipWSP(value, i+1)
+
+		args := 0
+		for i < len(value) && value[i] != ')' {
+			if args > 0 {
+				i = skipCommaWSP(value, i)
+			}
+			if i >= len(value) {
+				// A trailing comma, as in "translate(10,"
+				return start, fmt.Sprintf("%s( is missing an argument and never closed with )", name)
+			}
+			end, ok := scanNumber(value, i)
+			if !ok {
+				return i, fmt.Sprintf("invalid number %q in %s()", transformToken(value, i), name)
+			}
+			if end < len(value) && (value[end] >= 'a' && value[end] <= 'z' || value[end] == '%') {
+				return i, fmt.Sprintf("%q in %s() has a unit; transform attributes take plain numbers", transformToken(value, i), name)
+			}
+			i = skipWSP(value, end)
+			args++
+		}
+		if i == len(value) {
+			return start, fmt.Sprintf("%s( is never closed with )", name)
+		}
+		if !slices.Contains(counts, args) {
+			return start, fmt.Sprintf("%s() takes %s arguments, found %d", name, joinCounts(counts), args)
+		}
+		i = skipWSP(value, i+1)
+	}
+	return -1, ""
+}
+
+// joinCounts formats allowed argument counts as "1 or 2"
+func joinCounts(counts []int) string {
+	var parts []string
+	for _, c := range counts {
+		parts = append(parts, fmt.Sprint(c))
+	}
+	return strings.Join(parts, " or ")
+}
+
+// transformToken returns the text at i up to the next separator, or ""
+// at the end of s
+func transformToken(s string, i int) string {
+	if i >= len(s) {
+		return ""
+	}
+	end := i
+	for end < len(s) && !strings.ContainsRune(" \t\r\n,()", rune(s[end])) {
+		end++
+	}
+	if end == i {
+		return s[i : i+1]
+	}
+	return s[i:end]
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseViewBox reads the four numbers of a viewBox value. On failure it
+// returns the offset of the problem and a description of it.
+func parseViewBox(value string) ([4]float64, int, string) {
+	var box [4]float64
+	i := skipWSP(value, 0)
+	for n := 0; n < 4; n++ {
+		if n > 0 {
+			i = skipCommaWSP(value, i)
+		}
+		if i == len(value) {
+			return box, i, fmt.Sprintf("viewBox needs 4 numbers (min-x min-y width height), found %d", n)
+		}
+		end, ok := scanNumber(value, i)
+		if !ok {
+			return box, i, fmt.Sprintf("invalid number %q in viewBox", transformToken(value, i))
+		}
+		box[n], _ = strconv.ParseFloat(value[i:end], 64)
+		i = end
+	}
+	if i = skipWSP(value, i); i < len(value) {
+		return box, i, "viewBox has more than 4 numbers"
+	}
+	return box, -1, ""
+}
+
+// checkViewBox validates a viewBox value: four numbers with a width and
+// height that are not negative, and not zero since that disables rendering
+func checkViewBox(value string) (int, string) {
+	box, at, problem := parseViewBox(value)
+	if at >= 0 {
+		return at, problem
+	}
+	switch {
+	case box[2] < 0 || box[3] < 0:
+		return 0, fmt.Sprintf("viewBox width and height must not be negative (%g x %g); the element will not render", box[2], box[3])
+	case box[2] == 0 || box[3] == 0:
+		return 0, fmt.Sprintf("viewBox has zero area (%g x %g); the element will render blank", box[2], box[3])
+	}
+	return -1, ""
+}
+
+// checkViewBoxViewport compares a valid viewBox with the width and height
+// attributes of the same element. It returns the attribute to report and a
+// description of the problem, or nil.
+func checkViewBoxViewport(attrs []svgAttr) (*svgAttr, string) {
+	var viewBox, width, height, aspect *svgAttr
+	for i := range attrs {
+		switch attrs[i].name {
+		case "viewBox":
+			viewBox = &attrs[i]
+		case "width":
+			width = &attrs[i]
+		case "height":
+			height = &attrs[i]
+		case "preserveAspectRatio":
+			aspect = &attrs[i]
+		}
+	}
+	if viewBox == nil || width == nil || height == nil {
+		return nil, ""
+	}
+	box, at, _ := parseViewBox(viewBox.value)
+	if at >= 0 || box[2] <= 0 || box[3] <= 0 {
+		return nil, "" // Reported by checkViewBox
+	}
+
+	w, wOK := userUnits(width.value)
+	h, hOK := userUnits(height.value)
+	if !wOK || !hOK {
+		return nil, "" // Percentages and font-relative units depend on layout
+	}
+	if w == 0 || h == 0 {
+		return width, fmt.Sprintf("width=%q height=%q gives the viewport zero area; the viewBox will render blank", width.value, height.value)
+	}
+
+	// A different aspect ratio letterboxes the drawing, or stretches it
+	// when preserveAspectRatio="none"
+	if math.Abs(w/h-box[2]/box[3]) > 0.01*box[2]/box[3] {
+		effect := "it will be letterboxed"
+		if aspect != nil && strings.TrimSpace(aspect.value) == "none" {
+			effect = "it will be stretched because preserveAspectRatio=\"none\""
+		}
+		return viewBox, fmt.Sprintf("viewBox aspect ratio %g:%g does not match width=%q height=%q; %s",
+			box[2], box[3], width.value, height.value, effect)
+	}
+	return nil, ""
+}
+
+// userUnits converts a length without a unit or in px to a number
+func userUnits(value string) (float64, bool) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+	n, err := strconv.ParseFloat(value, 64)
+	return n, err == nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// The styles the color functions print in
+var (
+	successStyle   = color.New(color.FgGreen)
+	errorStyle     = color.New(color.FgRed)
+	highlightStyle = color.New(color.FgYellow)
+	headerStyle    = color.New(color.FgCyan)
+	infoStyle      = color.New(color.FgBlue)
+)
+
+// theme lists the attributes of each style
+type theme struct {
+	success, error, highlight, header, info []color.Attribute
+}
+
+// fg256 returns the attributes for a color of the 256-color palette
+func fg256(n color.Attribute) []color.Attribute {
+	return []color.Attribute{38, 5, n}
+}
+
+// Themes selectable with --theme. The colorblind theme uses the
+// Okabe-Ito palette, telling success from errors by blue and orange
+// rather than green and red.
+var themes = map[string]theme{
+	"default": {
+		success:   []color.Attribute{color.FgGreen},
+		error:     []color.Attribute{color.FgRed},
+		highlight: []color.Attribute{color.FgYellow},
+		header:    []color.Attribute{color.FgCyan},
+		info:      []color.Attribute{color.FgBlue},
+	},
+	"colorblind": {
+		success:   fg256(32),                      // Blue
+		error:     append(fg256(208), color.Bold), // Orange
+		highlight: fg256(222),                     // Yellow
+		header:    fg256(74),                      // Sky blue
+		info:      fg256(175),                     // Reddish purple
+	},
+	"mono": {
+		success:   []color.Attribute{color.Bold},
+		error:     []color.Attribute{color.Bold, color.Underline},
+		highlight: []color.Attribute{color.Underline},
+		header:    []color.Attribute{color.Bold},
+		info:      []color.Attribute{color.Faint},
+	},
+	"solarized": {
+		success:   fg256(64),  // Green
+		error:     fg256(160), // Red
+		highlight: fg256(136), // Yellow
+		header:    fg256(37),  // Cyan
+		info:      fg256(33),  // Blue
+	},
+}
+
+// applyTheme switches the styles to the named theme
+func applyTheme(name string) error {
+	t, ok := themes[name]
+	if !ok {
+		names := make([]string, 0, len(themes))
+		for n := range themes {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("unknown theme %q (want %s)", name, strings.Join(names, ", "))
+	}
+	successStyle = color.New(t.success...)
+	errorStyle = color.New(t.error...)
+	highlightStyle = color.New(t.highlight...)
+	headerStyle = color.New(t.header...)
+	infoStyle = color.New(t.info...)
+	return nil
+}
+
+// outputStyles returns every style, for switching colors on or off
+func outputStyles() []*color.Color {
+	return []*color.Color{successStyle, errorStyle, highlightStyle, headerStyle, infoStyle}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// timings adds up how long each check took, across every input validated
+// with --timings. A nil *timings records nothing.
+type timings struct {
+	mu     sync.Mutex
+	checks map[string]time.Duration
+	inputs int
+}
+
+func newTimings() *timings {
+	return &timings{checks: make(map[string]time.Duration)}
+}
+
+// add records d more time spent in the named check
+func (t *timings) add(check string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.checks[check] += d
+	t.mu.Unlock()
+}
+
+// since records the time from start spent in the named check
+func (t *timings) since(check string, start time.Time) {
+	if t != nil {
+		t.add(check, time.Since(start))
+	}
+}
+
+// validated counts one more input timed
+func (t *timings) validated() {
+	if t != nil {
+		t.mu.Lock()
+		t.inputs++
+		t.mu.Unlock()
+	}
+}
+
+// print writes the time of each check, slowest first. The checks run
+// concurrently, so their times add up to more than the run took.
+func (t *timings) print(w io.Writer) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	names := make([]string, 0, len(t.checks))
+	var total time.Duration
+	for name, d := range t.checks {
+		names = append(names, name)
+		total += d
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(t.checks[b], t.checks[a]), cmp.Compare(a, b))
+	})
+
+	fmt.Fprintf(w, "\n%s (%d inputs; checks run concurrently, disable slow ones with --disable)\n", headerColor("Check timings"), t.inputs)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		d := t.checks[name]
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(d) / float64(total)
+		}
+		precision := time.Microsecond
+		if d >= time.Second {
+			precision = time.Millisecond
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%.1f%%\n", name, d.Round(precision), share)
+	}
+	tw.Flush()
+}
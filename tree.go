@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// xmlNode is a minimal element tree used by the structural (profile)
+// checks. Only what those checks need is kept: names, attributes, direct
+// text and the byte offset of the start tag for error positions.
+type xmlNode struct {
+	Name     xml.Name
+	Attr     []xml.Attr
+	Children []*xmlNode
+	Parent   *xmlNode
+	Text     string // Character data directly inside this element, CDATA included
+	Offset   int    // Byte offset of the start tag in the document
+}
+
+// parseTree builds an element tree for a well-formed document and returns
+// its root element
+func parseTree(content []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	var root, current *xmlNode
+
+	for {
+		offset := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{Name: t.Name, Attr: t.Copy().Attr, Parent: current, Offset: offset}
+			if current == nil {
+				root = node
+			} else {
+				current.Children = append(current.Children, node)
+			}
+			current = node
+		case xml.CharData:
+			if current != nil {
+				current.Text += string(t)
+			}
+		case xml.EndElement:
+			if current != nil {
+				current = current.Parent
+			}
+		}
+	}
+
+	return root, nil
+}
+
+// child returns the first child element with the given namespace URI and
+// local name, or nil. An empty space matches elements in any namespace.
+func (n *xmlNode) child(space, local string) *xmlNode {
+	for _, c := range n.Children {
+		if c.Name.Local == local && (space == "" || c.Name.Space == space) {
+			return c
+		}
+	}
+	return nil
+}
+
+// childrenNamed returns all child elements with the given name
+func (n *xmlNode) childrenNamed(space, local string) []*xmlNode {
+	var out []*xmlNode
+	for _, c := range n.Children {
+		if c.Name.Local == local && (space == "" || c.Name.Space == space) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// attr returns the value of the named attribute and whether it was present
+func (n *xmlNode) attr(local string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Name.Local == local {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// trimmedText returns the element's text without surrounding whitespace
+func (n *xmlNode) trimmedText() string {
+	return strings.TrimSpace(n.Text)
+}
+
+// nodeError builds a ValidationError positioned at the start tag of n
+func nodeError(ix *lineIndex, n *xmlNode, errorType, message string) ValidationError {
+	line, col, lineContent := findErrorPosition(ix, n.Offset)
+	return ValidationError{
+		LineNumber: line,
+		Column:     col,
+		Line:       lineContent,
+		ErrorType:  errorType,
+		Message:    message,
+		Content:    "<" + n.Name.Local,
+	}
+}
+
+// lineOf returns the 1-based line number of a byte offset
+func lineOf(ix *lineIndex, offset int) int {
+	return ix.lineAt(min(offset, len(ix.content)))
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits for --check-urls requests
+const (
+	urlCheckTimeout = 10 * time.Second
+	urlCheckWorkers = 8
+)
+
+// urlCheck is one URL to probe and the node that referenced it
+type urlCheck struct {
+	url  string
+	node *xmlNode
+}
+
+// urlProbe is the outcome of probing a URL. problem is empty when the URL
+// resolved; length is -1 when the server did not report a size.
+type urlProbe struct {
+	problem     string
+	length      int64
+	contentType string
+}
+
+// checkWXRAttachmentURLs requests every attachment's <wp:attachment_url>
+// and <guid> when --check-urls is set, so broken media is found before a
+// migration instead of after it
+func checkWXRAttachmentURLs(ix *lineIndex, channel *xmlNode, opts ValidationOptions) []ValidationError {
+	if !opts.CheckURLs {
+		return nil
+	}
+
+	var checks []urlCheck
+	seen := make(map[string]bool)
+	for _, item := range channel.childrenNamed("", "item") {
+		if t := wpChild(item, "post_type"); t == nil || t.trimmedText() != "attachment" {
+			continue
+		}
+		for _, node := range []*xmlNode{wpChild(item, "attachment_url"), item.child("", "guid")} {
+			if node == nil || !isHTTP(node.trimmedText()) || seen[node.trimmedText()] {
+				continue
+			}
+			seen[node.trimmedText()] = true
+			checks = append(checks, urlCheck{url: node.trimmedText(), node: node})
+		}
+	}
+
+	fmt.Fprintln(opts.progressOut(), infoColor(fmt.Sprintf("Checking %d attachment URLs...", len(checks))))
+	problems := probeURLs(checks)
+
+	var errors []ValidationError
+	for i, check := range checks {
+		if problems[i].problem != "" {
+			errors = append(errors, nodeError(ix, check.node, "Broken attachment URL",
+				fmt.Sprintf("%s: %s", check.url, problems[i].problem)))
+		}
+	}
+	return errors
+}
+
+// checkRSSEnclosures requests every <enclosure url=...> when --check-urls
+// is set and compares the declared length and type attributes with the
+// Content-Length and Content-Type the server reports
+func checkRSSEnclosures(ix *lineIndex, channel *xmlNode, opts ValidationOptions) []ValidationError {
+	if !opts.CheckURLs {
+		return nil
+	}
+
+	var checks []urlCheck
+	for _, item := range channel.childrenNamed("", "item") {
+		for _, enclosure := range item.childrenNamed("", "enclosure") {
+			if u, _ := enclosure.attr("url"); isHTTP(u) {
+				checks = append(checks, urlCheck{url: u, node: enclosure})
+			}
+		}
+	}
+
+	fmt.Fprintln(opts.progressOut(), infoColor(fmt.Sprintf("Checking %d enclosure URLs...", len(checks))))
+	probes := probeURLs(checks)
+
+	var errors []ValidationError
+	for i, check := range checks {
+		probe := probes[i]
+		if probe.problem != "" {
+			errors = append(errors, nodeError(ix, check.node, "Broken enclosure URL",
+				fmt.Sprintf("%s: %s", check.url, probe.problem)))
+			continue
+		}
+
+		declaredLength, _ := check.node.attr("length")
+		if probe.length >= 0 && declaredLength != strconv.FormatInt(probe.length, 10) {
+			errors = append(errors, nodeError(ix, check.node, "Enclosure length mismatch",
+				fmt.Sprintf("Enclosure length=%q but %s is %d bytes", declaredLength, check.url, probe.length)))
+		}
+
+		declaredType, _ := check.node.attr("type")
+		if actual := mediaType(probe.contentType); actual != "" && mediaType(declaredType) != actual {
+			errors = append(errors, nodeError(ix, check.node, "Enclosure type mismatch",
+				fmt.Sprintf("Enclosure type=%q but the server sends %s as %q", declaredType, check.url, actual)))
+		}
+	}
+	return errors
+}
+
+// mediaType returns the lowercased type/subtype of a MIME type, dropping
+// parameters such as charset
+func mediaType(value string) string {
+	if t, _, err := mime.ParseMediaType(value); err == nil {
+		return t
+	}
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// probeURLs checks the URLs concurrently and returns one probe result per
+// URL, in the same order
+func probeURLs(checks []urlCheck) []urlProbe {
+	client := &http.Client{Transport: httpClient().Transport, Timeout: urlCheckTimeout}
+	problems := make([]urlProbe, len(checks))
+
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for w := 0; w < urlCheckWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				problems[i] = probeURL(client, checks[i].url)
+			}
+		}()
+	}
+	for i := range checks {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return problems
+}
+
+// probeURL sends a HEAD request, falling back to a ranged GET for servers
+// that do not implement HEAD
+func probeURL(client *http.Client, url string) urlProbe {
+	resp, err := client.Head(url)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		req, reqErr := http.NewRequest(http.MethodGet, url, nil)
+		if reqErr != nil {
+			return urlProbe{problem: reqErr.Error()}
+		}
+		req.Header.Set("Range", "bytes=0-0")
+		resp, err = client.Do(req)
+	}
+	if err != nil {
+		return urlProbe{problem: fmt.Sprintf("request failed: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return urlProbe{problem: fmt.Sprintf("HTTP %s", resp.Status)}
+	}
+
+	probe := urlProbe{length: resp.ContentLength, contentType: resp.Header.Get("Content-Type")}
+	if resp.StatusCode == http.StatusPartialContent {
+		// A ranged response's length is the range; the total follows the slash
+		probe.length = -1
+		if _, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/"); ok {
+			if n, err := strconv.ParseInt(total, 10, 64); err == nil {
+				probe.length = n
+			}
+		}
+	}
+	return probe
+}
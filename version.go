@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set by release builds with
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=abc1234 -X main.buildDate=2024-01-01T00:00:00Z"
+//
+// Values left empty are filled in from the module and VCS information
+// the Go toolchain embeds.
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+// buildInfo returns the version, commit and build date of the binary. The
+// date falls back to the time of the commit.
+func buildInfo() (string, string, string) {
+	v, c, d := version, commit, buildDate
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		settings := make(map[string]string)
+		for _, s := range info.Settings {
+			settings[s.Key] = s.Value
+		}
+		if c == "" && settings["vcs.revision"] != "" {
+			c = settings["vcs.revision"]
+			if settings["vcs.modified"] == "true" {
+				c += "-dirty"
+			}
+		}
+		if d == "" {
+			d = settings["vcs.time"]
+		}
+	}
+	if v == "" {
+		v = "dev"
+	}
+	if c == "" {
+		c = "unknown"
+	}
+	if d == "" {
+		d = "unknown"
+	}
+	return v, c, d
+}
+
+// printVersion implements the "version" subcommand and --version
+func printVersion() {
+	v, c, d := buildInfo()
+	fmt.Printf("xml_validator %s\n", v)
+	fmt.Printf("Commit:     %s\n", c)
+	fmt.Printf("Date:       %s\n", d)
+	fmt.Printf("Go version: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
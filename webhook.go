@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+)
+
+// webhookTopRules is how many of the most frequent rules a notification
+// lists
+const webhookTopRules = 5
+
+// webhook collects the results of a run for --webhook and posts them as
+// one JSON summary when the run finishes
+type webhook struct {
+	url   string
+	files []webhookFile
+	rules map[string]*webhookRule
+}
+
+// webhookFile is the result for one input
+type webhookFile struct {
+	File     string `json:"file"`
+	Valid    bool   `json:"valid"`
+	Errors   int    `json:"errors"`
+	Warnings int    `json:"warnings"`
+	Error    string `json:"error,omitempty"` // Why the input could not be read
+}
+
+// webhookRule counts the issues one rule reported across the run
+type webhookRule struct {
+	Rule  string `json:"rule"`
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// webhookSummary is the body of a notification. Passed is false when the
+// run exits with a non-zero status, which Status holds (see exit.go).
+type webhookSummary struct {
+	Tool     string        `json:"tool"`
+	Version  string        `json:"version"`
+	Finished string        `json:"finished"`
+	Passed   bool          `json:"passed"`
+	Status   int           `json:"status"`
+	Inputs   int           `json:"inputs"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+	TopRules []webhookRule `json:"topRules"`
+	Files    []webhookFile `json:"files"`
+}
+
+// newWebhook checks the URL of --webhook
+func newWebhook(rawURL string) (*webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("--webhook needs an http:// or https:// URL, got %q", rawURL)
+	}
+	return &webhook{url: rawURL, rules: make(map[string]*webhookRule)}, nil
+}
+
+// add records the issues of file, or why it could not be read. A nil
+// webhook does nothing.
+func (w *webhook) add(file string, errs []ValidationError, readErr error, failOn string) {
+	if w == nil {
+		return
+	}
+	warnings := countWarnings(errs)
+	f := webhookFile{
+		File:     file,
+		Valid:    readErr == nil && countFailing(errs, failOn) == 0,
+		Errors:   len(errs) - warnings,
+		Warnings: warnings,
+	}
+	if readErr != nil {
+		f.Error = readErr.Error()
+	}
+	w.files = append(w.files, f)
+	for _, e := range errs {
+		id := cmp.Or(e.ruleID(), e.ErrorType)
+		if w.rules[id] == nil {
+			w.rules[id] = &webhookRule{Rule: id, Type: e.ErrorType}
+		}
+		w.rules[id].Count++
+	}
+}
+
+// exit sends the summary and exits with status. Every exit of a run but
+// those for bad flags and config goes through it, so that monitoring also
+// hears about runs that broke off, e.g. because a report could not be
+// written.
+func (w *webhook) exit(status int) {
+	w.send(status)
+	os.Exit(status)
+}
+
+// send posts the summary of the run, which exits with status. A webhook
+// that cannot be reached is reported but leaves the status alone: the
+// documents were validated all the same. A nil webhook does nothing.
+func (w *webhook) send(status int) {
+	if w == nil {
+		return
+	}
+	v, _, _ := buildInfo()
+	summary := webhookSummary{
+		Tool:     "xml_validator",
+		Version:  cmp.Or(v, "dev"),
+		Finished: time.Now().UTC().Format(time.RFC3339),
+		Passed:   status == exitOK,
+		Status:   status,
+		Inputs:   len(w.files),
+		TopRules: []webhookRule{},
+		Files:    w.files,
+	}
+	for _, f := range w.files {
+		summary.Errors += f.Errors
+		summary.Warnings += f.Warnings
+	}
+	for _, r := range w.rules {
+		summary.TopRules = append(summary.TopRules, *r)
+	}
+	slices.SortFunc(summary.TopRules, func(a, b webhookRule) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Rule, b.Rule))
+	})
+	summary.TopRules = summary.TopRules[:min(len(summary.TopRules), webhookTopRules)]
+
+	body, err := json.Marshal(summary)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s --webhook: %v\n", highlightColor("Warning:"), err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s --webhook: %v\n", highlightColor("Warning:"), err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", fetchOptions.UserAgent)
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s --webhook: %v\n", highlightColor("Warning:"), err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		fmt.Fprintf(os.Stderr, "%s --webhook: %s answered %s\n", highlightColor("Warning:"), req.URL.Redacted(), resp.Status)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// wpNamespacePrefix is shared by the WXR 1.0, 1.1 and 1.2 namespace URIs
+// (http://wordpress.org/export/1.2/ and so on)
+const wpNamespacePrefix = "http://wordpress.org/export/"
+
+// dcNamespace is the Dublin Core namespace used for <dc:creator>
+const dcNamespace = "http://purl.org/dc/elements/1.1/"
+
+// Channel children WordPress needs to import a file; "wp:" names are
+// matched against any WXR namespace version
+var wxrRequiredChannel = []string{"title", "link", "description", "wp:wxr_version", "wp:base_site_url"}
+
+// WXR versions the WordPress importer understands, oldest first
+var wxrVersions = []string{"1.0", "1.1", "1.2"}
+
+// wp: elements that did not exist before the given WXR version. Elements
+// not listed here have been part of the format since 1.0.
+var wxrIntroducedIn = map[string]string{
+	"author":        "1.1",
+	"base_site_url": "1.1",
+	"base_blog_url": "1.1",
+	"term":          "1.1",
+	"term_id":       "1.1",
+	"termmeta":      "1.2",
+	"commentmeta":   "1.2",
+}
+
+// Item children every exported post must carry
+var wxrRequiredItem = []string{"post_id", "post_type", "status"}
+
+// isWPNamespace reports whether space is one of the WXR namespace URIs
+func isWPNamespace(space string) bool {
+	return strings.HasPrefix(space, wpNamespacePrefix)
+}
+
+// wpChild returns the first wp: child of n with the given local name
+func wpChild(n *xmlNode, local string) *xmlNode {
+	for _, c := range n.Children {
+		if c.Name.Local == local && isWPNamespace(c.Name.Space) {
+			return c
+		}
+	}
+	return nil
+}
+
+// wxrChild looks up a child by its conventional WXR name, e.g. "title"
+// or "wp:post_id"
+func wxrChild(n *xmlNode, name string) *xmlNode {
+	if local, ok := strings.CutPrefix(name, "wp:"); ok {
+		return wpChild(n, local)
+	}
+	return n.child("", name)
+}
+
+// wxrCheck inspects the <channel> of a WXR export
+type wxrCheck func(ix *lineIndex, channel *xmlNode, opts ValidationOptions) []ValidationError
+
+// WXR checks in the order they run
+var wxrChecks = []wxrCheck{
+	checkWXRVersion,
+	checkWXRChannel,
+	checkWXRItems,
+	checkWXRUniqueness,
+	checkWXRReferences,
+	checkWXRSerializedMeta,
+	checkWXRSlugs,
+	checkWXRCollisions,
+	checkWXRAttachmentURLs,
+}
+
+// validateWXR checks the WordPress eXtended RSS structure of an export
+func validateWXR(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	root, err := parseTree(content)
+	if err != nil || root == nil {
+		return errors // Reported by validateBasicXML
+	}
+
+	if root.Name.Local != "rss" {
+		return append(errors, nodeError(ix, root, "WXR structure",
+			fmt.Sprintf("Root element is <%s>, a WXR export must start with <rss>", root.Name.Local)))
+	}
+	channel := root.child("", "channel")
+	if channel == nil {
+		return append(errors, nodeError(ix, root, "WXR structure", "<rss> has no <channel> element"))
+	}
+
+	for _, check := range wxrChecks {
+		errors = append(errors, check(ix, channel, opts)...)
+		if opts.MaxErrors > 0 && len(errors) >= opts.MaxErrors {
+			break
+		}
+	}
+
+	return errors
+}
+
+// detectWXRVersion returns the version declared in <wp:wxr_version>,
+// falling back to the version in the wp: namespace URI
+func detectWXRVersion(channel *xmlNode) string {
+	if v := wpChild(channel, "wxr_version"); v != nil && v.trimmedText() != "" {
+		return v.trimmedText()
+	}
+	for _, c := range channel.Children {
+		if isWPNamespace(c.Name.Space) {
+			return namespaceVersion(c.Name.Space)
+		}
+	}
+	return ""
+}
+
+// namespaceVersion extracts "1.2" from http://wordpress.org/export/1.2/
+func namespaceVersion(space string) string {
+	return strings.Trim(strings.TrimPrefix(space, wpNamespacePrefix), "/")
+}
+
+// wxrVersionIndex returns the position of version in wxrVersions, or -1
+func wxrVersionIndex(version string) int {
+	for i, v := range wxrVersions {
+		if v == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkWXRVersion validates the declared WXR version and reports elements
+// that only exist in newer versions of the format, since importers built
+// for the declared version may ignore or mishandle them
+func checkWXRVersion(ix *lineIndex, channel *xmlNode, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	declared := wpChild(channel, "wxr_version")
+	if declared == nil || declared.trimmedText() == "" {
+		return errors // Reported by checkWXRChannel
+	}
+	version := declared.trimmedText()
+	index := wxrVersionIndex(version)
+	if index < 0 {
+		return append(errors, nodeError(ix, declared, "WXR version",
+			fmt.Sprintf("Unsupported WXR version %q; the WordPress importer understands %s",
+				version, strings.Join(wxrVersions, ", "))))
+	}
+
+	if nsVersion := namespaceVersion(declared.Name.Space); nsVersion != version {
+		errors = append(errors, nodeError(ix, declared, "WXR version",
+			fmt.Sprintf("<wp:wxr_version> says %s but the wp: namespace is for version %s", version, nsVersion)))
+	}
+
+	reported := make(map[string]bool)
+	var walk func(n *xmlNode)
+	walk = func(n *xmlNode) {
+		for _, c := range n.Children {
+			if isWPNamespace(c.Name.Space) && !reported[c.Name.Local] {
+				if introduced, ok := wxrIntroducedIn[c.Name.Local]; ok && wxrVersionIndex(introduced) > index {
+					reported[c.Name.Local] = true
+					errors = append(errors, nodeError(ix, c, "WXR version",
+						fmt.Sprintf("<wp:%s> was introduced in WXR %s but this file declares version %s",
+							c.Name.Local, introduced, version)))
+				}
+			}
+			walk(c)
+		}
+	}
+	walk(channel)
+
+	return errors
+}
+
+// checkWXRChannel verifies the channel-level elements the importer needs
+func checkWXRChannel(ix *lineIndex, channel *xmlNode, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	for _, name := range wxrRequiredChannel {
+		if name == "wp:base_site_url" && detectWXRVersion(channel) == "1.0" {
+			continue // Added in 1.1
+		}
+		found := wxrChild(channel, name)
+		if found == nil {
+			errors = append(errors, nodeError(ix, channel, "WXR missing channel element",
+				fmt.Sprintf("<channel> is missing required <%s>", name)))
+		} else if name == "wp:wxr_version" && found.trimmedText() == "" {
+			errors = append(errors, nodeError(ix, found, "WXR missing channel element",
+				"<wp:wxr_version> is empty; the WordPress importer rejects files without a version"))
+		}
+	}
+
+	return errors
+}
+
+// checkWXRItems verifies every <item> carries the fields of a post
+func checkWXRItems(ix *lineIndex, channel *xmlNode, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+
+	for _, item := range channel.childrenNamed("", "item") {
+		for _, name := range wxrRequiredItem {
+			if field := wpChild(item, name); field == nil || field.trimmedText() == "" {
+				errors = append(errors, nodeError(ix, item, "WXR incomplete item",
+					fmt.Sprintf("<item> %s is missing <wp:%s>", describeItem(item), name)))
+			}
+		}
+	}
+
+	return errors
+}
+
+// checkWXRUniqueness reports repeated <guid> and <wp:post_id> values. The
+// importer treats a repeated GUID as an already-imported post and silently
+// skips it, so every duplicate is listed with the line of its first use.
+func checkWXRUniqueness(ix *lineIndex, channel *xmlNode, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+	seenGUID := make(map[string]*xmlNode)
+	seenID := make(map[string]*xmlNode)
+
+	for _, item := range channel.childrenNamed("", "item") {
+		if guid := item.child("", "guid"); guid != nil && guid.trimmedText() != "" {
+			value := guid.trimmedText()
+			if first, ok := seenGUID[value]; ok {
+				errors = append(errors, nodeError(ix, guid, "WXR duplicate GUID",
+					fmt.Sprintf("<guid> %q of item %s duplicates line %d; the importer will skip this post",
+						value, describeItem(item), lineOf(ix, first.Offset))))
+			} else {
+				seenGUID[value] = guid
+			}
+		}
+
+		if id := wpChild(item, "post_id"); id != nil && id.trimmedText() != "" {
+			value := id.trimmedText()
+			if first, ok := seenID[value]; ok {
+				errors = append(errors, nodeError(ix, id, "WXR duplicate post ID",
+					fmt.Sprintf("<wp:post_id> %s of item %s duplicates line %d",
+						value, describeItem(item), lineOf(ix, first.Offset))))
+			} else {
+				seenID[value] = id
+			}
+		}
+	}
+
+	return errors
+}
+
+// checkWXRReferences verifies that IDs and logins referenced by items are
+// defined in the same export: <wp:post_parent>, _thumbnail_id attachment
+// metadata, and <dc:creator> authors declared in <wp:author> blocks
+func checkWXRReferences(ix *lineIndex, channel *xmlNode, opts ValidationOptions) []ValidationError {
+	var errors []ValidationError
+	items := channel.childrenNamed("", "item")
+
+	postTypes := make(map[string]string)
+	for _, item := range items {
+		if id := wpChild(item, "post_id"); id != nil {
+			postType := ""
+			if t := wpChild(item, "post_type"); t != nil {
+				postType = t.trimmedText()
+			}
+			postTypes[id.trimmedText()] = postType
+		}
+	}
+
+	authors := make(map[string]bool)
+	for _, c := range channel.Children {
+		if c.Name.Local == "author" && isWPNamespace(c.Name.Space) {
+			if login := wpChild(c, "author_login"); login != nil {
+				authors[login.trimmedText()] = true
+			}
+		}
+	}
+
+	for _, item := range items {
+		if parent := wpChild(item, "post_parent"); parent != nil {
+			id := parent.trimmedText()
+			if _, ok := postTypes[id]; id != "" && id != "0" && !ok {
+				errors = append(errors, nodeError(ix, parent, "WXR broken reference",
+					fmt.Sprintf("Item %s has <wp:post_parent> %s, which is not a post ID in this export", describeItem(item), id)))
+			}
+		}
+
+		for _, meta := range item.Children {
+			if meta.Name.Local != "postmeta" || !isWPNamespace(meta.Name.Space) {
+				continue
+			}
+			key, value := wpChild(meta, "meta_key"), wpChild(meta, "meta_value")
+			if key == nil || value == nil || key.trimmedText() != "_thumbnail_id" {
+				continue
+			}
+			id := value.trimmedText()
+			if postType, ok := postTypes[id]; !ok {
+				errors = append(errors, nodeError(ix, value, "WXR broken reference",
+					fmt.Sprintf("Item %s uses attachment %s as its featured image, but no such post is in this export", describeItem(item), id)))
+			} else if postType != "attachment" {
+				errors = append(errors, nodeError(ix, value, "WXR broken reference",
+					fmt.Sprintf("Item %s uses post %s as its featured image, but it is a %q, not an attachment", describeItem(item), id, postType)))
+			}
+		}
+
+		// Without any <wp:author> blocks the importer maps creators itself
+		if creator := item.child(dcNamespace, "creator"); creator != nil && len(authors) > 0 {
+			login := creator.trimmedText()
+			if login != "" && !authors[login] {
+				errors = append(errors, nodeError(ix, creator, "WXR undeclared author",
+					fmt.Sprintf("Item %s is by %q, who has no <wp:author> entry in this export", describeItem(item), login)))
+			}
+		}
+	}
+
+	return errors
+}
+
+// describeItem names an item for error messages using its title when set
+func describeItem(item *xmlNode) string {
+	if title := item.child("", "title"); title != nil && title.trimmedText() != "" {
+		return fmt.Sprintf("%q", title.trimmedText())
+	}
+	return "(untitled)"
+}
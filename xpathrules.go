@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+
+	"github.com/antchfx/xpath"
+)
+
+// xpathNode is a node of the tree XPath assertions are evaluated against.
+// Unlike xmlNode it keeps text, comments and attributes as nodes, in
+// document order, and names as written: content:encoded is matched by its
+// prefix, whatever namespace the document binds it to.
+type xpathNode struct {
+	kind     xpath.NodeType
+	prefix   string
+	local    string
+	value    string // Text of text, comment and attribute nodes
+	attrs    []*xpathNode
+	children []*xpathNode
+	parent   *xpathNode
+	index    int // Position among its parent's children
+	offset   int // Byte offset in the document; an attribute's is its element's
+}
+
+// parseXPathTree builds the XPath tree of a document, or returns nil if
+// it is not well-formed
+func parseXPathTree(content []byte) *xpathNode {
+	root := &xpathNode{kind: xpath.RootNode}
+	current := root
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		offset := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if err == io.EOF && current == root {
+			return root
+		}
+		if err != nil {
+			return nil
+		}
+
+		var node *xpathNode
+		switch t := token.(type) {
+		case xml.StartElement:
+			node = &xpathNode{kind: xpath.ElementNode, prefix: t.Name.Space, local: t.Name.Local, offset: offset}
+			for _, a := range t.Attr {
+				node.attrs = append(node.attrs, &xpathNode{
+					kind: xpath.AttributeNode, prefix: a.Name.Space, local: a.Name.Local,
+					value: a.Value, parent: node, offset: offset,
+				})
+			}
+		case xml.EndElement:
+			if current.parent == nil {
+				return nil
+			}
+			current = current.parent
+			continue
+		case xml.CharData:
+			// Text split by a CDATA section is one text node
+			if n := len(current.children); n > 0 && current.children[n-1].kind == xpath.TextNode {
+				current.children[n-1].value += string(t)
+				continue
+			}
+			node = &xpathNode{kind: xpath.TextNode, value: string(t), offset: offset}
+		case xml.Comment:
+			node = &xpathNode{kind: xpath.CommentNode, value: string(t), offset: offset}
+		default:
+			continue
+		}
+		node.parent, node.index = current, len(current.children)
+		current.children = append(current.children, node)
+		if node.kind == xpath.ElementNode {
+			current = node
+		}
+	}
+}
+
+// stringValue returns the XPath string-value of n: the text of all the
+// text nodes under it
+func (n *xpathNode) stringValue() string {
+	if n.kind != xpath.RootNode && n.kind != xpath.ElementNode {
+		return n.value
+	}
+	var b bytes.Buffer
+	var walk func(*xpathNode)
+	walk = func(n *xpathNode) {
+		for _, c := range n.children {
+			switch c.kind {
+			case xpath.TextNode:
+				b.WriteString(c.value)
+			case xpath.ElementNode:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// xpathNavigator walks an xpathNode tree for the xpath package
+type xpathNavigator struct {
+	root, node *xpathNode
+	attr       int // Index of the current attribute of node, or -1
+}
+
+func newXPathNavigator(root *xpathNode) *xpathNavigator {
+	return &xpathNavigator{root: root, node: root, attr: -1}
+}
+
+// current returns the node the navigator is on, attributes included
+func (x *xpathNavigator) current() *xpathNode {
+	if x.attr >= 0 {
+		return x.node.attrs[x.attr]
+	}
+	return x.node
+}
+
+func (x *xpathNavigator) NodeType() xpath.NodeType { return x.current().kind }
+func (x *xpathNavigator) LocalName() string        { return x.current().local }
+func (x *xpathNavigator) Prefix() string           { return x.current().prefix }
+func (x *xpathNavigator) Value() string            { return x.current().stringValue() }
+
+func (x *xpathNavigator) Copy() xpath.NodeNavigator {
+	c := *x
+	return &c
+}
+
+func (x *xpathNavigator) MoveToRoot() {
+	x.node, x.attr = x.root, -1
+}
+
+func (x *xpathNavigator) MoveToParent() bool {
+	switch {
+	case x.attr >= 0:
+		x.attr = -1
+	case x.node.parent != nil:
+		x.node = x.node.parent
+	default:
+		return false
+	}
+	return true
+}
+
+func (x *xpathNavigator) MoveToNextAttribute() bool {
+	if x.attr+1 >= len(x.node.attrs) {
+		return false
+	}
+	x.attr++
+	return true
+}
+
+func (x *xpathNavigator) MoveToChild() bool {
+	if x.attr >= 0 || len(x.node.children) == 0 {
+		return false
+	}
+	x.node = x.node.children[0]
+	return true
+}
+
+// sibling moves to the sibling at index i, if there is one
+func (x *xpathNavigator) sibling(i int) bool {
+	if x.attr >= 0 || x.node.parent == nil || i < 0 || i >= len(x.node.parent.children) || i == x.node.index {
+		return false
+	}
+	x.node = x.node.parent.children[i]
+	return true
+}
+
+func (x *xpathNavigator) MoveToFirst() bool    { return x.sibling(0) }
+func (x *xpathNavigator) MoveToNext() bool     { return x.sibling(x.node.index + 1) }
+func (x *xpathNavigator) MoveToPrevious() bool { return x.sibling(x.node.index - 1) }
+
+func (x *xpathNavigator) MoveTo(other xpath.NodeNavigator) bool {
+	o, ok := other.(*xpathNavigator)
+	if !ok || o.root != x.root {
+		return false
+	}
+	x.node, x.attr = o.node, o.attr
+	return true
+}
+
+// checkXPath reports each node the rule's XPath expression selects, or
+// the document once if it evaluates to true, a non-zero number or a
+// non-empty string
+func (r CustomRule) checkXPath(content []byte, ix *lineIndex, opts ValidationOptions) []ValidationError {
+	root := parseXPathTree(content)
+	if root == nil {
+		// The well-formedness check reports why
+		return nil
+	}
+	// Compiled expressions keep state while evaluating, so each document
+	// gets its own
+	expr, err := xpath.Compile(r.XPath)
+	if err != nil {
+		return nil
+	}
+
+	report := func(n *xpathNode, content string) ValidationError {
+		line := ix.lineAt(n.offset)
+		return ValidationError{
+			LineNumber: line,
+			Column:     n.offset - ix.lineStart(line) + 1,
+			Line:       string(ix.line(line)),
+			ErrorType:  r.errorType(),
+			Message:    r.Message,
+			Content:    content,
+		}
+	}
+
+	var errs []ValidationError
+	switch result := expr.Evaluate(newXPathNavigator(root)).(type) {
+	case *xpath.NodeIterator:
+		for result.MoveNext() && (opts.MaxErrors == 0 || len(errs) < opts.MaxErrors) {
+			n := result.Current().(*xpathNavigator).current()
+			switch n.kind {
+			case xpath.ElementNode:
+				errs = append(errs, report(n, "<"+rawName(xml.Name{Space: n.prefix, Local: n.local})))
+			case xpath.AttributeNode:
+				errs = append(errs, report(n, rawName(xml.Name{Space: n.prefix, Local: n.local})+"="+n.value))
+			default:
+				errs = append(errs, report(n, ""))
+			}
+		}
+	case bool:
+		if result {
+			errs = append(errs, report(root, ""))
+		}
+	case float64:
+		if result != 0 && !math.IsNaN(result) {
+			errs = append(errs, report(root, ""))
+		}
+	case string:
+		if result != "" {
+			errs = append(errs, report(root, ""))
+		}
+	}
+	return errs
+}